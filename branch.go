@@ -0,0 +1,303 @@
+package swarmgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mohan2020coder/swarmgo/llm"
+)
+
+// MainBranch is the ID of the branch a session starts on
+const MainBranch = "main"
+
+// ErrBranchNotFound is returned for unknown branch IDs
+var ErrBranchNotFound = errors.New("branch not found")
+
+// Branch is one line of a session's conversation. Forking creates a branch that
+// shares the first ForkPoint messages with its parent, so the branches of a
+// session form a tree.
+type Branch struct {
+	ID               string                 `json:"id"`
+	Parent           string                 `json:"parent,omitempty"` // Branch this one was forked from
+	ForkPoint        int                    `json:"fork_point"`       // Messages shared with the parent
+	Agent            string                 `json:"agent,omitempty"`
+	Model            string                 `json:"model,omitempty"` // Model the branch runs with, empty for the session's
+	Messages         []llm.Message          `json:"messages"`
+	ContextVariables map[string]interface{} `json:"context_variables,omitempty"`
+	CreatedAt        time.Time              `json:"created_at"`
+}
+
+// BranchDiff compares the histories of two branches
+type BranchDiff struct {
+	From    string
+	To      string
+	Common  int           // Messages at the start both branches share
+	Removed []llm.Message // Messages of From after the common ones
+	Added   []llm.Message // Messages of To after the common ones
+}
+
+// cloneBranch copies a branch so sessions don't share its state
+func cloneBranch(branch Branch) *Branch {
+	branch.Messages = cloneMessages(branch.Messages)
+	branch.ContextVariables = maps.Clone(branch.ContextVariables)
+	return &branch
+}
+
+// activeBranch returns a copy of the active branch with the session's current state
+func (s *Session) activeBranch() Branch {
+	branch := *cloneBranch(*s.branches[s.branch])
+	branch.Messages = cloneMessages(s.messages)
+	branch.ContextVariables = maps.Clone(s.contextVariables)
+	branch.Agent = ""
+	if s.agent != nil {
+		branch.Agent = s.agent.Name
+	}
+	return branch
+}
+
+// branchList returns copies of all branches, oldest first
+func (s *Session) branchList() []Branch {
+	branches := make([]Branch, 0, len(s.branches))
+	for id, branch := range s.branches {
+		if id == s.branch {
+			branches = append(branches, s.activeBranch())
+			continue
+		}
+		branches = append(branches, *cloneBranch(*branch))
+	}
+	sort.Slice(branches, func(i, j int) bool {
+		if !branches[i].CreatedAt.Equal(branches[j].CreatedAt) {
+			return branches[i].CreatedAt.Before(branches[j].CreatedAt)
+		}
+		return branches[i].ID < branches[j].ID
+	})
+	return branches
+}
+
+// branchByID returns a copy of a branch, with the current state for the active one
+func (s *Session) branchByID(id string) (Branch, error) {
+	if id == s.branch {
+		return s.activeBranch(), nil
+	}
+	branch, ok := s.branches[id]
+	if !ok {
+		return Branch{}, fmt.Errorf("%w: %s", ErrBranchNotFound, id)
+	}
+	return *cloneBranch(*branch), nil
+}
+
+// BranchID returns the ID of the active branch
+func (s *Session) BranchID() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.branch
+}
+
+// Branches returns all branches of the session, oldest first
+func (s *Session) Branches() []Branch {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.branchList()
+}
+
+// ForkOption changes the state a new branch starts with
+type ForkOption func(*forkOptions)
+
+type forkOptions struct {
+	agent *Agent
+	model string
+}
+
+// ForkWithAgent starts the branch with agent instead of the active agent
+func ForkWithAgent(agent *Agent) ForkOption {
+	return func(o *forkOptions) {
+		o.agent = agent
+	}
+}
+
+// ForkWithModel runs the agents of the branch with model instead of the model
+// of the parent branch
+func ForkWithModel(model string) ForkOption {
+	return func(o *forkOptions) {
+		o.model = model
+	}
+}
+
+// Fork starts a new branch from the first n messages of the active branch and
+// switches to it, keeping the active agent, model and context variables unless
+// opts change them. The original branch is kept. Like metadata, branches are
+// saved with the next send or Save.
+func (s *Session) Fork(n int, opts ...ForkOption) (string, error) {
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if n < 0 || n > len(s.messages) {
+		return "", fmt.Errorf("cannot fork at message %d of %d", n, len(s.messages))
+	}
+	return s.fork(n, opts), nil
+}
+
+// fork switches to a new branch with the first n messages, the caller holds mu
+func (s *Session) fork(n int, opts []ForkOption) string {
+	parent := s.activeBranch()
+	*s.branches[s.branch] = parent
+
+	options := forkOptions{agent: s.agent, model: parent.Model}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	s.agent = options.agent
+	s.registerAgent(options.agent)
+
+	now := time.Now()
+	id := uuid.New().String()
+	s.branches[id] = &Branch{ID: id, Parent: s.branch, ForkPoint: n, Model: options.model, CreatedAt: now}
+	s.branch = id
+	s.messages = cloneMessages(s.messages[:n])
+	s.updatedAt = now
+	return id
+}
+
+// Checkout switches to another branch, restoring its history, agent, model and context variables
+func (s *Session) Checkout(id string) error {
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.checkout(id)
+}
+
+// checkout switches to branch id, the caller holds mu
+func (s *Session) checkout(id string) error {
+	if id == s.branch {
+		return nil
+	}
+	branch, ok := s.branches[id]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrBranchNotFound, id)
+	}
+	agent, ok := s.agents[branch.Agent]
+	if !ok {
+		return fmt.Errorf("agent %q of branch %s is not registered", branch.Agent, id)
+	}
+
+	*s.branches[s.branch] = s.activeBranch()
+	s.branch = id
+	s.agent = agent
+	s.messages = cloneMessages(branch.Messages)
+	s.contextVariables = maps.Clone(branch.ContextVariables)
+	if s.contextVariables == nil {
+		s.contextVariables = make(map[string]interface{})
+	}
+	s.updatedAt = time.Now()
+	return nil
+}
+
+// lastUserMessage returns the index of the turns-th last user message, -1 if there are fewer
+func (s *Session) lastUserMessage(turns int) int {
+	for i := len(s.messages) - 1; i >= 0; i-- {
+		if s.messages[i].Role == llm.RoleUser {
+			turns--
+			if turns == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// Rewind forks the conversation before the last turns user messages, so the
+// next send continues from there. The rewound messages stay on the original branch.
+func (s *Session) Rewind(turns int, opts ...ForkOption) (string, error) {
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	index := s.lastUserMessage(turns)
+	if turns <= 0 || index < 0 {
+		return "", fmt.Errorf("cannot rewind %d turns", turns)
+	}
+	return s.fork(index, opts), nil
+}
+
+// Regenerate sends the last user message again on a new branch forked before
+// it, keeping the previous answer on the original branch. Options such as
+// ForkWithModel regenerate the answer with another agent or model.
+func (s *Session) Regenerate(ctx context.Context, opts ...ForkOption) (Response, error) {
+	return s.retry(ctx, nil, opts)
+}
+
+// Edit replaces the last user message with text on a new branch forked before
+// it and runs the agent loop, keeping the original exchange on its branch
+func (s *Session) Edit(ctx context.Context, text string, opts ...ForkOption) (Response, error) {
+	return s.retry(ctx, &llm.Message{Role: llm.RoleUser, Content: text}, opts)
+}
+
+// retry forks before the last user message and sends message, or the original
+// message if nil. When the run fails, the session returns to the original branch.
+func (s *Session) retry(ctx context.Context, message *llm.Message, opts []ForkOption) (Response, error) {
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+
+	s.mu.Lock()
+	index := s.lastUserMessage(1)
+	if index < 0 {
+		s.mu.Unlock()
+		return Response{}, errors.New("no user message to retry")
+	}
+	if message == nil {
+		message = &s.messages[index]
+	}
+	original := s.branch
+	retried := *message
+	branch := s.fork(index, opts)
+	s.mu.Unlock()
+
+	resp, err := s.send(ctx, retried)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.messages) == index && s.branch == branch {
+		// Nothing was recorded, drop the fork
+		if checkoutErr := s.checkout(original); checkoutErr == nil {
+			delete(s.branches, branch)
+		}
+	}
+	return resp, err
+}
+
+// Diff compares the histories of two branches
+func (s *Session) Diff(from, to string) (BranchDiff, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	a, err := s.branchByID(from)
+	if err != nil {
+		return BranchDiff{}, err
+	}
+	b, err := s.branchByID(to)
+	if err != nil {
+		return BranchDiff{}, err
+	}
+
+	common := 0
+	for common < len(a.Messages) && common < len(b.Messages) && reflect.DeepEqual(a.Messages[common], b.Messages[common]) {
+		common++
+	}
+	return BranchDiff{
+		From:    from,
+		To:      to,
+		Common:  common,
+		Removed: a.Messages[common:],
+		Added:   b.Messages[common:],
+	}, nil
+}
@@ -0,0 +1,166 @@
+package swarmgo
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mohan2020coder/swarmgo/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// replyLLM answers each non-streaming request with the next reply and records
+// the requested models
+type replyLLM struct {
+	replies []string
+	models  []string
+}
+
+func (l *replyLLM) CreateChatCompletion(ctx context.Context, req llm.ChatCompletionRequest) (llm.ChatCompletionResponse, error) {
+	l.models = append(l.models, req.Model)
+	if len(l.replies) == 0 {
+		return llm.ChatCompletionResponse{}, errors.New("no more replies")
+	}
+	reply := l.replies[0]
+	l.replies = l.replies[1:]
+	return llm.ChatCompletionResponse{Choices: []llm.Choice{{Message: llm.Message{Role: llm.RoleAssistant, Content: reply}}}}, nil
+}
+
+func (l *replyLLM) CreateChatCompletionStream(ctx context.Context, req llm.ChatCompletionRequest) (llm.ChatCompletionStream, error) {
+	return nil, errors.New("not supported")
+}
+
+func contents(messages []llm.Message) []string {
+	texts := make([]string, len(messages))
+	for i, msg := range messages {
+		texts[i] = msg.Content
+	}
+	return texts
+}
+
+// TestSessionForkCheckoutAndDiff tests forking, switching branches, diffs and
+// that branches survive a snapshot
+func TestSessionForkCheckoutAndDiff(t *testing.T) {
+	ctx := context.Background()
+	agent := &Agent{Name: "Echo", Model: "model"}
+	session := NewSession(NewSwarmWithCustomProvider(&replyLLM{replies: []string{"A", "B", "C"}}, nil), agent)
+	for _, text := range []string{"a", "b"} {
+		_, err := session.Send(ctx, text)
+		require.NoError(t, err)
+	}
+
+	_, err := session.Fork(5)
+	assert.Error(t, err)
+	branch, err := session.Fork(2)
+	require.NoError(t, err)
+	assert.Equal(t, branch, session.BranchID())
+	assert.Equal(t, []string{"a", "A"}, contents(session.Messages()))
+
+	_, err = session.Send(ctx, "c")
+	require.NoError(t, err)
+
+	diff, err := session.Diff(MainBranch, branch)
+	require.NoError(t, err)
+	assert.Equal(t, 2, diff.Common)
+	assert.Equal(t, []string{"b", "B"}, contents(diff.Removed))
+	assert.Equal(t, []string{"c", "C"}, contents(diff.Added))
+
+	branches := session.Branches()
+	require.Len(t, branches, 2)
+	assert.Equal(t, MainBranch, branches[0].ID)
+	assert.Equal(t, MainBranch, branches[1].Parent)
+	assert.Equal(t, 2, branches[1].ForkPoint)
+
+	require.NoError(t, session.Checkout(MainBranch))
+	assert.Equal(t, []string{"a", "A", "b", "B"}, contents(session.Messages()))
+	assert.ErrorIs(t, session.Checkout("missing"), ErrBranchNotFound)
+
+	restored, err := RestoreSession(session.swarm, session.Snapshot(), agent)
+	require.NoError(t, err)
+	assert.Equal(t, MainBranch, restored.BranchID())
+	require.NoError(t, restored.Checkout(branch))
+	assert.Equal(t, []string{"a", "A", "c", "C"}, contents(restored.Messages()))
+}
+
+// TestSessionRegenerateEditAndRewind tests retrying the last message on new branches
+func TestSessionRegenerateEditAndRewind(t *testing.T) {
+	ctx := context.Background()
+	session := NewSession(NewSwarmWithCustomProvider(&replyLLM{replies: []string{"one", "two", "three"}}, nil), &Agent{Name: "Echo", Model: "model"})
+
+	_, err := session.Regenerate(ctx)
+	assert.Error(t, err, "nothing to regenerate yet")
+
+	_, err = session.Send(ctx, "hi")
+	require.NoError(t, err)
+	resp, err := session.Regenerate(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "two", resp.Messages[0].Content)
+	regenerated := session.BranchID()
+	assert.Equal(t, []string{"hi", "two"}, contents(session.Messages()))
+
+	_, err = session.Edit(ctx, "hello")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"hello", "three"}, contents(session.Messages()))
+	edited := session.BranchID()
+	require.Len(t, session.Branches(), 3)
+	assert.Equal(t, regenerated, session.Branches()[2].Parent)
+
+	// A failed retry returns to the branch it started from
+	_, err = session.Regenerate(ctx)
+	require.Error(t, err)
+	assert.Equal(t, edited, session.BranchID())
+	assert.Len(t, session.Branches(), 3)
+	assert.Equal(t, []string{"hello", "three"}, contents(session.Messages()))
+
+	_, err = session.Rewind(2)
+	assert.Error(t, err)
+	_, err = session.Rewind(1)
+	require.NoError(t, err)
+	assert.Empty(t, session.Messages())
+
+	require.NoError(t, session.Checkout(MainBranch))
+	assert.Equal(t, []string{"hi", "one"}, contents(session.Messages()))
+}
+
+// TestSessionForkWithAgentAndModel tests that branches keep the agent and
+// model they were forked with
+func TestSessionForkWithAgentAndModel(t *testing.T) {
+	ctx := context.Background()
+	client := &replyLLM{replies: []string{"A", "B", "C", "D", "E"}}
+	echo := &Agent{Name: "Echo", Model: "echo-model"}
+	critic := &Agent{Name: "Critic", Model: "critic-model"}
+	session := NewSession(NewSwarmWithCustomProvider(client, nil), echo)
+	_, err := session.Send(ctx, "a")
+	require.NoError(t, err)
+
+	criticBranch, err := session.Fork(2, ForkWithAgent(critic))
+	require.NoError(t, err)
+	assert.Equal(t, critic, session.Agent())
+	_, err = session.Send(ctx, "b")
+	require.NoError(t, err)
+
+	resp, err := session.Regenerate(ctx, ForkWithModel("large-model"))
+	require.NoError(t, err)
+	assert.Equal(t, "C", resp.Messages[0].Content)
+	assert.Equal(t, critic, session.Agent())
+	largeBranch := session.BranchID()
+
+	require.NoError(t, session.Checkout(MainBranch))
+	assert.Equal(t, echo, session.Agent())
+	_, err = session.Send(ctx, "c")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"echo-model", "critic-model", "large-model", "echo-model"}, client.models)
+
+	// A restored session switches back to the branch's agent and model
+	restored, err := RestoreSession(session.swarm, session.Snapshot(), echo, critic)
+	require.NoError(t, err)
+	require.NoError(t, restored.Checkout(largeBranch))
+	assert.Equal(t, critic, restored.Agent())
+	_, err = restored.Send(ctx, "d")
+	require.NoError(t, err)
+	assert.Equal(t, "large-model", client.models[len(client.models)-1])
+
+	require.NoError(t, restored.Checkout(criticBranch))
+	assert.Equal(t, []string{"a", "A", "b", "B"}, contents(restored.Messages()))
+}
@@ -0,0 +1,191 @@
+package swarmgo
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/mohan2020coder/swarmgo/llm"
+)
+
+// emulatedToolInstructions explains the JSON reply format used when a model has no native tools
+const emulatedToolInstructions = `You can call the tools listed below. To call tools, reply with only a JSON object of the form
+{"tool_calls": [{"name": "<tool name>", "arguments": {<arguments>}}]}
+and nothing else. Otherwise answer normally.
+
+Tools:
+`
+
+// Capabilities returns the capabilities of the client an agent is routed to for model
+func (s *Swarm) Capabilities(agent *Agent, model string) llm.Capabilities {
+	client, _ := s.clientFor(agent)
+	if client == nil {
+		return llm.DefaultCapabilities()
+	}
+	return s.capabilitiesOf(client, model)
+}
+
+// capabilitiesOf looks up the capabilities of client in the configured catalog
+func (s *Swarm) capabilitiesOf(client llm.LLM, model string) llm.Capabilities {
+	if mapped, ok := client.(*modelMappingLLM); ok {
+		client, model = mapped.LLM, mapped.mapModel(model)
+	}
+	return s.config.ModelCatalog().CapabilitiesOf(client, model)
+}
+
+// Capabilities reports the capabilities of the wrapped client for the mapped model
+func (m *modelMappingLLM) Capabilities(model string) llm.Capabilities {
+	return llm.CapabilitiesOf(m.LLM, m.mapModel(model))
+}
+
+// adaptRequest degrades a request to what the provider supports. It reports
+// whether tools are emulated through prompting.
+func adaptRequest(caps llm.Capabilities, req llm.ChatCompletionRequest) (llm.ChatCompletionRequest, bool) {
+	emulateTools := len(req.Tools) > 0 && !caps.Tools
+	if caps.Vision && caps.SystemRole && !emulateTools {
+		return req, false
+	}
+
+	messages := make([]llm.Message, 0, len(req.Messages)+1)
+	for _, msg := range req.Messages {
+		if !caps.Vision && msg.HasMedia() {
+			msg.Content = msg.Text()
+			for _, part := range msg.Parts {
+				if part.Type != llm.ContentPartText {
+					msg.Content += fmt.Sprintf("\n[%s omitted: not supported by this model]", part.Type)
+				}
+			}
+			msg.Parts = nil
+		}
+
+		if emulateTools {
+			switch {
+			case msg.Role == llm.RoleAssistant && len(msg.ToolCalls) > 0:
+				msg.Content = strings.TrimSpace(msg.Content + "\n" + encodeEmulatedToolCalls(msg.ToolCalls))
+				msg.ToolCalls = nil
+			case msg.Role == llm.RoleFunction || msg.Role == llm.RoleTool:
+				msg = llm.Message{Role: llm.RoleUser, Content: fmt.Sprintf("Result of %s: %s", msg.Name, msg.Text())}
+			}
+		}
+		messages = append(messages, msg)
+	}
+
+	if emulateTools {
+		messages = append([]llm.Message{{Role: llm.RoleSystem, Content: emulatedToolPrompt(req.Tools)}}, messages...)
+		req.Tools = nil
+	}
+
+	if !caps.SystemRole {
+		messages = foldSystemMessages(messages)
+	}
+	req.Messages = messages
+	return req, emulateTools
+}
+
+// foldSystemMessages prepends system messages to the next user message
+func foldSystemMessages(messages []llm.Message) []llm.Message {
+	result := make([]llm.Message, 0, len(messages))
+	var pending []string
+	for _, msg := range messages {
+		if msg.Role == llm.RoleSystem {
+			if msg.Content != "" {
+				pending = append(pending, msg.Content)
+			}
+			continue
+		}
+		if msg.Role == llm.RoleUser && len(pending) > 0 {
+			msg.Content = strings.Join(append(pending, msg.Content), "\n\n")
+			pending = nil
+		}
+		result = append(result, msg)
+	}
+	if len(pending) > 0 {
+		result = append(result, llm.Message{Role: llm.RoleUser, Content: strings.Join(pending, "\n\n")})
+	}
+	return result
+}
+
+// emulatedToolPrompt describes the tools for models without native function calling
+func emulatedToolPrompt(tools []llm.Tool) string {
+	var prompt strings.Builder
+	prompt.WriteString(emulatedToolInstructions)
+	for _, tool := range tools {
+		if tool.Function == nil {
+			continue
+		}
+		params, _ := json.Marshal(tool.Function.Parameters)
+		fmt.Fprintf(&prompt, "- %s: %s\n  parameters: %s\n", tool.Function.Name, tool.Function.Description, params)
+	}
+	return prompt.String()
+}
+
+type emulatedToolCall struct {
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+// encodeEmulatedToolCalls writes tool calls in the emulated reply format
+func encodeEmulatedToolCalls(toolCalls []llm.ToolCall) string {
+	calls := make([]emulatedToolCall, len(toolCalls))
+	for i, call := range toolCalls {
+		args := json.RawMessage(call.Function.Arguments)
+		if !json.Valid(args) {
+			args = json.RawMessage("{}")
+		}
+		calls[i] = emulatedToolCall{Name: call.Function.Name, Arguments: args}
+	}
+	data, _ := json.Marshal(map[string]interface{}{"tool_calls": calls})
+	return string(data)
+}
+
+// parseEmulatedToolCalls turns a JSON tool call reply into native tool calls.
+// Replies that are not tool calls are returned unchanged.
+func parseEmulatedToolCalls(resp llm.ChatCompletionResponse, tools []llm.Tool) llm.ChatCompletionResponse {
+	known := make(map[string]bool, len(tools))
+	for _, tool := range tools {
+		if tool.Function != nil {
+			known[tool.Function.Name] = true
+		}
+	}
+
+	for i, choice := range resp.Choices {
+		content := strings.TrimSpace(choice.Message.Content)
+		content = strings.TrimPrefix(content, "```json")
+		content = strings.TrimPrefix(content, "```")
+		content = strings.TrimSuffix(content, "```")
+		start, end := strings.Index(content, "{"), strings.LastIndex(content, "}")
+		if start < 0 || end < start {
+			continue
+		}
+
+		var reply struct {
+			ToolCalls []emulatedToolCall `json:"tool_calls"`
+		}
+		if err := json.Unmarshal([]byte(content[start:end+1]), &reply); err != nil || len(reply.ToolCalls) == 0 {
+			continue
+		}
+
+		var calls []llm.ToolCall
+		for j, call := range reply.ToolCalls {
+			if !known[call.Name] {
+				continue
+			}
+			args := string(call.Arguments)
+			if args == "" || args == "null" {
+				args = "{}"
+			}
+			calls = append(calls, llm.ToolCall{
+				ID:       fmt.Sprintf("call_emulated_%d_%d", i, j),
+				Type:     "function",
+				Function: llm.ToolCallFunction{Name: call.Name, Arguments: args},
+			})
+		}
+		if len(calls) == 0 {
+			continue
+		}
+		resp.Choices[i].Message.Content = ""
+		resp.Choices[i].Message.ToolCalls = calls
+		resp.Choices[i].FinishReason = "tool_calls"
+	}
+	return resp
+}
@@ -0,0 +1,121 @@
+package swarmgo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mohan2020coder/swarmgo/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// noStreamToolsLLM answers completions but cannot stream tool calls
+type noStreamToolsLLM struct {
+	requests []llm.ChatCompletionRequest
+}
+
+func (l *noStreamToolsLLM) CreateChatCompletion(ctx context.Context, req llm.ChatCompletionRequest) (llm.ChatCompletionResponse, error) {
+	l.requests = append(l.requests, req)
+	return llm.ChatCompletionResponse{Choices: []llm.Choice{{Message: llm.Message{
+		Role:      llm.RoleAssistant,
+		ToolCalls: []llm.ToolCall{{ID: "call_1", Type: "function", Function: llm.ToolCallFunction{Name: "lookup", Arguments: "{}"}}},
+	}}}}, nil
+}
+
+func (l *noStreamToolsLLM) CreateChatCompletionStream(ctx context.Context, req llm.ChatCompletionRequest) (llm.ChatCompletionStream, error) {
+	return nil, errors.New("streaming tool calls is not supported")
+}
+
+func (l *noStreamToolsLLM) Capabilities(model string) llm.Capabilities {
+	caps := llm.DefaultCapabilities()
+	caps.StreamToolDeltas = false
+	caps.Vision = false
+	return caps
+}
+
+var lookupTool = llm.Tool{Type: "function", Function: &llm.Function{
+	Name:       "lookup",
+	Parameters: map[string]interface{}{"type": "object", "properties": map[string]interface{}{"q": map[string]interface{}{"type": "string"}}},
+}}
+
+// TestCapabilitiesOfProviders tests built-in capabilities and registered overrides
+func TestCapabilitiesOfProviders(t *testing.T) {
+	deepseek := llm.NewDeepSeekLLM("test-key")
+	assert.False(t, llm.CapabilitiesOf(deepseek, "deepseek-reasoner").Tools)
+	assert.True(t, llm.CapabilitiesOf(deepseek, "deepseek-reasoner").Reasoning)
+	assert.True(t, llm.CapabilitiesOf(deepseek, "deepseek-chat").Tools)
+
+	ollama, err := llm.NewOllamaLLMWithURL("http://localhost:11434")
+	require.NoError(t, err)
+	assert.True(t, llm.CapabilitiesOf(ollama, "llava:13b").Vision)
+	assert.False(t, llm.CapabilitiesOf(ollama, "llama3.2").Vision)
+	assert.True(t, llm.CapabilitiesOf(ollama, "llama3.2").Tools)
+	assert.True(t, llm.CapabilitiesOf(ollama, "qwen2.5:7b").Tools)
+	assert.False(t, llm.CapabilitiesOf(ollama, "gemma2").Tools)
+	assert.False(t, llm.CapabilitiesOf(ollama, "llava:13b").Tools)
+	assert.False(t, llm.CapabilitiesOf(ollama, "phi3").ParallelToolCalls)
+
+	llm.RegisterModelCapabilities("capabilities-test-*", llm.Capabilities{Streaming: true})
+	assert.Equal(t, llm.Capabilities{Streaming: true}, llm.CapabilitiesOf(ollama, "capabilities-test-model"))
+}
+
+// TestSwarmEmulatesToolsByPrompting tests tool emulation for models without native tools
+func TestSwarmEmulatesToolsByPrompting(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		_, _ = io.WriteString(w, `{"id":"1","choices":[{"index":0,"message":{"role":"assistant",
+			"content":"`+"```json\\n"+`{\"tool_calls\":[{\"name\":\"lookup\",\"arguments\":{\"q\":\"swarm\"}}]}`+"\\n```"+`"},"finish_reason":"stop"}]}`)
+	}))
+	defer server.Close()
+
+	swarm := NewSwarmFromClientConfig(&ClientConfig{Provider: llm.DeepSeek, AuthToken: "test-key", BaseURL: server.URL}, nil)
+	resp, err := swarm.createChatCompletion(context.Background(), &Agent{Model: "deepseek-reasoner"}, llm.ChatCompletionRequest{
+		Model:    "deepseek-reasoner",
+		Messages: []llm.Message{{Role: llm.RoleUser, Content: "find swarm"}},
+		Tools:    []llm.Tool{lookupTool},
+	})
+	require.NoError(t, err)
+
+	assert.NotContains(t, body, "tools")
+	system := body["messages"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "system", system["role"])
+	assert.Contains(t, system["content"], "- lookup:")
+
+	choice := resp.Choices[0]
+	assert.Equal(t, "tool_calls", choice.FinishReason)
+	require.Len(t, choice.Message.ToolCalls, 1)
+	assert.Equal(t, "lookup", choice.Message.ToolCalls[0].Function.Name)
+	assert.JSONEq(t, `{"q":"swarm"}`, choice.Message.ToolCalls[0].Function.Arguments)
+}
+
+// TestSwarmStreamFallsBackWithoutStreamToolDeltas tests that tool requests are replayed from a completion
+func TestSwarmStreamFallsBackWithoutStreamToolDeltas(t *testing.T) {
+	client := &noStreamToolsLLM{}
+	swarm := NewSwarmWithCustomProvider(client, nil)
+
+	stream, err := swarm.createChatCompletionStream(context.Background(), &Agent{Model: "model"}, llm.ChatCompletionRequest{
+		Model: "model",
+		Messages: []llm.Message{{Role: llm.RoleUser, Content: "look", Parts: []llm.ContentPart{
+			llm.ImagePart([]byte("\x89PNG\r\n\x1a\n"), ""),
+		}}},
+		Tools: []llm.Tool{lookupTool},
+	})
+	require.NoError(t, err)
+
+	resp, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Len(t, resp.Choices[0].Message.ToolCalls, 1)
+	_, err = stream.Recv()
+	assert.Equal(t, io.EOF, err)
+
+	require.Len(t, client.requests, 1)
+	sent := client.requests[0].Messages[0]
+	assert.Empty(t, sent.Parts)
+	assert.Contains(t, sent.Content, "[image omitted")
+}
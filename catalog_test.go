@@ -0,0 +1,141 @@
+package swarmgo
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mohan2020coder/swarmgo/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestModelCatalogLookup tests lookups by ID, alias, provider prefix and Ollama tag
+func TestModelCatalogLookup(t *testing.T) {
+	catalog := llm.DefaultModelCatalog()
+
+	info, ok := catalog.Lookup("gpt-4o")
+	require.True(t, ok)
+	assert.Equal(t, 128000, info.ContextWindow)
+	assert.Equal(t, llm.OpenAI, info.Provider)
+
+	assert.Equal(t, "claude-3-5-sonnet-20241022", catalog.Resolve("claude-3-5-sonnet-latest"))
+	assert.Equal(t, "gpt-4o-mini", catalog.Resolve("openrouter/gpt-4o-mini"))
+	assert.Equal(t, "llama3.2", catalog.Resolve("llama3.2:3b"))
+	assert.Equal(t, "unknown-model", catalog.Resolve("unknown-model"))
+
+	for _, model := range catalog.Models(llm.Claude) {
+		assert.Equal(t, llm.Claude, model.Provider)
+	}
+	assert.NotEmpty(t, catalog.Models(llm.Gemini))
+}
+
+// TestModelCatalogCost tests pricing with prompt cache reads and writes
+func TestModelCatalogCost(t *testing.T) {
+	catalog := llm.NewModelCatalog()
+	catalog.Add(llm.ModelInfo{ID: "priced", Pricing: llm.ModelPricing{Input: 2, Output: 10, CachedInput: 1, CacheWrite: 4}})
+	catalog.Add(llm.ModelInfo{ID: "plain", Pricing: llm.ModelPricing{Input: 2, Output: 10}})
+
+	usage := llm.Usage{PromptTokens: 1_000_000, CompletionTokens: 500_000, CacheReadTokens: 400_000, CacheCreationTokens: 100_000}
+	cost, ok := catalog.Cost("priced", usage)
+	require.True(t, ok)
+	assert.InDelta(t, 0.5*2+0.4*1+0.1*4+0.5*10, cost, 1e-9)
+
+	cost, ok = catalog.Cost("plain", usage)
+	require.True(t, ok)
+	assert.InDelta(t, 1*2+0.5*10, cost, 1e-9)
+
+	_, ok = catalog.Cost("missing", usage)
+	assert.False(t, ok)
+
+	swarm := &Swarm{config: &Config{Catalog: catalog}}
+	assert.InDelta(t, 7.0, swarm.Cost("plain", usage), 1e-9)
+}
+
+// TestModelCatalogLoadFile tests loading and overriding models from YAML and JSON files
+func TestModelCatalogLoadFile(t *testing.T) {
+	dir := t.TempDir()
+	yamlPath := filepath.Join(dir, "models.yaml")
+	require.NoError(t, os.WriteFile(yamlPath, []byte(`
+models:
+  - id: my-finetune
+    provider: OPEN_AI
+    context_window: 32000
+    pricing: {input: 3, output: 12}
+    capabilities: {tools: true, streaming: true, system_role: true}
+    aliases: [ft:gpt-4o-mini:acme]
+`), 0o644))
+	jsonPath := filepath.Join(dir, "models.json")
+	require.NoError(t, os.WriteFile(jsonPath, []byte(`{"models":[{"id":"my-finetune","context_window":64000}]}`), 0o644))
+
+	catalog := llm.NewModelCatalog()
+	require.NoError(t, catalog.LoadFile(yamlPath))
+
+	info, ok := catalog.Lookup("ft:gpt-4o-mini:acme")
+	require.True(t, ok)
+	assert.Equal(t, "my-finetune", info.ID)
+	assert.Equal(t, 32000, info.ContextWindow)
+	require.NotNil(t, info.Capabilities)
+	assert.False(t, info.Capabilities.Vision)
+
+	require.NoError(t, catalog.LoadFile(jsonPath))
+	info, _ = catalog.Lookup("my-finetune")
+	assert.Equal(t, 64000, info.ContextWindow)
+	_, ok = catalog.Lookup("ft:gpt-4o-mini:acme")
+	assert.False(t, ok)
+
+	assert.Error(t, catalog.LoadFile(filepath.Join(dir, "models.toml")))
+	assert.Error(t, catalog.LoadYAML(strings.NewReader("models:\n  - provider: OPEN_AI\n")))
+}
+
+// TestConfigContextWindow tests that TokenLimits take precedence over the catalog
+func TestConfigContextWindow(t *testing.T) {
+	config := DefaultConfig()
+	assert.Equal(t, 16385, config.ContextWindow("gpt-3.5-turbo"))
+	assert.Equal(t, 200000, config.ContextWindow("claude-3-opus"))
+	assert.Equal(t, 0, config.ContextWindow("unknown-model"))
+
+	config.TokenLimits["gpt-3.5-turbo"] = 4096
+	assert.Equal(t, 4096, config.ContextWindow("gpt-3.5-turbo"))
+}
+
+// TestCapabilitiesFromCatalog tests that catalog capabilities take precedence over
+// the provider, which covers models without them
+func TestCapabilitiesFromCatalog(t *testing.T) {
+	assert.True(t, llm.CapabilitiesOf(new(MockLLM), "gpt-4o").Vision)
+	assert.False(t, llm.CapabilitiesOf(new(MockLLM), "gpt-3.5-turbo").Vision)
+
+	catalog := llm.NewModelCatalog()
+	catalog.Add(llm.ModelInfo{ID: "text-only", Capabilities: &llm.Capabilities{Streaming: true}})
+	catalog.Add(llm.ModelInfo{ID: "deepseek-chat", Capabilities: &llm.Capabilities{Vision: true}})
+	config := DefaultConfig()
+	config.Catalog = catalog
+
+	swarm := NewSwarmWithCustomProvider(new(MockLLM), config)
+	assert.Equal(t, llm.Capabilities{Streaming: true}, swarm.Capabilities(nil, "text-only"))
+	assert.Equal(t, llm.DefaultCapabilities(), swarm.Capabilities(nil, "gpt-3.5-turbo"))
+
+	// The catalog entry wins, the provider answers for the rest
+	swarm = NewSwarmWithCustomProvider(llm.NewDeepSeekLLM("test-key"), config)
+	assert.Equal(t, llm.Capabilities{Vision: true}, swarm.Capabilities(nil, "deepseek-chat"))
+	assert.False(t, swarm.Capabilities(nil, "deepseek-reasoner").Tools)
+}
+
+// TestCatalogOverrideChangesProviderCapabilities tests that a loaded catalog
+// overrides the capabilities an OpenAI client reports
+func TestCatalogOverrideChangesProviderCapabilities(t *testing.T) {
+	client := llm.NewOpenAILLM("test-key")
+	assert.True(t, llm.CapabilitiesOf(client, "gpt-4o").Tools)
+
+	catalog := llm.NewModelCatalog()
+	require.NoError(t, catalog.LoadJSON(strings.NewReader(`{"models":[
+		{"id":"gpt-4o","provider":"OPEN_AI","capabilities":{"streaming":true,"system_role":true}}
+	]}`)))
+	config := DefaultConfig()
+	config.Catalog = catalog
+
+	swarm := NewSwarmWithCustomProvider(client, config)
+	assert.Equal(t, llm.Capabilities{Streaming: true, SystemRole: true}, swarm.Capabilities(nil, "gpt-4o"))
+	assert.True(t, swarm.Capabilities(nil, "gpt-4o-mini").Tools)
+}
@@ -0,0 +1,305 @@
+package swarmgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mohan2020coder/swarmgo/llm"
+)
+
+// ErrCircuitOpen is matched by every CircuitOpenError, use errors.Is to detect it
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitState represents the state of a circuit
+type CircuitState int
+
+const (
+	CircuitClosed   CircuitState = iota // Requests flow normally
+	CircuitOpen                         // Requests fail fast until the cool-down ends
+	CircuitHalfOpen                     // A limited number of trial requests are allowed
+)
+
+// String returns the name of the state
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return fmt.Sprintf("CircuitState(%d)", int(s))
+	}
+}
+
+// CircuitOpenError is returned instead of calling a provider whose circuit is open
+type CircuitOpenError struct {
+	Provider   llm.LLMProvider
+	Model      string
+	RetryAfter time.Duration // Remaining cool-down before a trial request is allowed
+	LastError  error         // The failure that opened the circuit
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("circuit open for %s: retry after %v", limitKey(e.Provider, e.Model), e.RetryAfter.Round(time.Millisecond))
+}
+
+// Is reports whether target is ErrCircuitOpen
+func (e *CircuitOpenError) Is(target error) bool {
+	return target == ErrCircuitOpen
+}
+
+// Unwrap returns the failure that opened the circuit
+func (e *CircuitOpenError) Unwrap() error {
+	return e.LastError
+}
+
+// CircuitEvent describes a state change of a single circuit
+type CircuitEvent struct {
+	Provider llm.LLMProvider
+	Model    string
+	From     CircuitState
+	To       CircuitState
+	Err      error // The error that caused the transition, if any
+	Time     time.Time
+}
+
+// CircuitBreakerConfig holds the thresholds of a circuit breaker
+type CircuitBreakerConfig struct {
+	FailureThreshold int              // Consecutive failures that open the circuit
+	CoolDown         time.Duration    // Time spent open before allowing trial requests
+	HalfOpenMaxCalls int              // Concurrent trial requests allowed while half-open
+	SuccessThreshold int              // Successful trial requests needed to close the circuit
+	IsFailure        func(error) bool // Decides which errors count as failures
+}
+
+// DefaultCircuitBreakerConfig returns default circuit breaker settings
+func DefaultCircuitBreakerConfig() CircuitBreakerConfig {
+	return CircuitBreakerConfig{
+		FailureThreshold: 5,
+		CoolDown:         30 * time.Second,
+		HalfOpenMaxCalls: 1,
+		SuccessThreshold: 1,
+		IsFailure:        isCircuitFailure,
+	}
+}
+
+// isCircuitFailure counts errors that indicate a degraded provider. Caller
+// cancellations, client-side rate limits and configuration errors are ignored.
+func isCircuitFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, ErrRateLimitExceeded) || errors.Is(err, ErrCircuitOpen) {
+		return false
+	}
+	return !isFatalError(err)
+}
+
+// circuit holds the state for a single provider/model pair
+type circuit struct {
+	state     CircuitState
+	failures  int
+	successes int
+	inFlight  int
+	openedAt  time.Time
+	lastErr   error
+}
+
+// CircuitBreaker tracks provider health per provider and model and fails fast
+// while a provider is degraded. It can be shared between swarms.
+type CircuitBreaker struct {
+	mu        sync.Mutex
+	config    CircuitBreakerConfig
+	circuits  map[string]*circuit
+	listeners []func(CircuitEvent)
+	now       func() time.Time
+}
+
+// NewCircuitBreaker creates a circuit breaker, zero fields fall back to the defaults
+func NewCircuitBreaker(config CircuitBreakerConfig) *CircuitBreaker {
+	defaults := DefaultCircuitBreakerConfig()
+	if config.FailureThreshold <= 0 {
+		config.FailureThreshold = defaults.FailureThreshold
+	}
+	if config.CoolDown <= 0 {
+		config.CoolDown = defaults.CoolDown
+	}
+	if config.HalfOpenMaxCalls <= 0 {
+		config.HalfOpenMaxCalls = defaults.HalfOpenMaxCalls
+	}
+	if config.SuccessThreshold <= 0 {
+		config.SuccessThreshold = defaults.SuccessThreshold
+	}
+	if config.IsFailure == nil {
+		config.IsFailure = defaults.IsFailure
+	}
+
+	return &CircuitBreaker{
+		config:   config,
+		circuits: make(map[string]*circuit),
+		now:      time.Now,
+	}
+}
+
+// OnStateChange registers a listener that is called after every state change
+func (cb *CircuitBreaker) OnStateChange(listener func(CircuitEvent)) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.listeners = append(cb.listeners, listener)
+}
+
+// circuitFor returns the circuit for a provider/model pair, creating it if needed
+func (cb *CircuitBreaker) circuitFor(provider llm.LLMProvider, model string) *circuit {
+	key := limitKey(provider, model)
+	c, ok := cb.circuits[key]
+	if !ok {
+		c = &circuit{state: CircuitClosed}
+		cb.circuits[key] = c
+	}
+	return c
+}
+
+// transition changes the state of a circuit and returns the event to emit
+func (cb *CircuitBreaker) transition(c *circuit, provider llm.LLMProvider, model string, to CircuitState, err error) CircuitEvent {
+	event := CircuitEvent{
+		Provider: provider,
+		Model:    model,
+		From:     c.state,
+		To:       to,
+		Err:      err,
+		Time:     cb.now(),
+	}
+
+	c.state = to
+	c.failures = 0
+	c.successes = 0
+	if to == CircuitOpen {
+		c.openedAt = event.Time
+	}
+	return event
+}
+
+// emit calls the listeners outside of the lock
+func (cb *CircuitBreaker) emit(events ...CircuitEvent) {
+	if len(events) == 0 {
+		return
+	}
+	cb.mu.Lock()
+	listeners := append([]func(CircuitEvent){}, cb.listeners...)
+	cb.mu.Unlock()
+
+	for _, event := range events {
+		for _, listener := range listeners {
+			listener(event)
+		}
+	}
+}
+
+// Allow checks whether a request may be sent to provider and model. It returns
+// a *CircuitOpenError while the circuit is open. Every allowed request must be
+// followed by a call to Record.
+func (cb *CircuitBreaker) Allow(provider llm.LLMProvider, model string) error {
+	var events []CircuitEvent
+	defer func() { cb.emit(events...) }()
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	c := cb.circuitFor(provider, model)
+	if c.state == CircuitOpen {
+		elapsed := cb.now().Sub(c.openedAt)
+		if elapsed < cb.config.CoolDown {
+			return &CircuitOpenError{
+				Provider:   provider,
+				Model:      model,
+				RetryAfter: cb.config.CoolDown - elapsed,
+				LastError:  c.lastErr,
+			}
+		}
+		events = append(events, cb.transition(c, provider, model, CircuitHalfOpen, nil))
+	}
+
+	if c.state == CircuitHalfOpen {
+		if c.inFlight >= cb.config.HalfOpenMaxCalls {
+			return &CircuitOpenError{Provider: provider, Model: model, LastError: c.lastErr}
+		}
+	}
+
+	c.inFlight++
+	return nil
+}
+
+// Record reports the outcome of a request previously allowed by Allow
+func (cb *CircuitBreaker) Record(provider llm.LLMProvider, model string, err error) {
+	var events []CircuitEvent
+	defer func() { cb.emit(events...) }()
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	c := cb.circuitFor(provider, model)
+	if c.inFlight > 0 {
+		c.inFlight--
+	}
+
+	switch {
+	case err == nil:
+		c.failures = 0
+		if c.state == CircuitHalfOpen {
+			c.successes++
+			if c.successes >= cb.config.SuccessThreshold {
+				events = append(events, cb.transition(c, provider, model, CircuitClosed, nil))
+			}
+		}
+	case cb.config.IsFailure(err):
+		c.lastErr = err
+		c.failures++
+		if c.state == CircuitHalfOpen || (c.state == CircuitClosed && c.failures >= cb.config.FailureThreshold) {
+			events = append(events, cb.transition(c, provider, model, CircuitOpen, err))
+		}
+	}
+}
+
+// release frees a request allowed by Allow without reporting an outcome, for
+// requests that ended before the provider's health could be judged
+func (cb *CircuitBreaker) release(provider llm.LLMProvider, model string) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	c := cb.circuitFor(provider, model)
+	if c.inFlight > 0 {
+		c.inFlight--
+	}
+}
+
+// State returns the current state of the circuit for provider and model
+func (cb *CircuitBreaker) State(provider llm.LLMProvider, model string) CircuitState {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	c := cb.circuitFor(provider, model)
+	if c.state == CircuitOpen && cb.now().Sub(c.openedAt) >= cb.config.CoolDown {
+		return CircuitHalfOpen
+	}
+	return c.state
+}
+
+// Reset closes the circuit for provider and model
+func (cb *CircuitBreaker) Reset(provider llm.LLMProvider, model string) {
+	var events []CircuitEvent
+	defer func() { cb.emit(events...) }()
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	c := cb.circuitFor(provider, model)
+	if c.state != CircuitClosed {
+		events = append(events, cb.transition(c, provider, model, CircuitClosed, nil))
+	}
+	c.lastErr = nil
+}
@@ -0,0 +1,156 @@
+package swarmgo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mohan2020coder/swarmgo/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCircuitBreakerTransitions tests the closed, open and half-open cycle
+func TestCircuitBreakerTransitions(t *testing.T) {
+	now := time.Now()
+	cb := NewCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 2, CoolDown: time.Minute})
+	cb.now = func() time.Time { return now }
+
+	var events []CircuitEvent
+	cb.OnStateChange(func(e CircuitEvent) { events = append(events, e) })
+
+	failure := errors.New("503 service unavailable")
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, cb.Allow(llm.OpenAI, "gpt-4o"))
+		cb.Record(llm.OpenAI, "gpt-4o", failure)
+	}
+	assert.Equal(t, CircuitOpen, cb.State(llm.OpenAI, "gpt-4o"))
+
+	err := cb.Allow(llm.OpenAI, "gpt-4o")
+	var openErr *CircuitOpenError
+	assert.True(t, errors.As(err, &openErr))
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.Equal(t, time.Minute, openErr.RetryAfter)
+
+	// Other models are unaffected
+	assert.NoError(t, cb.Allow(llm.OpenAI, "gpt-4o-mini"))
+
+	// After the cool-down a single trial request is allowed
+	now = now.Add(time.Minute)
+	assert.NoError(t, cb.Allow(llm.OpenAI, "gpt-4o"))
+	assert.ErrorIs(t, cb.Allow(llm.OpenAI, "gpt-4o"), ErrCircuitOpen)
+	cb.Record(llm.OpenAI, "gpt-4o", nil)
+	assert.Equal(t, CircuitClosed, cb.State(llm.OpenAI, "gpt-4o"))
+
+	if assert.Len(t, events, 3) {
+		assert.Equal(t, CircuitOpen, events[0].To)
+		assert.Equal(t, CircuitHalfOpen, events[1].To)
+		assert.Equal(t, CircuitClosed, events[2].To)
+	}
+}
+
+// TestCircuitBreakerIgnoresCallerErrors tests that cancellations do not open the circuit
+func TestCircuitBreakerIgnoresCallerErrors(t *testing.T) {
+	cb := NewCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 1})
+	assert.NoError(t, cb.Allow(llm.Claude, "claude-3-opus"))
+	cb.Record(llm.Claude, "claude-3-opus", context.Canceled)
+	assert.Equal(t, CircuitClosed, cb.State(llm.Claude, "claude-3-opus"))
+}
+
+// TestRunStopsRetryingOnOpenCircuit tests that Run retries failed turns until
+// the provider's circuit opens
+func TestRunStopsRetryingOnOpenCircuit(t *testing.T) {
+	mockClient := new(MockLLM)
+	config := DefaultConfig()
+	config.RetryBackoff = time.Millisecond
+	config.MaxRetries = 5
+	config.CircuitBreaker = NewCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 2, CoolDown: time.Minute})
+	sw := NewSwarmWithCustomProvider(mockClient, config)
+
+	mockClient.On("CreateChatCompletion", mock.Anything, mock.Anything).
+		Return(llm.ChatCompletionResponse{}, errors.New("502 bad gateway"))
+
+	agent := &Agent{Name: "TestAgent", Model: "test-model"}
+	_, err := sw.Run(context.Background(), agent, []llm.Message{{Role: llm.RoleUser, Content: "Hello"}}, nil, "", false, false, 1, true)
+
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	mockClient.AssertNumberOfCalls(t, "CreateChatCompletion", 2)
+}
+
+// TestRunRetriesFailedTurn tests that a turn succeeds after a transient error
+func TestRunRetriesFailedTurn(t *testing.T) {
+	mockClient := new(MockLLM)
+	config := DefaultConfig()
+	config.RetryBackoff = time.Millisecond
+	sw := NewSwarmWithCustomProvider(mockClient, config)
+
+	mockClient.On("CreateChatCompletion", mock.Anything, mock.Anything).
+		Return(llm.ChatCompletionResponse{}, errors.New("502 bad gateway")).Once()
+	mockClient.On("CreateChatCompletion", mock.Anything, mock.Anything).
+		Return(llm.ChatCompletionResponse{Choices: []llm.Choice{{Message: llm.Message{Role: llm.RoleAssistant, Content: "Hi"}}}}, nil).Once()
+
+	agent := &Agent{Name: "TestAgent", Model: "test-model"}
+	resp, err := sw.Run(context.Background(), agent, []llm.Message{{Role: llm.RoleUser, Content: "Hello"}}, nil, "", false, false, 1, true)
+
+	require.NoError(t, err)
+	assert.Equal(t, "Hi", resp.Messages[len(resp.Messages)-1].Content)
+	mockClient.AssertNumberOfCalls(t, "CreateChatCompletion", 2)
+}
+
+// TestRateLimitWaitDoesNotOpenCircuit tests that a caller's deadline expiring in the
+// rate limiter queue is not counted against the provider
+func TestRateLimitWaitDoesNotOpenCircuit(t *testing.T) {
+	mockClient := new(MockLLM)
+	config := DefaultConfig()
+	config.CircuitBreaker = NewCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 1})
+	sw := NewSwarmWithCustomProvider(mockClient, config)
+	sw.SetRateLimiter(NewRateLimiter(RateLimit{RequestsPerMinute: 1}))
+
+	mockClient.On("CreateChatCompletion", mock.Anything, mock.Anything).
+		Return(llm.ChatCompletionResponse{Choices: []llm.Choice{{Message: llm.Message{Role: llm.RoleAssistant, Content: "Hi"}}}}, nil)
+
+	agent := &Agent{Name: "TestAgent", Model: "test-model"}
+	req := llm.ChatCompletionRequest{Model: "test-model", Messages: []llm.Message{{Role: llm.RoleUser, Content: "Hello"}}}
+	_, err := sw.createChatCompletion(context.Background(), agent, req)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = sw.createChatCompletion(ctx, agent, req)
+	require.Error(t, err)
+	assert.Equal(t, CircuitClosed, config.CircuitBreaker.State(sw.provider, "test-model"))
+	mockClient.AssertNumberOfCalls(t, "CreateChatCompletion", 1)
+}
+
+// failingStream fails on its first Recv, as clients that connect lazily do
+type failingStream struct{ err error }
+
+func (s *failingStream) Recv() (llm.ChatCompletionResponse, error) {
+	return llm.ChatCompletionResponse{}, s.err
+}
+
+func (s *failingStream) Close() error { return nil }
+
+// TestStreamErrorsOpenCircuit tests that errors reported by a stream's first Recv count as failures
+func TestStreamErrorsOpenCircuit(t *testing.T) {
+	mockClient := new(MockLLM)
+	config := DefaultConfig()
+	config.CircuitBreaker = NewCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 1, CoolDown: time.Minute})
+	sw := NewSwarmWithCustomProvider(mockClient, config)
+
+	mockClient.On("CreateChatCompletionStream", mock.Anything, mock.Anything).
+		Return(&failingStream{err: errors.New("connection refused")}, nil)
+
+	agent := &Agent{Name: "TestAgent", Model: "test-model"}
+	req := llm.ChatCompletionRequest{Model: "test-model", Messages: []llm.Message{{Role: llm.RoleUser, Content: "Hello"}}}
+	stream, err := sw.createChatCompletionStream(context.Background(), agent, req)
+	require.NoError(t, err)
+	assert.Equal(t, CircuitClosed, config.CircuitBreaker.State(sw.provider, "test-model"))
+
+	_, err = stream.Recv()
+	require.Error(t, err)
+	require.NoError(t, stream.Close())
+	assert.Equal(t, CircuitOpen, config.CircuitBreaker.State(sw.provider, "test-model"))
+}
@@ -0,0 +1,152 @@
+package swarmgo
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mohan2020coder/swarmgo/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestClaudeCachingAndThinking tests the system parameter, cache breakpoints, thinking replay and cache usage
+func TestClaudeCachingAndThinking(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/messages", r.URL.Path)
+		assert.Equal(t, "test-key", r.Header.Get("x-api-key"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"msg_1","content":[
+			{"type":"thinking","thinking":"Need the tool","signature":"sig"},
+			{"type":"tool_use","id":"toolu_2","name":"lookup","input":{"q":"more"}}],
+			"stop_reason":"tool_use",
+			"usage":{"input_tokens":10,"output_tokens":5,"cache_read_input_tokens":100,"cache_creation_input_tokens":0}}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClientFromConfig(&ClientConfig{
+		Provider:   llm.Claude,
+		AuthToken:  "test-key",
+		BaseURL:    server.URL,
+		HTTPClient: server.Client(),
+		Options: map[string]interface{}{
+			OptionClaude: llm.ClaudeOptions{CacheSystemPrompt: true, CacheTools: true, ThinkingBudget: 2048},
+		},
+	})
+	require.NoError(t, err)
+
+	call := llm.ToolCall{ID: "toolu_1", Type: "function", Function: llm.ToolCallFunction{Name: "lookup", Arguments: `{"q":"swarm"}`}}
+	resp, err := client.CreateChatCompletion(context.Background(), llm.ChatCompletionRequest{
+		Model:       "claude-sonnet",
+		Temperature: 0.5,
+		MaxTokens:   1024,
+		Messages: []llm.Message{
+			{Role: llm.RoleSystem, Content: "Long instructions"},
+			{Role: llm.RoleSystem, Content: "More instructions"},
+			{Role: llm.RoleUser, Content: "find swarm"},
+			{Role: llm.RoleAssistant, ToolCalls: []llm.ToolCall{call}, Thinking: []llm.ThinkingBlock{{Text: "Use lookup", Signature: "abc"}}},
+			{Role: llm.RoleFunction, Name: "lookup", Content: "found", ToolCallID: "toolu_1"},
+		},
+		Tools: []llm.Tool{{Type: "function", Function: &llm.Function{Name: "lookup", Parameters: map[string]interface{}{"type": "object"}}}},
+	})
+	require.NoError(t, err)
+
+	system := body["system"].([]interface{})
+	require.Len(t, system, 2)
+	assert.Nil(t, system[0].(map[string]interface{})["cache_control"])
+	assert.Equal(t, map[string]interface{}{"type": "ephemeral"}, system[1].(map[string]interface{})["cache_control"])
+	tools := body["tools"].([]interface{})
+	assert.Equal(t, map[string]interface{}{"type": "ephemeral"}, tools[0].(map[string]interface{})["cache_control"])
+
+	assert.Equal(t, map[string]interface{}{"type": "enabled", "budget_tokens": float64(2048)}, body["thinking"])
+	assert.Greater(t, body["max_tokens"].(float64), float64(2048))
+	assert.NotContains(t, body, "temperature")
+
+	messages := body["messages"].([]interface{})
+	require.Len(t, messages, 3)
+	assistant := messages[1].(map[string]interface{})["content"].([]interface{})
+	assert.Equal(t, "thinking", assistant[0].(map[string]interface{})["type"])
+	assert.Equal(t, "abc", assistant[0].(map[string]interface{})["signature"])
+	assert.Equal(t, "tool_use", assistant[1].(map[string]interface{})["type"])
+	result := messages[2].(map[string]interface{})["content"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "tool_result", result["type"])
+	assert.Equal(t, "toolu_1", result["tool_use_id"])
+
+	choice := resp.Choices[0]
+	assert.Equal(t, "tool_calls", choice.FinishReason)
+	require.Len(t, choice.Message.ToolCalls, 1)
+	assert.JSONEq(t, `{"q":"more"}`, choice.Message.ToolCalls[0].Function.Arguments)
+	assert.Equal(t, []llm.ThinkingBlock{{Text: "Need the tool", Signature: "sig"}}, choice.Message.Thinking)
+	assert.Equal(t, "Need the tool", choice.Message.Reasoning)
+	assert.Equal(t, 100, resp.Usage.CacheReadTokens)
+	assert.Equal(t, 110, resp.Usage.PromptTokens)
+}
+
+// TestClaudeStreamsThinkingAndToolCalls tests that streamed thinking and tool use arrive as complete blocks
+func TestClaudeStreamsThinkingAndToolCalls(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, data := range []string{
+			`{"type":"message_start","message":{"id":"msg_1","usage":{"input_tokens":3,"cache_creation_input_tokens":50}}}`,
+			`{"type":"content_block_start","index":0,"content_block":{"type":"thinking","thinking":""}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"Let me "}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"check"}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"signature_delta","signature":"sig"}}`,
+			`{"type":"content_block_stop","index":0}`,
+			`{"type":"content_block_start","index":1,"content_block":{"type":"text","text":""}}`,
+			`{"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"Looking"}}`,
+			`{"type":"content_block_stop","index":1}`,
+			`{"type":"content_block_start","index":2,"content_block":{"type":"tool_use","id":"toolu_1","name":"lookup","input":{}}}`,
+			`{"type":"content_block_delta","index":2,"delta":{"type":"input_json_delta","partial_json":"{\"q\":"}}`,
+			`{"type":"content_block_delta","index":2,"delta":{"type":"input_json_delta","partial_json":"\"swarm\"}"}}`,
+			`{"type":"content_block_stop","index":2}`,
+			`{"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":9}}`,
+			`{"type":"message_stop"}`,
+		} {
+			var event struct{ Type string }
+			require.NoError(t, json.Unmarshal([]byte(data), &event))
+			_, _ = io.WriteString(w, "event: "+event.Type+"\ndata: "+data+"\n\n")
+		}
+	}))
+	defer server.Close()
+
+	client := llm.NewClaudeLLMWithConfig(llm.ClaudeConfig{APIKey: "test-key", BaseURL: server.URL})
+	stream, err := client.CreateChatCompletionStream(context.Background(), llm.ChatCompletionRequest{
+		Model:    "claude-sonnet",
+		Messages: []llm.Message{{Role: llm.RoleUser, Content: "find swarm"}},
+	})
+	require.NoError(t, err)
+	defer stream.Close()
+
+	var acc llm.StreamAccumulator
+	var fragments int
+	var last llm.ChatCompletionResponse
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		acc.Add(resp)
+		fragments += len(resp.Choices[0].Message.ToolCalls)
+		last = resp
+	}
+	message := acc.Message()
+
+	assert.Equal(t, "Looking", message.Content)
+	assert.Equal(t, []llm.ThinkingBlock{{Text: "Let me check", Signature: "sig"}}, message.Thinking)
+	assert.Equal(t, "Let me check", message.Reasoning)
+	assert.Equal(t, 3, fragments)
+	require.Len(t, message.ToolCalls, 1)
+	assert.Equal(t, "toolu_1", message.ToolCalls[0].ID)
+	assert.Equal(t, message.ToolCalls, last.Choices[0].AssembledToolCalls)
+	assert.JSONEq(t, `{"q":"swarm"}`, message.ToolCalls[0].Function.Arguments)
+	assert.Equal(t, "tool_calls", last.Choices[0].FinishReason)
+	assert.Equal(t, 50, last.Usage.CacheCreationTokens)
+	assert.Equal(t, 62, last.Usage.TotalTokens)
+}
@@ -26,6 +26,14 @@ func NewConcurrentSwarm(apiKey string, provider llm.LLMProvider) *ConcurrentSwar
 	}
 }
 
+// NewConcurrentSwarmWithConfig creates a new ConcurrentSwarm with custom configuration.
+// Set config.RateLimiter to keep concurrent agents within provider limits.
+func NewConcurrentSwarmWithConfig(apiKey string, provider llm.LLMProvider, config *Config) *ConcurrentSwarm {
+	return &ConcurrentSwarm{
+		Swarm: NewSwarmWithConfig(apiKey, provider, config),
+	}
+}
+
 // AgentConfig holds the configuration for a single agent execution
 type AgentConfig struct {
 	Agent            *Agent
@@ -1,7 +1,10 @@
 package swarmgo
 
 import (
+	"context"
+	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/mohan2020coder/swarmgo/llm"
 )
@@ -18,4 +21,188 @@ type ClientConfig struct {
 	HTTPClient         *http.Client
 	EmptyMessagesLimit uint
 	Options            map[string]interface{} // Additional provider-specific options
+
+	// TokenProvider supplies Azure AD bearer tokens for the Azure providers
+	TokenProvider func(ctx context.Context) (string, error)
+}
+
+// Option keys understood by NewClientFromConfig in ClientConfig.Options
+const (
+	OptionHeaders = "headers" // map[string]string of extra headers sent with every request
+	OptionGemini  = "gemini"  // llm.GeminiOptions with the default model and safety settings
+	OptionOllama  = "ollama"  // llm.OllamaOptions with keep-alive, model options and auto-pull
+	OptionClaude  = "claude"  // llm.ClaudeOptions with prompt caching and extended thinking
+)
+
+// NewClientFromConfig creates an LLM client for the provider described by config.
+// BaseURL, HTTPClient, OrgID, APIVersion, AssistantVersion and EmptyMessagesLimit
+// are passed to providers that support them, ModelMapperFunc rewrites the model
+// of every request. For the Azure providers ModelMapperFunc maps models to
+// deployment names and BaseURL is the resource endpoint or Cloudflare gateway URL.
+func NewClientFromConfig(config *ClientConfig) (llm.LLM, error) {
+	if config == nil {
+		return nil, fmt.Errorf("%w: nil client config", ErrInvalidProvider)
+	}
+
+	httpClient, err := config.httpClient()
+	if err != nil {
+		return nil, err
+	}
+
+	var client llm.LLM
+	switch config.Provider {
+	case llm.OpenAI:
+		client = llm.NewOpenAILLMWithConfig(config.openAIConfig(httpClient))
+
+	case llm.Gemini:
+		var opts []llm.GeminiOptions
+		if geminiOpts, ok := config.Options[OptionGemini].(llm.GeminiOptions); ok {
+			opts = append(opts, geminiOpts)
+		}
+		gemini, err := llm.NewGeminiLLMWithClient(config.AuthToken, config.BaseURL, httpClient, opts...)
+		if err != nil {
+			return nil, err
+		}
+		client = gemini
+
+	case llm.Claude:
+		claudeConfig := llm.ClaudeConfig{
+			APIKey:     config.AuthToken,
+			BaseURL:    config.BaseURL,
+			APIVersion: config.APIVersion,
+			HTTPClient: httpClient,
+		}
+		if claudeOpts, ok := config.Options[OptionClaude].(llm.ClaudeOptions); ok {
+			claudeConfig.Options = claudeOpts
+		}
+		client = llm.NewClaudeLLMWithConfig(claudeConfig)
+
+	case llm.Ollama:
+		host := config.BaseURL
+		if host == "" && (strings.HasPrefix(config.AuthToken, "http://") || strings.HasPrefix(config.AuthToken, "https://")) {
+			// Ollama needs no key, older callers pass the host in its place
+			host = config.AuthToken
+		}
+		var opts []llm.OllamaOptions
+		if ollamaOpts, ok := config.Options[OptionOllama].(llm.OllamaOptions); ok {
+			opts = append(opts, ollamaOpts)
+		}
+		ollama, err := llm.NewOllamaLLMWithClient(host, httpClient, opts...)
+		if err != nil {
+			return nil, err
+		}
+		client = ollama
+
+	case llm.DeepSeek:
+		client = llm.NewDeepSeekLLMWithClient(config.AuthToken, config.BaseURL, httpClient)
+
+	case llm.OpenRouter:
+		client = llm.NewOpenRouterLLMWithConfig(config.openAIConfig(httpClient))
+
+	case llm.Azure, llm.AzureAD, llm.CloudflareAzure:
+		// Azure resolves deployments itself, so the mapper is not applied twice
+		azure, err := llm.NewAzureOpenAILLM(llm.AzureConfig{
+			OpenAIConfig:     config.openAIConfig(httpClient),
+			APIType:          config.Provider,
+			DeploymentMapper: config.ModelMapperFunc,
+			TokenProvider:    config.TokenProvider,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return azure, nil
+
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrInvalidProvider, config.Provider)
+	}
+
+	if config.ModelMapperFunc != nil {
+		client = &modelMappingLLM{LLM: client, mapModel: config.ModelMapperFunc}
+	}
+	return client, nil
+}
+
+// NewEmbedderFromConfig creates an embedder for the provider described by config.
+// It returns ErrNoEmbeddings for providers without an embeddings API.
+func NewEmbedderFromConfig(config *ClientConfig) (llm.Embedder, error) {
+	client, err := NewClientFromConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	if mapped, ok := client.(*modelMappingLLM); ok {
+		embedder, ok := mapped.LLM.(llm.Embedder)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrNoEmbeddings, config.Provider)
+		}
+		return llm.EmbedderFunc(func(ctx context.Context, req llm.EmbeddingRequest) (llm.EmbeddingResponse, error) {
+			req.Model = mapped.mapModel(req.Model)
+			return embedder.CreateEmbeddings(ctx, req)
+		}), nil
+	}
+
+	embedder, ok := client.(llm.Embedder)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNoEmbeddings, config.Provider)
+	}
+	return embedder, nil
+}
+
+// openAIConfig returns the settings for OpenAI-compatible clients
+func (c *ClientConfig) openAIConfig(httpClient *http.Client) llm.OpenAIConfig {
+	return llm.OpenAIConfig{
+		APIKey:             c.AuthToken,
+		BaseURL:            c.BaseURL,
+		OrgID:              c.OrgID,
+		APIVersion:         c.APIVersion,
+		AssistantVersion:   c.AssistantVersion,
+		HTTPClient:         httpClient,
+		EmptyMessagesLimit: c.EmptyMessagesLimit,
+	}
+}
+
+// httpClient returns the HTTP client to use, wrapped to send any headers set in Options
+func (c *ClientConfig) httpClient() (*http.Client, error) {
+	raw, ok := c.Options[OptionHeaders]
+	if !ok {
+		return c.HTTPClient, nil
+	}
+
+	headers := make(map[string]string)
+	switch h := raw.(type) {
+	case map[string]string:
+		headers = h
+	case map[string]interface{}:
+		for key, value := range h {
+			str, ok := value.(string)
+			if !ok {
+				return nil, fmt.Errorf("option %q: header %s must be a string, got %T", OptionHeaders, key, value)
+			}
+			headers[key] = str
+		}
+	default:
+		return nil, fmt.Errorf("option %q must be a map of strings, got %T", OptionHeaders, raw)
+	}
+	return llm.HTTPClientWithHeaders(c.HTTPClient, headers), nil
+}
+
+// modelMappingLLM rewrites the model of every request before passing it on
+type modelMappingLLM struct {
+	llm.LLM
+	mapModel func(model string) string
+}
+
+func (m *modelMappingLLM) CreateChatCompletion(ctx context.Context, req llm.ChatCompletionRequest) (llm.ChatCompletionResponse, error) {
+	req.Model = m.mapModel(req.Model)
+	return m.LLM.CreateChatCompletion(ctx, req)
+}
+
+func (m *modelMappingLLM) CreateChatCompletionStream(ctx context.Context, req llm.ChatCompletionRequest) (llm.ChatCompletionStream, error) {
+	req.Model = m.mapModel(req.Model)
+	return m.LLM.CreateChatCompletionStream(ctx, req)
+}
+
+// requiresAuthToken reports whether a provider cannot be used without an API key
+func requiresAuthToken(provider llm.LLMProvider) bool {
+	return provider != llm.Ollama
 }
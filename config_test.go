@@ -0,0 +1,109 @@
+package swarmgo
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mohan2020coder/swarmgo/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestNewClientFromConfigHonorsSettings tests that base URL, headers, org and model mapping reach the wire
+func TestNewClientFromConfigHonorsSettings(t *testing.T) {
+	var gotModel, gotHeader, gotOrg, gotPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Model string `json:"model"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		gotModel = body.Model
+		gotHeader = r.Header.Get("X-Proxy-Token")
+		gotOrg = r.Header.Get("OpenAI-Organization")
+		gotPath = r.URL.Path
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"1","choices":[{"index":0,"message":{"role":"assistant","content":"hi"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClientFromConfig(&ClientConfig{
+		Provider:   llm.OpenAI,
+		AuthToken:  "test-key",
+		BaseURL:    server.URL + "/v1",
+		OrgID:      "org-123",
+		HTTPClient: server.Client(),
+		ModelMapperFunc: func(model string) string {
+			return "deployment-" + model
+		},
+		Options: map[string]interface{}{
+			OptionHeaders: map[string]interface{}{"X-Proxy-Token": "secret"},
+		},
+	})
+	require.NoError(t, err)
+
+	resp, err := client.CreateChatCompletion(context.Background(), llm.ChatCompletionRequest{
+		Model:    "gpt-4o",
+		Messages: []llm.Message{{Role: llm.RoleUser, Content: "Hello"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "hi", resp.Choices[0].Message.Content)
+	assert.Equal(t, "deployment-gpt-4o", gotModel)
+	assert.Equal(t, "secret", gotHeader)
+	assert.Equal(t, "org-123", gotOrg)
+	assert.Equal(t, "/v1/chat/completions", gotPath)
+}
+
+// TestNewClientFromConfigRejectsInvalidOptions tests validation of provider options
+func TestNewClientFromConfigRejectsInvalidOptions(t *testing.T) {
+	_, err := NewClientFromConfig(&ClientConfig{
+		Provider: llm.OpenAI,
+		Options:  map[string]interface{}{OptionHeaders: "not-a-map"},
+	})
+	assert.Error(t, err)
+
+	_, err = NewClientFromConfig(&ClientConfig{Provider: "UNKNOWN"})
+	assert.ErrorIs(t, err, ErrInvalidProvider)
+}
+
+// TestNewClientFromConfigAzureAD tests deployment mapping, API version and AAD tokens for Azure
+func TestNewClientFromConfigAzureAD(t *testing.T) {
+	var gotPath, gotVersion, gotAuth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotVersion = r.URL.Query().Get("api-version")
+		gotAuth = r.Header.Get("Authorization")
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"1","choices":[{"index":0,"message":{"role":"assistant","content":"hi"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClientFromConfig(&ClientConfig{
+		Provider:   llm.AzureAD,
+		BaseURL:    server.URL,
+		APIVersion: "2024-10-21",
+		ModelMapperFunc: func(model string) string {
+			return map[string]string{"gpt-4o": "prod-gpt4o"}[model]
+		},
+		TokenProvider: func(ctx context.Context) (string, error) {
+			return "aad-token", nil
+		},
+	})
+	require.NoError(t, err)
+
+	_, err = client.CreateChatCompletion(context.Background(), llm.ChatCompletionRequest{
+		Model:    "gpt-4o",
+		Messages: []llm.Message{{Role: llm.RoleUser, Content: "Hello"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "/openai/deployments/prod-gpt4o/chat/completions", gotPath)
+	assert.Equal(t, "2024-10-21", gotVersion)
+	assert.Equal(t, "Bearer aad-token", gotAuth)
+
+	_, err = NewClientFromConfig(&ClientConfig{Provider: llm.Azure, AuthToken: "key"})
+	assert.Error(t, err, "a base URL is required")
+}
@@ -0,0 +1,110 @@
+package swarmgo
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mohan2020coder/swarmgo/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testPNG = []byte("\x89PNG\r\n\x1a\n0000")
+
+// captureRequest starts a server that records the request body and answers with reply
+func captureRequest(t *testing.T, body *map[string]interface{}, reply string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(body))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, reply)
+	}))
+}
+
+func multimodalMessages() []llm.Message {
+	call := llm.ToolCall{ID: "call_1", Type: "function", Function: llm.ToolCallFunction{Name: "render", Arguments: `{}`}}
+	return []llm.Message{
+		{Role: llm.RoleUser, Content: "What is in this diagram?", Parts: []llm.ContentPart{llm.ImagePart(testPNG, "")}},
+		{Role: llm.RoleAssistant, ToolCalls: []llm.ToolCall{call}},
+		{Role: llm.RoleFunction, Name: "render", ToolCallID: "call_1", Content: "rendered", Parts: []llm.ContentPart{llm.ImageURLPart("https://example.com/chart.png")}},
+	}
+}
+
+// TestOpenAIMultimodalContent tests image parts in user messages and tool results
+func TestOpenAIMultimodalContent(t *testing.T) {
+	var body map[string]interface{}
+	server := captureRequest(t, &body, `{"id":"1","choices":[{"index":0,"message":{"role":"assistant","content":"a box"},"finish_reason":"stop"}]}`)
+	defer server.Close()
+
+	client := llm.NewOpenAILLMWithConfig(llm.OpenAIConfig{APIKey: "test-key", BaseURL: server.URL})
+	_, err := client.CreateChatCompletion(context.Background(), llm.ChatCompletionRequest{Model: "gpt-4o", Messages: multimodalMessages()})
+	require.NoError(t, err)
+
+	messages := body["messages"].([]interface{})
+	require.Len(t, messages, 4)
+	user := messages[0].(map[string]interface{})["content"].([]interface{})
+	assert.Equal(t, "What is in this diagram?", user[0].(map[string]interface{})["text"])
+	image := user[1].(map[string]interface{})["image_url"].(map[string]interface{})
+	assert.Equal(t, "data:image/png;base64,"+base64.StdEncoding.EncodeToString(testPNG), image["url"])
+
+	tool := messages[2].(map[string]interface{})
+	assert.Equal(t, "tool", tool["role"])
+	assert.Equal(t, "rendered", tool["content"])
+	attachments := messages[3].(map[string]interface{})
+	assert.Equal(t, "user", attachments["role"])
+	parts := attachments["content"].([]interface{})
+	assert.Equal(t, "https://example.com/chart.png", parts[1].(map[string]interface{})["image_url"].(map[string]interface{})["url"])
+}
+
+// TestClaudeMultimodalContent tests image and document blocks, including inside tool results
+func TestClaudeMultimodalContent(t *testing.T) {
+	var body map[string]interface{}
+	server := captureRequest(t, &body, `{"id":"msg_1","content":[{"type":"text","text":"ok"}],"stop_reason":"end_turn","usage":{}}`)
+	defer server.Close()
+
+	messages := multimodalMessages()
+	messages[0].Parts = append(messages[0].Parts, llm.FilePart([]byte("%PDF-1.4"), "application/pdf", "spec.pdf"))
+
+	client := llm.NewClaudeLLMWithConfig(llm.ClaudeConfig{APIKey: "test-key", BaseURL: server.URL})
+	_, err := client.CreateChatCompletion(context.Background(), llm.ChatCompletionRequest{Model: "claude-sonnet", Messages: messages})
+	require.NoError(t, err)
+
+	claudeMessages := body["messages"].([]interface{})
+	user := claudeMessages[0].(map[string]interface{})["content"].([]interface{})
+	require.Len(t, user, 3)
+	image := user[1].(map[string]interface{})
+	assert.Equal(t, "image", image["type"])
+	assert.Equal(t, map[string]interface{}{"type": "base64", "media_type": "image/png", "data": base64.StdEncoding.EncodeToString(testPNG)}, image["source"])
+	document := user[2].(map[string]interface{})
+	assert.Equal(t, "document", document["type"])
+	assert.Equal(t, "spec.pdf", document["title"])
+
+	result := claudeMessages[2].(map[string]interface{})["content"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "tool_result", result["type"])
+	content := result["content"].([]interface{})
+	assert.Equal(t, map[string]interface{}{"type": "url", "url": "https://example.com/chart.png"}, content[1].(map[string]interface{})["source"])
+}
+
+// TestToolResultImageParts tests that content parts returned by a tool reach the function result message
+func TestToolResultImageParts(t *testing.T) {
+	agent := &Agent{Functions: []AgentFunction{{
+		Name: "screenshot",
+		Function: func(args map[string]interface{}, contextVariables map[string]interface{}) Result {
+			return Result{Data: []llm.ContentPart{llm.TextPart("login page"), llm.ImagePart(testPNG, "image/png")}}
+		},
+	}}}
+
+	swarm := &Swarm{}
+	call := llm.ToolCall{ID: "call_1", Type: "function", Function: llm.ToolCallFunction{Name: "screenshot", Arguments: `{}`}}
+	resp, err := swarm.handleToolCall(context.Background(), &call, agent, nil, false)
+	require.NoError(t, err)
+
+	require.Len(t, resp.Messages, 1)
+	assert.Equal(t, "login page", resp.Messages[0].Content)
+	require.Len(t, resp.Messages[0].Parts, 1)
+	assert.Equal(t, llm.ContentPartImage, resp.Messages[0].Parts[0].Type)
+}
@@ -10,6 +10,7 @@ import (
 	"os"
 	"os/signal"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -25,7 +26,7 @@ var (
 // DemoLoopConfig contains configuration options for the demo loop
 type DemoLoopConfig struct {
 	Timeout             time.Duration // Timeout for each agent execution
-	MaxHistoryMessages  int           // Maximum number of messages to keep in history
+	MaxHistoryMessages  int           // Maximum number of history messages sent to the agent
 	MaxInputLength      int           // Maximum length of user input
 	ShowFunctionResults bool          // Whether to display function results
 	ColorOutput         bool          // Whether to use color in output
@@ -83,18 +84,31 @@ func RunDemoLoopWithConfig(client *Swarm, agent *Agent, config *DemoLoopConfig)
 	ctx, cancel := context.WithCancel(baseCtx)
 	defer cancel()
 
-	// Set up signal handling for graceful shutdown
+	// Set up signal handling: an interrupt stops the running agent, otherwise it shuts down
+	var runningMu sync.Mutex
+	var running *StopHandle
 	signalChan := make(chan os.Signal, 1)
 	signal.Notify(signalChan, os.Interrupt, syscall.SIGTERM)
 	go func() {
-		<-signalChan
-		fmt.Println("\nReceived interrupt signal, shutting down...")
-		cancel()
+		for sig := range signalChan {
+			runningMu.Lock()
+			stop := running
+			runningMu.Unlock()
+			if sig == os.Interrupt && stop != nil && !stop.Stopped() {
+				fmt.Println("\nStopping...")
+				stop.Stop()
+				continue
+			}
+			fmt.Println("\nReceived interrupt signal, shutting down...")
+			cancel()
+			return
+		}
 	}()
 
 	// Print a starting message to the console
 	printColoredText(config.ColorOutput, "\n==== Starting SwarmGo CLI Demo ====\n", "cyan")
-	fmt.Printf("Agent: %s\nModel: %s\n\n", agent.Name, agent.Model)
+	fmt.Printf("Agent: %s\nModel: %s\n", agent.Name, agent.Model)
+	fmt.Printf("Commands: /edit <message> replaces your last message, /retry regenerates the last answer\n\n")
 	if config.Debug {
 		fmt.Printf("Debug mode: ON\n")
 		if agent.Functions != nil {
@@ -106,14 +120,15 @@ func RunDemoLoopWithConfig(client *Swarm, agent *Agent, config *DemoLoopConfig)
 		fmt.Println()
 	}
 
-	// Initialize a slice to store chat messages
-	messages := []llm.Message{}
+	// The session keeps the history and the active agent between runs
+	session := NewSession(client, agent).
+		WithMaxTurns(5).
+		WithHistoryLimit(config.MaxHistoryMessages).
+		WithDebug(config.Debug)
 
 	// Create a new reader to read user input from the standard input
 	reader := bufio.NewReader(os.Stdin)
 
-	activeAgent := agent
-
 	// Main interaction loop
 	for {
 		select {
@@ -153,52 +168,39 @@ func RunDemoLoopWithConfig(client *Swarm, agent *Agent, config *DemoLoopConfig)
 				continue
 			}
 
-			// Append the user's input as a new message to the messages slice
-			messages = append(messages, llm.Message{
-				Role:    llm.RoleUser,
-				Content: userInput,
-			})
-
-			// Trim history if it exceeds the maximum
-			if config.MaxHistoryMessages > 0 && len(messages) > config.MaxHistoryMessages {
-				// Keep system message if it exists, plus recent messages
-				var systemMsg *llm.Message
-				for _, msg := range messages {
-					if msg.Role == llm.RoleSystem {
-						systemMsg = &msg
-						break
-					}
-				}
-
-				newMessages := make([]llm.Message, 0, config.MaxHistoryMessages+1)
-				if systemMsg != nil {
-					newMessages = append(newMessages, *systemMsg)
-				}
-
-				// Add recent messages
-				startIdx := len(messages) - config.MaxHistoryMessages
-				if startIdx < 0 {
-					startIdx = 0
+			// Edits and retries run on a new branch, keeping the original exchange
+			send := func(ctx context.Context) (Response, error) {
+				return session.Send(ctx, userInput)
+			}
+			if userInput == "/retry" {
+				send = func(ctx context.Context) (Response, error) {
+					return session.Regenerate(ctx)
 				}
-
-				newMessages = append(newMessages, messages[startIdx:]...)
-				messages = newMessages
-
-				if config.Debug {
-					fmt.Printf("History trimmed to %d messages\n", len(messages))
+			} else if text, ok := strings.CutPrefix(userInput, "/edit "); ok {
+				send = func(ctx context.Context) (Response, error) {
+					return session.Edit(ctx, strings.TrimSpace(text))
 				}
 			}
 
 			// Create execution context with timeout
 			execCtx, execCancel := context.WithTimeout(ctx, config.Timeout)
+			stop := NewStopHandle()
+			execCtx = WithStopHandle(execCtx, stop)
+			runningMu.Lock()
+			running = stop
+			runningMu.Unlock()
 
 			// Show thinking indicator
 			fmt.Print("Thinking...")
 
 			// Execute agent
 			startTime := time.Now()
-			response, err := client.Run(execCtx, activeAgent, messages, nil, "", false, config.Debug, 5, true)
+			activeAgent := session.Agent()
+			response, err := send(execCtx)
 			execCancel() // Always cancel context
+			runningMu.Lock()
+			running = nil
+			runningMu.Unlock()
 
 			// Clear thinking indicator
 			fmt.Print("\r          \r")
@@ -212,13 +214,12 @@ func RunDemoLoopWithConfig(client *Swarm, agent *Agent, config *DemoLoopConfig)
 						fmt.Sprintf("Request timed out after %v\n", config.Timeout), "yellow")
 				}
 
-				continue
+				// Interrupted runs still return what was completed
+				if !errors.Is(err, ErrInterrupted) {
+					continue
+				}
 			}
 
-			// Process the response and print it to the console
-			var lastAssistantMessage llm.Message
-			var functionMessages []llm.Message
-
 			// Display response messages
 			for _, msg := range response.Messages {
 				switch msg.Role {
@@ -231,7 +232,6 @@ func RunDemoLoopWithConfig(client *Swarm, agent *Agent, config *DemoLoopConfig)
 
 						printColoredText(config.ColorOutput, fmt.Sprintf("%s: ", name), "blue")
 						fmt.Println(msg.Content)
-						lastAssistantMessage = msg
 					}
 				case llm.RoleFunction:
 					if config.ShowFunctionResults {
@@ -239,9 +239,11 @@ func RunDemoLoopWithConfig(client *Swarm, agent *Agent, config *DemoLoopConfig)
 							fmt.Sprintf("%s function result: ", msg.Name), "magenta")
 						fmt.Println(msg.Content)
 					}
-					functionMessages = append(functionMessages, msg)
 				}
 			}
+			if response.Interrupted {
+				printColoredText(config.ColorOutput, "[interrupted]\n", "yellow")
+			}
 
 			// Display timing information in debug mode
 			if config.Debug {
@@ -256,22 +258,15 @@ func RunDemoLoopWithConfig(client *Swarm, agent *Agent, config *DemoLoopConfig)
 				}
 			}
 
-			// Add function results to history first, then the assistant message
-			messages = append(messages, functionMessages...)
-			if lastAssistantMessage.Content != "" {
-				messages = append(messages, lastAssistantMessage)
-			}
-
 			// Handle agent transfer
 			if response.Agent != nil && response.Agent.Name != activeAgent.Name {
 				printColoredText(config.ColorOutput,
 					fmt.Sprintf("\nTransferring conversation to %s.\n\n", response.Agent.Name), "yellow")
-				activeAgent = response.Agent
 			}
 
 			// Save conversation history if enabled
 			if config.SaveHistory && config.HistoryFile != "" {
-				saveConversationHistory(messages, config.HistoryFile)
+				saveConversationHistory(session.Messages(), config.HistoryFile)
 			}
 		}
 	}
@@ -0,0 +1,102 @@
+package swarmgo
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mohan2020coder/swarmgo/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func cosine(a, b []float32) float32 {
+	var dot float32
+	for i := range a {
+		dot += a[i] * b[i]
+	}
+	return dot
+}
+
+// TestOpenAIEmbeddings tests the embeddings request and that results are ordered by index
+func TestOpenAIEmbeddings(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/embeddings", r.URL.Path)
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		_, _ = w.Write([]byte(`{"model":"text-embedding-3-small","data":[
+			{"index":1,"embedding":[0,1]},
+			{"index":0,"embedding":[1,0]}],
+			"usage":{"prompt_tokens":4,"total_tokens":4}}`))
+	}))
+	defer server.Close()
+
+	embedder, err := NewEmbedderFromConfig(&ClientConfig{Provider: llm.OpenAI, AuthToken: "test-key", BaseURL: server.URL})
+	require.NoError(t, err)
+
+	resp, err := embedder.CreateEmbeddings(context.Background(), llm.EmbeddingRequest{
+		Model:      "text-embedding-3-small",
+		Input:      []string{"first", "second"},
+		Dimensions: 2,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, float64(2), body["dimensions"])
+	assert.Equal(t, [][]float32{{1, 0}, {0, 1}}, resp.Embeddings)
+	assert.Equal(t, 2, resp.Dimensions())
+	assert.Equal(t, 4, resp.Usage.TotalTokens)
+}
+
+// TestOllamaEmbeddings tests the Ollama embed endpoint
+func TestOllamaEmbeddings(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/embed", r.URL.Path)
+		_, _ = w.Write([]byte(`{"model":"nomic-embed-text","embeddings":[[3,4,0]],"prompt_eval_count":2}`))
+	}))
+	defer server.Close()
+
+	embedder, err := NewEmbedderFromConfig(&ClientConfig{Provider: llm.Ollama, BaseURL: server.URL})
+	require.NoError(t, err)
+
+	resp, err := embedder.CreateEmbeddings(context.Background(), llm.EmbeddingRequest{Model: "nomic-embed-text", Input: []string{"hello"}, Dimensions: 2})
+	require.NoError(t, err)
+	assert.InDeltaSlice(t, []float32{0.6, 0.8}, resp.Embeddings[0], 1e-6)
+	assert.Equal(t, 2, resp.Usage.PromptTokens)
+}
+
+// TestEmbedBatchesSplitsInput tests batching and usage aggregation
+func TestEmbedBatchesSplitsInput(t *testing.T) {
+	fake := llm.NewFakeEmbedder(8)
+	var sizes []int
+	counting := llm.EmbedderFunc(func(ctx context.Context, req llm.EmbeddingRequest) (llm.EmbeddingResponse, error) {
+		sizes = append(sizes, len(req.Input))
+		return fake.CreateEmbeddings(ctx, req)
+	})
+
+	resp, err := llm.EmbedBatches(context.Background(), counting, llm.EmbeddingRequest{Input: []string{"a", "b", "c", "d", "e"}}, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []int{2, 2, 1}, sizes)
+	assert.Len(t, resp.Embeddings, 5)
+	assert.Equal(t, 5, resp.Usage.PromptTokens)
+}
+
+// TestFakeEmbedder tests that the fake embedder is deterministic and ranks shared words higher
+func TestFakeEmbedder(t *testing.T) {
+	fake := llm.NewFakeEmbedder(0)
+	resp, err := fake.CreateEmbeddings(context.Background(), llm.EmbeddingRequest{
+		Input: []string{"the cat sat on the mat", "a cat on a mat", "quarterly revenue report", "the cat sat on the mat"},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, 64, resp.Dimensions())
+	assert.Equal(t, resp.Embeddings[0], resp.Embeddings[3])
+	assert.Greater(t, cosine(resp.Embeddings[0], resp.Embeddings[1]), cosine(resp.Embeddings[0], resp.Embeddings[2]))
+}
+
+// TestEmbedderUnsupportedProvider tests that providers without embeddings are reported
+func TestEmbedderUnsupportedProvider(t *testing.T) {
+	_, err := NewEmbedderFromConfig(&ClientConfig{Provider: llm.Claude, AuthToken: "test-key"})
+	assert.ErrorIs(t, err, ErrNoEmbeddings)
+}
@@ -0,0 +1,134 @@
+package swarmgo
+
+import (
+	"context"
+	"iter"
+
+	"github.com/google/uuid"
+	"github.com/mohan2020coder/swarmgo/llm"
+)
+
+// StreamEventType identifies the kind of a StreamEvent
+type StreamEventType string
+
+const (
+	EventTextDelta        StreamEventType = "text_delta"         // Text is the new content
+	EventReasoningDelta   StreamEventType = "reasoning_delta"    // Text is the new reasoning
+	EventToolCallDelta    StreamEventType = "tool_call_delta"    // ToolCall is a fragment, merged by its Index
+	EventToolCallComplete StreamEventType = "tool_call_complete" // ToolCall is assembled and about to run
+	EventToolResult       StreamEventType = "tool_result"        // ToolResult and Message hold the function result
+	EventHandoff          StreamEventType = "handoff"            // Handoff is the agent taking over from Agent
+	EventUsage            StreamEventType = "usage"              // Usage of the turn's response
+	EventTurnEnd          StreamEventType = "turn_end"           // Message is the turn's assistant message
+	EventError            StreamEventType = "error"              // Err ended the run, Response is set for interrupted runs
+	EventDone             StreamEventType = "done"               // Response is the result of the run
+)
+
+// StreamEvent is a typed event of a streamed agent run
+type StreamEvent struct {
+	Type  StreamEventType
+	RunID string // Identifies the run the event belongs to
+	Agent string // Name of the active agent
+	Turn  int    // Model response the event belongs to, starting at 1
+
+	Text       string
+	ToolCall   *llm.ToolCall
+	ToolResult *ToolResult
+	Message    *llm.Message
+	Handoff    *Agent
+	Usage      *llm.Usage
+	Response   *Response
+	Err        error
+}
+
+// RunStream runs the agent loop with streamed responses and yields its events.
+// The run ends with an EventDone or EventError event, breaking out of the loop
+// cancels it. Without executeTools the run ends at the first response with tool
+// calls, which are left to the caller.
+func (s *Swarm) RunStream(
+	ctx context.Context,
+	agent *Agent,
+	messages []llm.Message,
+	contextVariables map[string]interface{},
+	modelOverride string,
+	debug bool,
+	maxTurns int,
+	executeTools bool,
+) iter.Seq[StreamEvent] {
+	return func(yield func(StreamEvent) bool) {
+		runCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		runID := uuid.New().String()
+		stopped := false
+		resp, err := s.runLoop(runCtx, agent, messages, contextVariables, runOptions{
+			runID:         runID,
+			modelOverride: modelOverride,
+			stream:        true,
+			debug:         debug,
+			maxTurns:      maxTurns,
+			executeTools:  executeTools,
+			emit: func(event StreamEvent) {
+				if stopped {
+					return
+				}
+				if !yield(event) {
+					stopped = true
+					cancel()
+				}
+			},
+		})
+		if err != nil || stopped {
+			return
+		}
+		event := StreamEvent{Type: EventDone, RunID: runID, Response: &resp}
+		if resp.Agent != nil {
+			event.Agent = resp.Agent.Name
+		}
+		yield(event)
+	}
+}
+
+// StreamEvents runs the agent loop like RunStream and delivers its events on a
+// channel, which is closed when the run ends
+func (s *Swarm) StreamEvents(
+	ctx context.Context,
+	agent *Agent,
+	messages []llm.Message,
+	contextVariables map[string]interface{},
+	modelOverride string,
+	debug bool,
+	maxTurns int,
+	executeTools bool,
+) <-chan StreamEvent {
+	events := make(chan StreamEvent)
+	go func() {
+		defer close(events)
+		for event := range s.RunStream(ctx, agent, messages, contextVariables, modelOverride, debug, maxTurns, executeTools) {
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events
+}
+
+// handlerEvents adapts a StreamHandler to stream events
+func handlerEvents(handler StreamHandler) func(StreamEvent) {
+	return func(event StreamEvent) {
+		switch event.Type {
+		case EventTextDelta:
+			handler.OnToken(event.Text)
+		case EventReasoningDelta:
+			if reasoningHandler, ok := handler.(ReasoningStreamHandler); ok {
+				reasoningHandler.OnReasoning(event.Text)
+			}
+		case EventToolCallComplete:
+			handler.OnToolCall(*event.ToolCall)
+		case EventError:
+			handler.OnError(event.Err)
+		}
+	}
+}
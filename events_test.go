@@ -0,0 +1,91 @@
+package swarmgo
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mohan2020coder/swarmgo/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func handoffAgents() (*Agent, *Agent) {
+	billing := &Agent{Name: "Billing", Model: "model"}
+	triage := &Agent{Name: "Triage", Model: "model", Functions: []AgentFunction{
+		{Name: "transfer", Function: func(args map[string]interface{}, _ map[string]interface{}) Result {
+			return Result{Success: true, Data: "transferred", Agent: billing}
+		}},
+	}}
+	return triage, billing
+}
+
+func handoffScript() *scriptedLLM {
+	return &scriptedLLM{responses: [][]llm.ChatCompletionResponse{
+		{textDelta("One moment."), toolDelta(0, "call_1", "transfer", "{}")},
+		{textDelta("Billing "), textDelta("here."), {Usage: llm.Usage{PromptTokens: 3, CompletionTokens: 2, TotalTokens: 5}}},
+	}}
+}
+
+// TestRunStreamEvents tests the event sequence of a run with a tool call and a handoff
+func TestRunStreamEvents(t *testing.T) {
+	triage, billing := handoffAgents()
+	swarm := NewSwarmWithCustomProvider(handoffScript(), nil)
+
+	var events []StreamEvent
+	for event := range swarm.RunStream(context.Background(), triage, []llm.Message{{Role: llm.RoleUser, Content: "Refund"}}, nil, "", false, 5, true) {
+		events = append(events, event)
+	}
+
+	var types []StreamEventType
+	for _, event := range events {
+		types = append(types, event.Type)
+		assert.Equal(t, events[0].RunID, event.RunID)
+	}
+	assert.NotEmpty(t, events[0].RunID)
+	assert.Equal(t, []StreamEventType{
+		EventTextDelta, EventToolCallDelta, EventToolCallComplete, EventUsage, EventTurnEnd, EventToolResult, EventHandoff,
+		EventTextDelta, EventTextDelta, EventUsage, EventTurnEnd, EventDone,
+	}, types)
+
+	assert.Equal(t, "Triage", events[0].Agent)
+	assert.Equal(t, 1, events[0].Turn)
+	assert.Equal(t, "transferred", events[5].Message.Content)
+	assert.Equal(t, billing, events[6].Handoff)
+	assert.Equal(t, "Triage", events[6].Agent)
+	assert.Equal(t, "Billing", events[7].Agent)
+	assert.Equal(t, 2, events[7].Turn)
+	assert.Equal(t, 5, events[9].Usage.TotalTokens)
+
+	done := events[len(events)-1]
+	require.NotNil(t, done.Response)
+	assert.Equal(t, billing, done.Response.Agent)
+	assert.Equal(t, "Billing here.", done.Response.Messages[len(done.Response.Messages)-1].Content)
+}
+
+// TestRunStreamBreakCancelsRun tests that leaving the loop early stops the run
+func TestRunStreamBreakCancelsRun(t *testing.T) {
+	triage, _ := handoffAgents()
+	client := handoffScript()
+	swarm := NewSwarmWithCustomProvider(client, nil)
+
+	for event := range swarm.RunStream(context.Background(), triage, []llm.Message{{Role: llm.RoleUser, Content: "Refund"}}, nil, "", false, 5, true) {
+		if event.Type == EventToolCallComplete {
+			break
+		}
+	}
+	assert.Len(t, client.requests, 1)
+}
+
+// TestStreamEventsChannel tests the channel form and errors as events
+func TestStreamEventsChannel(t *testing.T) {
+	triage, _ := handoffAgents()
+	swarm := NewSwarmWithCustomProvider(&scriptedLLM{}, nil)
+
+	var events []StreamEvent
+	for event := range swarm.StreamEvents(context.Background(), triage, []llm.Message{{Role: llm.RoleUser, Content: "Refund"}}, nil, "", false, 5, true) {
+		events = append(events, event)
+	}
+	require.Len(t, events, 1)
+	assert.Equal(t, EventError, events[0].Type)
+	assert.ErrorContains(t, events[0].Err, "no more scripted responses")
+}
@@ -229,7 +229,7 @@ Wait for each tool's result before proceeding.`,
 	fmt.Printf("Debug: Reading file: %s\n", readmePath)
 
 	// Start streaming analysis
-	if err := swarm.StreamingResponse(ctx, agent, messages, nil, "", handler, false); err != nil {
+	if _, err := swarm.StreamingResponse(ctx, agent, messages, nil, "", handler, false, 5); err != nil {
 		log.Fatalf("Error in streaming response: %v", err)
 	}
 }
@@ -12,8 +12,9 @@ import (
 	"github.com/mohan2020coder/swarmgo/llm"
 )
 
-// createMemoryAgent creates an agent with memory capabilities and custom functions
-func createMemoryAgent() *swarmgo.Agent {
+// createMemoryAgent creates an agent with memory capabilities and custom functions.
+// Memories are stored with ctx.
+func createMemoryAgent(ctx context.Context) *swarmgo.Agent {
 	agent := swarmgo.NewAgent("MemoryAgent", "gpt-4", llm.OpenAI)
 	agent.Instructions = `You are a helpful assistant with memory capabilities. 
 	You can remember our conversations and use that information in future responses.
@@ -50,7 +51,9 @@ func createMemoryAgent() *swarmgo.Agent {
 				}
 
 				// Add the memory to the agent's memory store
-				agent.Memory.AddMemory(memory)
+				if err := agent.Memory.AddMemoryContext(ctx, memory); err != nil {
+					return swarmgo.Result{Error: fmt.Errorf("failed to store fact: %w", err)}
+				}
 
 				return swarmgo.Result{
 					Data: fmt.Sprintf("Stored fact: %s (importance: %.2f)", content, importance),
@@ -118,8 +121,9 @@ func main() {
 	}
 
 	// Create a new swarm and memory-enabled agent
+	ctx := context.Background()
 	client := swarmgo.NewSwarm(apiKey, llm.OpenAI)
-	agent := createMemoryAgent()
+	agent := createMemoryAgent(ctx)
 
 	// Example conversation demonstrating memory capabilities
 	conversations := []string{
@@ -130,8 +134,6 @@ func main() {
 		"What are all the facts you remember about me?",
 	}
 
-	ctx := context.Background()
-
 	fmt.Println("Starting memory demonstration...")
 	fmt.Println("=================================")
 
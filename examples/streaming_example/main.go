@@ -122,7 +122,7 @@ func main() {
 
 	// Start streaming with context
 	ctx := context.Background()
-	err := client.StreamingResponse(ctx, agent, messages, nil, "", handler, true)
+	_, err := client.StreamingResponse(ctx, agent, messages, nil, "", handler, true, 5)
 	if err != nil {
 		log.Fatalf("Error: %v", err)
 	}
@@ -93,7 +93,7 @@ func main() {
 	}
 
 	// Run the agent with tool execution enabled
-	response, err := client.Run(ctx, mathAgent, messages, nil, "", false, true, 1, true)
+	response, err := client.Run(ctx, mathAgent, messages, nil, "", false, true, 5, true)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 		return
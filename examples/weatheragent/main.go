@@ -203,7 +203,7 @@ Remember, you have FULL ACCESS to both weather data and email functionality. Use
 		fmt.Print("Thinking...")
 
 		// Execute agent with our context variables
-		response, err := client.Run(ctx, weatherAgent, messages, contextVariables, "", false, false, 1, true)
+		response, err := client.Run(ctx, weatherAgent, messages, contextVariables, "", false, false, 5, true)
 
 		// Clear indicator
 		fmt.Print("\r           \r")
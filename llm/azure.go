@@ -0,0 +1,114 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// defaultAzureAPIVersion is the Azure OpenAI API version used when none is configured
+const defaultAzureAPIVersion = "2024-06-01"
+
+// AzureConfig holds the settings of an Azure OpenAI client
+type AzureConfig struct {
+	OpenAIConfig
+
+	// APIType selects the authentication and addressing scheme: Azure (api-key
+	// header), AzureAD (bearer token) or CloudflareAzure (AI gateway URL).
+	APIType LLMProvider
+
+	// DeploymentMapper maps model names to deployment names. When nil, dots and
+	// colons are stripped from the model name ("gpt-3.5-turbo" -> "gpt-35-turbo").
+	DeploymentMapper func(model string) string
+
+	// TokenProvider returns an Azure AD bearer token for each request. When set,
+	// requests authenticate with the token instead of the API key.
+	TokenProvider func(ctx context.Context) (string, error)
+}
+
+// defaultDeploymentPattern matches the characters Azure does not allow in deployment names
+var defaultDeploymentPattern = regexp.MustCompile(`[.:]`)
+
+// tokenTransport sets a bearer token obtained per request
+type tokenTransport struct {
+	base          http.RoundTripper
+	tokenProvider func(ctx context.Context) (string, error)
+}
+
+func (t *tokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.tokenProvider(req.Context())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Azure AD token: %w", err)
+	}
+
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Del(openai.AzureAPIKeyHeader)
+	return t.base.RoundTrip(req)
+}
+
+// NewAzureOpenAILLM creates an OpenAI LLM client for Azure OpenAI, Azure AD or
+// a Cloudflare AI gateway in front of Azure
+func NewAzureOpenAILLM(config AzureConfig) (*OpenAILLM, error) {
+	if config.BaseURL == "" {
+		return nil, errors.New("azure: base URL is required")
+	}
+	if config.APIKey == "" && config.TokenProvider == nil {
+		return nil, errors.New("azure: an API key or token provider is required")
+	}
+
+	clientConfig := openai.DefaultAzureConfig(config.APIKey, config.BaseURL)
+	switch config.APIType {
+	case Azure, "":
+		if config.TokenProvider != nil {
+			clientConfig.APIType = openai.APITypeAzureAD
+		}
+	case AzureAD:
+		clientConfig.APIType = openai.APITypeAzureAD
+	case CloudflareAzure:
+		clientConfig.APIType = openai.APITypeCloudflareAzure
+	default:
+		return nil, fmt.Errorf("azure: unsupported API type %s", config.APIType)
+	}
+
+	clientConfig.APIVersion = defaultAzureAPIVersion
+	if config.APIVersion != "" {
+		clientConfig.APIVersion = config.APIVersion
+	}
+	clientConfig.AzureModelMapperFunc = func(model string) string {
+		return defaultDeploymentPattern.ReplaceAllString(model, "")
+	}
+	if config.DeploymentMapper != nil {
+		clientConfig.AzureModelMapperFunc = config.DeploymentMapper
+	}
+	if config.OrgID != "" {
+		clientConfig.OrgID = config.OrgID
+	}
+	if config.AssistantVersion != "" {
+		clientConfig.AssistantVersion = config.AssistantVersion
+	}
+	if config.EmptyMessagesLimit > 0 {
+		clientConfig.EmptyMessagesLimit = config.EmptyMessagesLimit
+	}
+
+	httpClient := config.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{}
+	}
+	if config.TokenProvider != nil {
+		base := httpClient.Transport
+		if base == nil {
+			base = http.DefaultTransport
+		}
+		wrapped := *httpClient
+		wrapped.Transport = &tokenTransport{base: base, tokenProvider: config.TokenProvider}
+		httpClient = &wrapped
+	}
+	clientConfig.HTTPClient = httpClient
+
+	return &OpenAILLM{newOpenAICompatibleLLM(clientConfig, DefaultCompatibilityProfile())}, nil
+}
@@ -0,0 +1,162 @@
+package llm
+
+import (
+	"strings"
+	"sync"
+)
+
+// Capabilities describes the features a provider supports for a model
+type Capabilities struct {
+	Tools             bool `json:"tools" yaml:"tools"`                             // Native function calling
+	ParallelToolCalls bool `json:"parallel_tool_calls" yaml:"parallel_tool_calls"` // Several tool calls in one response
+	Streaming         bool `json:"streaming" yaml:"streaming"`                     // Streamed responses
+	StreamToolDeltas  bool `json:"stream_tool_deltas" yaml:"stream_tool_deltas"`   // Tool calls while streaming
+	Vision            bool `json:"vision" yaml:"vision"`                           // Image and file parts
+	JSONMode          bool `json:"json_mode" yaml:"json_mode"`                     // Constrained JSON output
+	SystemRole        bool `json:"system_role" yaml:"system_role"`                 // System messages
+	Reasoning         bool `json:"reasoning" yaml:"reasoning"`                     // Reasoning or thinking output
+}
+
+// DefaultCapabilities returns the capabilities assumed for providers that do not report any
+func DefaultCapabilities() Capabilities {
+	return Capabilities{
+		Tools:             true,
+		ParallelToolCalls: true,
+		Streaming:         true,
+		StreamToolDeltas:  true,
+		Vision:            true,
+		JSONMode:          true,
+		SystemRole:        true,
+	}
+}
+
+// CapabilityProvider is implemented by LLMs that report their capabilities per model
+type CapabilityProvider interface {
+	Capabilities(model string) Capabilities
+}
+
+var capabilityOverrides = struct {
+	sync.RWMutex
+	byPattern map[string]Capabilities
+}{byPattern: make(map[string]Capabilities)}
+
+// RegisterModelCapabilities overrides the capabilities reported for a model.
+// A pattern ending in "*" matches every model with that prefix, the longest match wins.
+func RegisterModelCapabilities(pattern string, caps Capabilities) {
+	capabilityOverrides.Lock()
+	defer capabilityOverrides.Unlock()
+	capabilityOverrides.byPattern[pattern] = caps
+}
+
+// modelCapabilityOverride returns the registered override for a model
+func modelCapabilityOverride(model string) (Capabilities, bool) {
+	capabilityOverrides.RLock()
+	defer capabilityOverrides.RUnlock()
+
+	if caps, ok := capabilityOverrides.byPattern[model]; ok {
+		return caps, true
+	}
+	best := -1
+	var found Capabilities
+	for pattern, caps := range capabilityOverrides.byPattern {
+		prefix, ok := strings.CutSuffix(pattern, "*")
+		if ok && strings.HasPrefix(model, prefix) && len(prefix) > best {
+			best, found = len(prefix), caps
+		}
+	}
+	return found, best >= 0
+}
+
+// CapabilitiesOf returns the capabilities of client for model using the
+// default model catalog. See ModelCatalog.CapabilitiesOf for the lookup order.
+func CapabilitiesOf(client LLM, model string) Capabilities {
+	return DefaultModelCatalog().CapabilitiesOf(client, model)
+}
+
+// CapabilitiesOf returns the capabilities of client for model. Registered overrides
+// come first, then the catalog entry of the model if it sets capabilities, then the
+// client if it is a CapabilityProvider and finally DefaultCapabilities.
+func (c *ModelCatalog) CapabilitiesOf(client LLM, model string) Capabilities {
+	if caps, ok := modelCapabilityOverride(model); ok {
+		return caps
+	}
+	if info, ok := c.Lookup(model); ok && info.Capabilities != nil {
+		return *info.Capabilities
+	}
+	if provider, ok := client.(CapabilityProvider); ok {
+		return provider.Capabilities(model)
+	}
+	return DefaultCapabilities()
+}
+
+// modelMatches reports whether a model name contains any of the given names
+func modelMatches(model string, names ...string) bool {
+	model = strings.ToLower(model)
+	for _, name := range names {
+		if strings.Contains(model, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Capabilities implements CapabilityProvider from the compatibility profile
+func (c *OpenAICompatibleLLM) Capabilities(model string) Capabilities {
+	profile := c.profile
+	caps := DefaultCapabilities()
+	caps.Tools = profile.SupportsTools
+	caps.ParallelToolCalls = profile.SupportsTools
+	caps.StreamToolDeltas = profile.SupportsTools && profile.SupportsStreamToolDeltas
+	caps.SystemRole = profile.SupportsSystemRole
+	caps.Reasoning = modelMatches(model, "o1", "o3", "o4", "reasoner", "-r1", "/r1", "thinking")
+	return caps
+}
+
+// Capabilities implements CapabilityProvider for DeepSeek
+func (l *DeepSeekLLM) Capabilities(model string) Capabilities {
+	caps := l.OpenAICompatibleLLM.Capabilities(model)
+	caps.Vision = false
+	if modelMatches(model, "reasoner") {
+		caps.Tools = false
+		caps.ParallelToolCalls = false
+		caps.StreamToolDeltas = false
+		caps.JSONMode = false
+		caps.Reasoning = true
+	}
+	return caps
+}
+
+// Capabilities implements CapabilityProvider for Claude
+func (c *ClaudeLLM) Capabilities(model string) Capabilities {
+	caps := DefaultCapabilities()
+	caps.JSONMode = false
+	caps.Reasoning = c.config.Options.ThinkingBudget > 0
+	return caps
+}
+
+// Capabilities implements CapabilityProvider for Gemini
+func (g *GeminiLLM) Capabilities(model string) Capabilities {
+	caps := DefaultCapabilities()
+	caps.Reasoning = modelMatches(model, "thinking", "2.5")
+	return caps
+}
+
+// ollamaToolModels are the Ollama model families with native tool support. Other
+// models answer tool requests with an error, so their tools are emulated.
+var ollamaToolModels = []string{
+	"llama3.1", "llama3.2", "llama3.3", "llama4", "llama3-groq-tool-use",
+	"qwen2", "qwen3", "qwq", "mistral", "mixtral", "devstral", "magistral",
+	"command-r", "command-a", "firefunction", "hermes3", "nemotron", "granite3",
+	"smollm2", "phi4-mini", "athene-v2", "cogito", "gpt-oss",
+}
+
+// Capabilities implements CapabilityProvider for Ollama, tools, vision and reasoning depend on the model
+func (o *OllamaLLM) Capabilities(model string) Capabilities {
+	caps := DefaultCapabilities()
+	caps.Tools = modelMatches(model, ollamaToolModels...)
+	caps.ParallelToolCalls = caps.Tools
+	caps.StreamToolDeltas = caps.Tools
+	caps.Vision = modelMatches(model, "llava", "vision", "moondream", "minicpm-v", "gemma3", "qwen2.5vl", "granite3.2-vision")
+	caps.Reasoning = modelMatches(model, "deepseek-r1", "qwq", "qwen3")
+	return caps
+}
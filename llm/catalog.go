@@ -0,0 +1,224 @@
+package llm
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed models.json
+var defaultModelsJSON []byte
+
+// ModelPricing holds prices in USD per million tokens
+type ModelPricing struct {
+	Input       float64 `json:"input" yaml:"input"`
+	Output      float64 `json:"output" yaml:"output"`
+	CachedInput float64 `json:"cached_input,omitempty" yaml:"cached_input,omitempty"` // Prompt cache reads
+	CacheWrite  float64 `json:"cache_write,omitempty" yaml:"cache_write,omitempty"`   // Prompt cache writes
+}
+
+// ModelInfo describes a model in the catalog
+type ModelInfo struct {
+	ID              string        `json:"id" yaml:"id"`
+	Provider        LLMProvider   `json:"provider" yaml:"provider"`
+	ContextWindow   int           `json:"context_window" yaml:"context_window"`
+	MaxOutputTokens int           `json:"max_output_tokens,omitempty" yaml:"max_output_tokens,omitempty"`
+	Pricing         ModelPricing  `json:"pricing" yaml:"pricing"`
+	Capabilities    *Capabilities `json:"capabilities,omitempty" yaml:"capabilities,omitempty"` // Takes precedence over the client, nil to ask the client
+	Aliases         []string      `json:"aliases,omitempty" yaml:"aliases,omitempty"`
+}
+
+// Cost returns the price of a request in USD. Cached prompt tokens are charged
+// at the cache prices, which default to the input price.
+func (m ModelInfo) Cost(usage Usage) float64 {
+	cachedInput, cacheWrite := m.Pricing.CachedInput, m.Pricing.CacheWrite
+	if cachedInput == 0 {
+		cachedInput = m.Pricing.Input
+	}
+	if cacheWrite == 0 {
+		cacheWrite = m.Pricing.Input
+	}
+
+	uncached := usage.PromptTokens - usage.CacheReadTokens - usage.CacheCreationTokens
+	if uncached < 0 {
+		uncached = 0
+	}
+	cost := float64(uncached)*m.Pricing.Input +
+		float64(usage.CacheReadTokens)*cachedInput +
+		float64(usage.CacheCreationTokens)*cacheWrite +
+		float64(usage.CompletionTokens)*m.Pricing.Output
+	return cost / 1e6
+}
+
+// ModelCatalog is a registry of model metadata looked up by ID or alias
+type ModelCatalog struct {
+	mu      sync.RWMutex
+	models  map[string]ModelInfo
+	aliases map[string]string // alias -> ID
+}
+
+// NewModelCatalog creates an empty model catalog
+func NewModelCatalog() *ModelCatalog {
+	return &ModelCatalog{
+		models:  make(map[string]ModelInfo),
+		aliases: make(map[string]string),
+	}
+}
+
+var (
+	defaultCatalog     *ModelCatalog
+	defaultCatalogOnce sync.Once
+)
+
+// DefaultModelCatalog returns the shared catalog loaded from the embedded model list.
+// Changes to it apply to every user of the default catalog.
+func DefaultModelCatalog() *ModelCatalog {
+	defaultCatalogOnce.Do(func() {
+		defaultCatalog = NewModelCatalog()
+		if err := defaultCatalog.LoadJSON(bytes.NewReader(defaultModelsJSON)); err != nil {
+			panic(fmt.Sprintf("invalid embedded model catalog: %v", err))
+		}
+	})
+	return defaultCatalog
+}
+
+type catalogFile struct {
+	Models []ModelInfo `json:"models" yaml:"models"`
+}
+
+// LoadJSON adds or replaces the models in a JSON document of the form {"models": [...]}
+func (c *ModelCatalog) LoadJSON(r io.Reader) error {
+	var file catalogFile
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return fmt.Errorf("failed to decode model catalog: %w", err)
+	}
+	return c.addAll(file.Models)
+}
+
+// LoadYAML adds or replaces the models in a YAML document with a models list
+func (c *ModelCatalog) LoadYAML(r io.Reader) error {
+	var file catalogFile
+	if err := yaml.NewDecoder(r).Decode(&file); err != nil {
+		return fmt.Errorf("failed to decode model catalog: %w", err)
+	}
+	return c.addAll(file.Models)
+}
+
+// LoadFile loads a .json, .yaml or .yml catalog file
+func (c *ModelCatalog) LoadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return c.LoadJSON(f)
+	case ".yaml", ".yml":
+		return c.LoadYAML(f)
+	}
+	return fmt.Errorf("unsupported model catalog format: %s", path)
+}
+
+func (c *ModelCatalog) addAll(models []ModelInfo) error {
+	for _, model := range models {
+		if model.ID == "" {
+			return fmt.Errorf("model catalog entry without id")
+		}
+	}
+	for _, model := range models {
+		c.Add(model)
+	}
+	return nil
+}
+
+// Add adds or replaces a model and its aliases
+func (c *ModelCatalog) Add(info ModelInfo) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if old, ok := c.models[info.ID]; ok {
+		for _, alias := range old.Aliases {
+			delete(c.aliases, alias)
+		}
+	}
+	c.models[info.ID] = info
+	for _, alias := range info.Aliases {
+		c.aliases[alias] = info.ID
+	}
+}
+
+// Lookup returns the model with the given ID or alias. Provider prefixes such
+// as "openai/" and Ollama tags are tried as a fallback.
+func (c *ModelCatalog) Lookup(model string) (ModelInfo, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	candidates := []string{model}
+	if i := strings.LastIndex(model, "/"); i >= 0 {
+		candidates = append(candidates, model[i+1:])
+	}
+	if name, _, ok := strings.Cut(model, ":"); ok {
+		candidates = append(candidates, name)
+	}
+	for _, candidate := range candidates {
+		if info, ok := c.models[candidate]; ok {
+			return info, true
+		}
+		if id, ok := c.aliases[candidate]; ok {
+			return c.models[id], true
+		}
+	}
+	return ModelInfo{}, false
+}
+
+// Resolve returns the canonical ID of a model, or model itself if it is unknown
+func (c *ModelCatalog) Resolve(model string) string {
+	if info, ok := c.Lookup(model); ok {
+		return info.ID
+	}
+	return model
+}
+
+// Models returns all models sorted by ID, optionally only those of the given providers
+func (c *ModelCatalog) Models(providers ...LLMProvider) []ModelInfo {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	models := make([]ModelInfo, 0, len(c.models))
+	for _, info := range c.models {
+		if len(providers) > 0 && !containsProvider(providers, info.Provider) {
+			continue
+		}
+		models = append(models, info)
+	}
+	sort.Slice(models, func(i, j int) bool { return models[i].ID < models[j].ID })
+	return models
+}
+
+func containsProvider(providers []LLMProvider, provider LLMProvider) bool {
+	for _, p := range providers {
+		if p == provider {
+			return true
+		}
+	}
+	return false
+}
+
+// Cost returns the price of a request for model in USD, false if the model is unknown
+func (c *ModelCatalog) Cost(model string, usage Usage) (float64, bool) {
+	info, ok := c.Lookup(model)
+	if !ok {
+		return 0, false
+	}
+	return info.Cost(usage), true
+}
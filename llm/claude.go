@@ -5,355 +5,427 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"net/http"
+	"strings"
 
 	"github.com/anthropics/anthropic-sdk-go"
 	"github.com/anthropics/anthropic-sdk-go/option"
 	"github.com/anthropics/anthropic-sdk-go/packages/ssestream"
-
-	"github.com/invopop/jsonschema"
 )
 
 // ClaudeLLM implements the LLM interface for Anthropic's Claude
 type ClaudeLLM struct {
-	client *anthropic.Client
+	config ClaudeConfig
+	client anthropic.Client
+}
+
+// ClaudeConfig contains the settings of a Claude client
+type ClaudeConfig struct {
+	APIKey     string
+	BaseURL    string
+	APIVersion string // Sent as the anthropic-version header
+	HTTPClient *http.Client
+	Options    ClaudeOptions
+}
+
+// ClaudeOptions controls prompt caching and extended thinking
+type ClaudeOptions struct {
+	// CacheSystemPrompt places a cache breakpoint after the system prompt
+	CacheSystemPrompt bool
+
+	// CacheTools places a cache breakpoint after the tool definitions
+	CacheTools bool
+
+	// ThinkingBudget enables extended thinking with this many tokens when non-zero.
+	// MaxTokens is raised above the budget when needed and Temperature is not sent.
+	ThinkingBudget int
 }
 
 // NewClaudeLLM creates a new Claude LLM client
 func NewClaudeLLM(apiKey string) *ClaudeLLM {
-	client := anthropic.NewClient(option.WithAPIKey(apiKey))
+	return NewClaudeLLMWithConfig(ClaudeConfig{APIKey: apiKey})
+}
 
-	return &ClaudeLLM{client: client}
+// NewClaudeLLMWithConfig creates a new Claude LLM client. Empty or nil values keep the defaults.
+func NewClaudeLLMWithConfig(config ClaudeConfig) *ClaudeLLM {
+	// Failed turns are retried by the Swarm, not again by the SDK
+	opts := []option.RequestOption{option.WithAPIKey(config.APIKey), option.WithMaxRetries(0)}
+	if config.BaseURL != "" {
+		opts = append(opts, option.WithBaseURL(config.BaseURL))
+	}
+	if config.APIVersion != "" {
+		opts = append(opts, option.WithHeader("anthropic-version", config.APIVersion))
+	}
+	if config.HTTPClient != nil {
+		opts = append(opts, option.WithHTTPClient(config.HTTPClient))
+	}
+	return &ClaudeLLM{config: config, client: anthropic.NewClient(opts...)}
 }
 
-// convertToClaudeMessages converts our generic Message type to Claude's message format
-func convertToClaudeMessages(messages []Message) []anthropic.MessageParam {
-	var claudeMessages []anthropic.MessageParam
+// convertToClaudeUsage converts Claude's usage to ours. Prompt tokens include cached tokens.
+func convertToClaudeUsage(usage anthropic.Usage) Usage {
+	prompt := int(usage.InputTokens + usage.CacheCreationInputTokens + usage.CacheReadInputTokens)
+	return Usage{
+		PromptTokens:        prompt,
+		CompletionTokens:    int(usage.OutputTokens),
+		TotalTokens:         prompt + int(usage.OutputTokens),
+		CacheReadTokens:     int(usage.CacheReadInputTokens),
+		CacheCreationTokens: int(usage.CacheCreationInputTokens),
+	}
+}
+
+// convertFromClaudeStopReason converts Claude's stop reason to the OpenAI naming
+func convertFromClaudeStopReason(reason anthropic.StopReason) string {
+	switch reason {
+	case anthropic.StopReasonMaxTokens:
+		return "length"
+	case anthropic.StopReasonToolUse:
+		return "tool_calls"
+	case "", anthropic.StopReasonEndTurn, anthropic.StopReasonStopSequence:
+		return "stop"
+	}
+	return string(reason)
+}
 
-	// First pass: collect tool calls and their results
-	toolCallMap := make(map[string]string) // map[tool_id]result
+// convertToClaudeSystem combines all system messages into the system parameter
+func convertToClaudeSystem(messages []Message, cache bool) []anthropic.TextBlockParam {
+	var system []anthropic.TextBlockParam
 	for _, msg := range messages {
-		if msg.Role == RoleFunction {
-			toolCallMap[msg.Name] = msg.Content
+		if msg.Role == RoleSystem && msg.Content != "" {
+			system = append(system, anthropic.TextBlockParam{Text: msg.Content})
+		}
+	}
+	if cache && len(system) > 0 {
+		system[len(system)-1].CacheControl = anthropic.NewCacheControlEphemeralParam()
+	}
+	return system
+}
+
+// convertToClaudeParts converts content parts to Claude's blocks. Files become
+// documents, sent as plain text for text files. Files Claude cannot read as
+// documents are referenced in text.
+func convertToClaudeParts(parts []ContentPart) []anthropic.ContentBlockParamUnion {
+	var blocks []anthropic.ContentBlockParamUnion
+	for _, part := range parts {
+		switch {
+		case part.Type == ContentPartText:
+			if part.Text != "" {
+				blocks = append(blocks, anthropic.NewTextBlock(part.Text))
+			}
+		case part.Type == ContentPartImage && len(part.Data) == 0:
+			blocks = append(blocks, anthropic.NewImageBlock(anthropic.URLImageSourceParam{URL: part.URL}))
+		case part.Type == ContentPartImage:
+			blocks = append(blocks, anthropic.NewImageBlockBase64(part.MIMEType, part.Base64()))
+		case len(part.Data) == 0:
+			blocks = append(blocks, titled(anthropic.NewDocumentBlock(anthropic.URLPDFSourceParam{URL: part.URL}), part.Filename))
+		case strings.HasPrefix(part.MIMEType, "text/"):
+			blocks = append(blocks, titled(anthropic.NewDocumentBlock(anthropic.PlainTextSourceParam{Data: string(part.Data)}), part.Filename))
+		case part.MIMEType == "application/pdf":
+			blocks = append(blocks, titled(anthropic.NewDocumentBlock(anthropic.Base64PDFSourceParam{Data: part.Base64()}), part.Filename))
+		default:
+			blocks = append(blocks, anthropic.NewTextBlock(fmt.Sprintf("[file: %s]", part.Filename)))
+		}
+	}
+	return blocks
+}
+
+// titled sets the title of a document block
+func titled(block anthropic.ContentBlockParamUnion, title string) anthropic.ContentBlockParamUnion {
+	if title != "" {
+		block.OfDocument.Title = anthropic.String(title)
+	}
+	return block
+}
+
+// convertToClaudeToolResult converts content blocks to the content of a tool result
+func convertToClaudeToolResult(blocks []anthropic.ContentBlockParamUnion) []anthropic.ToolResultBlockParamContentUnion {
+	var content []anthropic.ToolResultBlockParamContentUnion
+	for _, block := range blocks {
+		content = append(content, anthropic.ToolResultBlockParamContentUnion{
+			OfText:     block.OfText,
+			OfImage:    block.OfImage,
+			OfDocument: block.OfDocument,
+		})
+	}
+	return content
+}
+
+// convertToClaudeMessages converts our generic Message type to Claude's message format.
+// Thinking blocks are replayed before tool use, function results become tool_result
+// blocks and consecutive messages of the same role are merged as Claude requires.
+func convertToClaudeMessages(messages []Message) []anthropic.MessageParam {
+	var claudeMessages []anthropic.MessageParam
+	add := func(role anthropic.MessageParamRole, blocks ...anthropic.ContentBlockParamUnion) {
+		if len(blocks) == 0 {
+			return
 		}
+		if n := len(claudeMessages); n > 0 && claudeMessages[n-1].Role == role {
+			claudeMessages[n-1].Content = append(claudeMessages[n-1].Content, blocks...)
+			return
+		}
+		claudeMessages = append(claudeMessages, anthropic.MessageParam{Role: role, Content: blocks})
 	}
 
 	for i, msg := range messages {
 		switch msg.Role {
 		case RoleSystem:
-			// Claude handles system messages differently - we'll add it as a system prompt
+			// Sent through the system parameter
 			continue
-		case RoleUser:
-			claudeMessages = append(claudeMessages, anthropic.NewUserMessage(anthropic.NewTextBlock(msg.Content)))
 		case RoleAssistant:
-			// Skip assistant messages that are the last message when there are tool calls
-			if len(msg.ToolCalls) > 0 && i == len(messages)-1 {
-				continue
-			}
-			
-			// If there are tool calls, we need to split this into multiple messages
-			if len(msg.ToolCalls) > 0 {
-				// First message: just the text content if any
-				if msg.Content != "" {
-					claudeMessages = append(claudeMessages, anthropic.NewAssistantMessage(anthropic.NewTextBlock(msg.Content)))
+			var blocks []anthropic.ContentBlockParamUnion
+			for _, thinking := range msg.Thinking {
+				if thinking.Redacted != "" {
+					blocks = append(blocks, anthropic.NewRedactedThinkingBlock(thinking.Redacted))
+				} else {
+					blocks = append(blocks, anthropic.NewThinkingBlock(thinking.Signature, thinking.Text))
 				}
-				
-				// Then for each tool call, create a tool use message
-				for _, tc := range msg.ToolCalls {
-					var args interface{}
-					if err := json.Unmarshal([]byte(tc.Function.Arguments), &args); err == nil {
-						// Create the tool use message
-						toolMsg := anthropic.NewAssistantMessage(
-							anthropic.NewToolUseBlockParam(tc.ID, tc.Function.Name, args))
-						claudeMessages = append(claudeMessages, toolMsg)
-						
-						// Add the tool result immediately after if available
-						if result, ok := toolCallMap[tc.Function.Name]; ok {
-							toolResult := anthropic.NewUserMessage(
-								anthropic.NewToolResultBlock(tc.ID, result, false))
-							claudeMessages = append(claudeMessages, toolResult)
-						}
-					}
+			}
+			if text := msg.Text(); text != "" {
+				blocks = append(blocks, anthropic.NewTextBlock(text))
+			}
+			for _, tc := range msg.ToolCalls {
+				input := json.RawMessage(tc.Function.Arguments)
+				if !json.Valid(input) {
+					input = json.RawMessage("{}")
 				}
-			} else {
-				// No tool calls, just add the content
-				claudeMessages = append(claudeMessages, anthropic.NewAssistantMessage(anthropic.NewTextBlock(msg.Content)))
+				blocks = append(blocks, anthropic.NewToolUseBlock(tc.ID, input, tc.Function.Name))
 			}
-		case RoleFunction:
-			// Function messages are handled with tool calls
-			continue
+			add(anthropic.MessageParamRoleAssistant, blocks...)
+		case RoleFunction, RoleTool:
+			id := msg.ToolCallID
+			if id == "" {
+				id = findToolCallID(messages, i, msg.Name)
+			}
+			if id == "" {
+				parts := append([]ContentPart{TextPart(fmt.Sprintf("Result of %s: %s", msg.Name, msg.Text()))}, msg.Parts...)
+				add(anthropic.MessageParamRoleUser, convertToClaudeParts(parts)...)
+				continue
+			}
+			result := anthropic.ToolResultBlockParam{ToolUseID: id}
+			if msg.HasMedia() {
+				result.Content = convertToClaudeToolResult(convertToClaudeParts(msg.ContentParts()))
+			} else if text := msg.Text(); text != "" {
+				result.Content = []anthropic.ToolResultBlockParamContentUnion{{OfText: &anthropic.TextBlockParam{Text: text}}}
+			}
+			add(anthropic.MessageParamRoleUser, anthropic.ContentBlockParamUnion{OfToolResult: &result})
+		default:
+			add(anthropic.MessageParamRoleUser, convertToClaudeParts(msg.ContentParts())...)
 		}
 	}
 
 	return claudeMessages
 }
 
-func min(a, b int) int {
-	if a < b {
-		return a
-	}
-	return b
-}
-
-// generateJSONSchema generates a JSON schema for a given type
-func generateJSONSchema[T any]() interface{} {
-	reflector := jsonschema.Reflector{
-		AllowAdditionalProperties: false,
-		DoNotReference:            true,
-	}
-	var v T
-	return reflector.Reflect(v)
-}
-
 // convertToClaudeTools converts our generic Tool type to Claude's tool format
-func convertToClaudeTools(tools []Tool) []anthropic.ToolParam {
-	if len(tools) == 0 {
-		return nil
-	}
-
-	claudeTools := make([]anthropic.ToolParam, len(tools))
-	for i, tool := range tools {
-		// Handle parameters based on their type
-		var schema interface{}
-		var params interface{} = tool.Function.Parameters
-		switch params := params.(type) {
-		case string:
-			// If it's already a JSON string, use it directly
-			schema = params
-		case map[string]interface{}:
-			// For maps, just marshal directly since they should already be in schema format
-			schema = params
-		default:
-			// For any other type, generate a schema using reflection
-			schemaObj := generateJSONSchema[interface{}]()
-			jsonBytes, _ := json.Marshal(schemaObj)
-			schema = string(jsonBytes)
+func convertToClaudeTools(tools []Tool, cache bool) []anthropic.ToolUnionParam {
+	var claudeTools []anthropic.ToolUnionParam
+	for _, tool := range tools {
+		if tool.Function == nil {
+			continue
+		}
+		schema := anthropic.ToolInputSchemaParam{ExtraFields: make(map[string]any)}
+		for key, value := range tool.Function.Parameters {
+			switch key {
+			case "type":
+				// Always an object
+			case "properties":
+				schema.Properties = value
+			default:
+				schema.ExtraFields[key] = value
+			}
+		}
+		if schema.Properties == nil {
+			schema.Properties = map[string]interface{}{}
 		}
 
-		claudeTools[i] = anthropic.ToolParam{
-			Name:        anthropic.F(tool.Function.Name),
-			Description: anthropic.F(tool.Function.Description),
-			InputSchema: anthropic.F(schema),
+		claudeTool := anthropic.ToolParam{Name: tool.Function.Name, InputSchema: schema}
+		if tool.Function.Description != "" {
+			claudeTool.Description = anthropic.String(tool.Function.Description)
 		}
+		claudeTools = append(claudeTools, anthropic.ToolUnionParam{OfTool: &claudeTool})
+	}
+	if cache && len(claudeTools) > 0 {
+		claudeTools[len(claudeTools)-1].OfTool.CacheControl = anthropic.NewCacheControlEphemeralParam()
 	}
 	return claudeTools
 }
 
-// convertFromClaudeMessage converts Claude's message type to our generic Message type
-func convertFromClaudeMessage(msg anthropic.Message) Message {
-	var content string
-	var toolCalls []ToolCall
-
-	for _, block := range msg.Content {
-		switch block := block.AsUnion().(type) {
+// convertFromClaudeBlocks converts Claude's content blocks to our generic Message type
+func convertFromClaudeBlocks(blocks []anthropic.ContentBlockUnion) Message {
+	message := Message{Role: RoleAssistant}
+	for _, block := range blocks {
+		switch block := block.AsAny().(type) {
 		case anthropic.TextBlock:
-			content = block.Text
+			message.Content += block.Text
+		case anthropic.ThinkingBlock:
+			message.Reasoning += block.Thinking
+			message.Thinking = append(message.Thinking, ThinkingBlock{Text: block.Thinking, Signature: block.Signature})
+		case anthropic.RedactedThinkingBlock:
+			message.Thinking = append(message.Thinking, ThinkingBlock{Redacted: block.Data})
 		case anthropic.ToolUseBlock:
-			toolCalls = append(toolCalls, ToolCall{
-				ID:   block.ID,
-				Type: "function",
-				Function: ToolCallFunction{
-					Name:      block.Name,
-					Arguments: string(block.Input),
-				},
+			arguments := string(block.Input)
+			if arguments == "" {
+				arguments = "{}"
+			}
+			message.ToolCalls = append(message.ToolCalls, ToolCall{
+				ID:       block.ID,
+				Type:     "function",
+				Function: ToolCallFunction{Name: block.Name, Arguments: arguments},
 			})
 		}
 	}
-
-	return Message{
-		Role:      RoleAssistant,
-		Content:   content,
-		ToolCalls: toolCalls,
-	}
+	return message
 }
 
-// CreateChatCompletion implements the LLM interface for Claude
-func (c *ClaudeLLM) CreateChatCompletion(ctx context.Context, req ChatCompletionRequest) (ChatCompletionResponse, error) {
-	// Extract system message if present
-	var systemPrompt string
-	var nonSystemMessages []Message
-
-	for _, msg := range req.Messages {
-		if msg.Role == RoleSystem {
-			systemPrompt = msg.Content
-		} else {
-			nonSystemMessages = append(nonSystemMessages, msg)
-		}
-	}
-
-	// Convert all non-system messages at once
-	messages := convertToClaudeMessages(nonSystemMessages)
-
-	if req.MaxTokens == 0 {
-		req.MaxTokens = 8192
-	}
-
-	// Create Claude request
+// buildRequest converts a generic request to Claude's format
+func (c *ClaudeLLM) buildRequest(req ChatCompletionRequest) anthropic.MessageNewParams {
+	opts := c.config.Options
 	claudeReq := anthropic.MessageNewParams{
-		Model:     anthropic.F(req.Model),
-		MaxTokens: anthropic.F(int64(req.MaxTokens)),
-		Messages:  anthropic.F(messages),
-		Tools:     anthropic.F(convertToClaudeTools(req.Tools)),
+		Model:         anthropic.Model(req.Model),
+		MaxTokens:     int64(req.MaxTokens),
+		System:        convertToClaudeSystem(req.Messages, opts.CacheSystemPrompt),
+		Messages:      convertToClaudeMessages(req.Messages),
+		Tools:         convertToClaudeTools(req.Tools, opts.CacheTools),
+		StopSequences: req.Stop,
 	}
-
-	if systemPrompt != "" {
-		claudeReq.System = anthropic.F([]anthropic.TextBlockParam{
-			anthropic.NewTextBlock(systemPrompt),
-		})
+	if claudeReq.MaxTokens == 0 {
+		claudeReq.MaxTokens = 8192
 	}
 
+	if opts.ThinkingBudget > 0 {
+		claudeReq.Thinking = anthropic.ThinkingConfigParamOfEnabled(int64(opts.ThinkingBudget))
+		if claudeReq.MaxTokens <= int64(opts.ThinkingBudget) {
+			claudeReq.MaxTokens = int64(opts.ThinkingBudget) + 8192
+		}
+		// Thinking does not allow changing temperature or top_p
+		return claudeReq
+	}
 	if req.Temperature > 0 {
-		claudeReq.Temperature = anthropic.F(float64(req.Temperature))
+		claudeReq.Temperature = anthropic.Float(float64(req.Temperature))
+	}
+	if req.TopP > 0 {
+		claudeReq.TopP = anthropic.Float(float64(req.TopP))
 	}
+	return claudeReq
+}
 
-	// Make request to Claude API
-	resp, err := c.client.Messages.New(ctx, claudeReq)
+// CreateChatCompletion implements the LLM interface for Claude
+func (c *ClaudeLLM) CreateChatCompletion(ctx context.Context, req ChatCompletionRequest) (ChatCompletionResponse, error) {
+	resp, err := c.client.Messages.New(ctx, c.buildRequest(req))
 	if err != nil {
-		return ChatCompletionResponse{}, fmt.Errorf("claude API error: %v", err)
+		return ChatCompletionResponse{}, fmt.Errorf("claude API error: %w", err)
 	}
 
-	// Convert response
-	message := convertFromClaudeMessage(*resp)
-
 	return ChatCompletionResponse{
 		ID: resp.ID,
 		Choices: []Choice{{
 			Index:        0,
-			Message:      message,
-			FinishReason: "stop", // Claude doesn't provide this explicitly
+			Message:      convertFromClaudeBlocks(resp.Content),
+			FinishReason: convertFromClaudeStopReason(resp.StopReason),
 		}},
-		Usage: Usage{
-			PromptTokens:     int(resp.Usage.InputTokens),
-			CompletionTokens: int(resp.Usage.OutputTokens),
-			TotalTokens:      int(resp.Usage.InputTokens + resp.Usage.OutputTokens),
-		},
+		Usage: convertToClaudeUsage(resp.Usage),
 	}, nil
 }
 
 // CreateChatCompletionStream implements the LLM interface for Claude streaming
 func (c *ClaudeLLM) CreateChatCompletionStream(ctx context.Context, req ChatCompletionRequest) (ChatCompletionStream, error) {
-	// Extract system message if present
-	var systemPrompt string
-	var nonSystemMessages []Message
-
-	for _, msg := range req.Messages {
-		if msg.Role == RoleSystem {
-			systemPrompt = msg.Content
-		} else {
-			nonSystemMessages = append(nonSystemMessages, msg)
-		}
-	}
-
-	// Convert all non-system messages at once
-	messages := convertToClaudeMessages(nonSystemMessages)
-
-	if req.MaxTokens == 0 {
-		req.MaxTokens = 8192
+	stream := c.client.Messages.NewStreaming(ctx, c.buildRequest(req))
+	if err := stream.Err(); err != nil {
+		return nil, fmt.Errorf("claude API error: %w", err)
 	}
-
-	// Create Claude streaming request
-	claudeReq := anthropic.MessageNewParams{
-		Model:     anthropic.F(req.Model),
-		MaxTokens: anthropic.F(int64(req.MaxTokens)),
-		Messages:  anthropic.F(messages),
-		Tools:     anthropic.F(convertToClaudeTools(req.Tools)),
-	}
-
-	if systemPrompt != "" {
-		claudeReq.System = anthropic.F([]anthropic.TextBlockParam{
-			anthropic.NewTextBlock(systemPrompt),
-		})
-	}
-
-	if req.Temperature > 0 {
-		claudeReq.Temperature = anthropic.F(float64(req.Temperature))
-	}
-
-	// Create streaming response
-	stream := c.client.Messages.NewStreaming(ctx, claudeReq)
-
-	return &claudeStreamWrapper{
-		stream:          stream,
-		message:         anthropic.Message{},
-		currentToolCall: nil,
-		currentContent:  "",
-	}, nil
+	return &claudeStreamWrapper{stream: stream}, nil
 }
 
-// claudeStreamWrapper wraps Claude's stream to implement our ChatCompletionStream interface
+// claudeStreamWrapper wraps Claude's stream to implement our ChatCompletionStream interface.
+// Text and tool input are emitted as they arrive, thinking blocks complete when their
+// block stops. The assembled tool calls are sent again with the finish chunk.
 type claudeStreamWrapper struct {
-	stream          *ssestream.Stream[anthropic.MessageStreamEvent]
-	message         anthropic.Message
-	currentToolCall *ToolCall
-	currentContent  string
+	stream    *ssestream.Stream[anthropic.MessageStreamEventUnion]
+	message   anthropic.Message // Accumulated from the events so far
+	toolCalls []ToolCall        // Complete tool calls, in order
+	toolIndex map[int64]int     // Content block index -> tool call index
 }
 
 func (w *claudeStreamWrapper) Recv() (ChatCompletionResponse, error) {
-	if !w.stream.Next() {
-		if err := w.stream.Err(); err != nil {
-			return ChatCompletionResponse{}, err
+	for w.stream.Next() {
+		event := w.stream.Current()
+		if err := w.message.Accumulate(event); err != nil {
+			return ChatCompletionResponse{}, fmt.Errorf("claude stream error: %w", err)
 		}
-		return ChatCompletionResponse{}, io.EOF
-	}
-
-	event := w.stream.Current()
-	err := w.message.Accumulate(event)
-	if err != nil {
-		return ChatCompletionResponse{}, err
-	}
 
-	message := Message{
-		Role:    RoleAssistant,
-		Content: w.currentContent,
-	}
-
-	switch event := event.AsUnion().(type) {
-	case anthropic.ContentBlockStartEvent:
-		if string(event.ContentBlock.Type) == string(anthropic.ContentBlockTypeToolUse) {
-			w.currentToolCall = &ToolCall{
-				ID:   event.ContentBlock.ID,
-				Type: "function",
-				Function: ToolCallFunction{
-					Name: event.ContentBlock.Name,
-				},
+		var message Message
+		switch event := event.AsAny().(type) {
+		case anthropic.ContentBlockStartEvent:
+			block, ok := event.ContentBlock.AsAny().(anthropic.ToolUseBlock)
+			if !ok {
+				continue
 			}
-		}
-	case anthropic.ContentBlockDeltaEvent:
-		delta := event.Delta
-		if delta.Text != "" {
-			w.currentContent += delta.Text
-			message.Content = w.currentContent
-		}
-		if delta.PartialJSON != "" && w.currentToolCall != nil {
-			if w.currentToolCall.Function.Arguments == "" {
-				w.currentToolCall.Function.Arguments = delta.PartialJSON
-			} else {
-				w.currentToolCall.Function.Arguments += delta.PartialJSON
+			if w.toolIndex == nil {
+				w.toolIndex = make(map[int64]int)
 			}
+			w.toolIndex[event.Index] = len(w.toolIndex)
+			message.ToolCalls = []ToolCall{{
+				ID:       block.ID,
+				Type:     "function",
+				Function: ToolCallFunction{Name: block.Name},
+				Index:    w.toolIndex[event.Index],
+			}}
+		case anthropic.ContentBlockDeltaEvent:
+			switch delta := event.Delta.AsAny().(type) {
+			case anthropic.TextDelta:
+				message.Content = delta.Text
+			case anthropic.ThinkingDelta:
+				message.Reasoning = delta.Thinking
+			case anthropic.InputJSONDelta:
+				if delta.PartialJSON == "" {
+					continue
+				}
+				message.ToolCalls = []ToolCall{{
+					Function: ToolCallFunction{Arguments: delta.PartialJSON},
+					Index:    w.toolIndex[event.Index],
+				}}
+			default:
+				// Signatures are sent with the complete block
+				continue
+			}
+		case anthropic.ContentBlockStopEvent:
+			if len(w.message.Content) == 0 {
+				continue
+			}
+			block := convertFromClaudeBlocks(w.message.Content[len(w.message.Content)-1:])
+			w.toolCalls = append(w.toolCalls, block.ToolCalls...)
+			if len(block.Thinking) == 0 {
+				continue
+			}
+			// Reasoning was already streamed as deltas
+			message.Thinking = block.Thinking
+		case anthropic.MessageDeltaEvent:
+			return ChatCompletionResponse{
+				ID: w.message.ID,
+				Choices: []Choice{{
+					Message:            Message{Role: RoleAssistant},
+					FinishReason:       convertFromClaudeStopReason(event.Delta.StopReason),
+					AssembledToolCalls: w.toolCalls,
+				}},
+				Usage: convertToClaudeUsage(w.message.Usage),
+			}, nil
+		default:
+			continue
 		}
-	case anthropic.ContentBlockStopEvent:
-		if w.currentToolCall != nil {
-			message.ToolCalls = []ToolCall{*w.currentToolCall}
-			w.currentToolCall = nil
-		}
-	case anthropic.MessageStopEvent:
-		// Final message, include any pending tool calls
-		if w.currentToolCall != nil {
-			message.ToolCalls = []ToolCall{*w.currentToolCall}
-			w.currentToolCall = nil
-		}
+
+		message.Role = RoleAssistant
+		return ChatCompletionResponse{
+			ID:      w.message.ID,
+			Choices: []Choice{{Message: message}},
+		}, nil
 	}
 
-	return ChatCompletionResponse{
-		ID: w.message.ID,
-		Choices: []Choice{{
-			Index:        0,
-			Message:      message,
-			FinishReason: string(event.Type),
-		}},
-	}, nil
+	if err := w.stream.Err(); err != nil {
+		return ChatCompletionResponse{}, fmt.Errorf("claude API error: %w", err)
+	}
+	return ChatCompletionResponse{}, io.EOF
 }
 
 func (w *claudeStreamWrapper) Close() error {
-	w.stream.Close()
-	return nil
+	return w.stream.Close()
 }
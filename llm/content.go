@@ -0,0 +1,129 @@
+package llm
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ContentPartType identifies the kind of a content part
+type ContentPartType string
+
+const (
+	ContentPartText  ContentPartType = "text"
+	ContentPartImage ContentPartType = "image"
+	ContentPartFile  ContentPartType = "file"
+)
+
+// ContentPart is one part of a multi-part message. Images and files carry
+// either inline Data or a URL.
+type ContentPart struct {
+	Type     ContentPartType `json:"type"`
+	Text     string          `json:"text,omitempty"`
+	Data     []byte          `json:"data,omitempty"`
+	URL      string          `json:"url,omitempty"`
+	MIMEType string          `json:"mime_type,omitempty"`
+	Filename string          `json:"filename,omitempty"`
+}
+
+// TextPart creates a text content part
+func TextPart(text string) ContentPart {
+	return ContentPart{Type: ContentPartText, Text: text}
+}
+
+// ImagePart creates an image content part from raw bytes. An empty mimeType is detected from the data.
+func ImagePart(data []byte, mimeType string) ContentPart {
+	if mimeType == "" {
+		mimeType = http.DetectContentType(data)
+	}
+	return ContentPart{Type: ContentPartImage, Data: data, MIMEType: mimeType}
+}
+
+// ImageURLPart creates an image content part referencing a URL or data URL
+func ImageURLPart(url string) ContentPart {
+	if data, mimeType, ok := parseDataURL(url); ok {
+		return ContentPart{Type: ContentPartImage, Data: data, MIMEType: mimeType}
+	}
+	return ContentPart{Type: ContentPartImage, URL: url}
+}
+
+// ImageBase64Part creates an image content part from base64 encoded data
+func ImageBase64Part(encoded, mimeType string) (ContentPart, error) {
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return ContentPart{}, fmt.Errorf("invalid base64 image: %w", err)
+	}
+	return ImagePart(data, mimeType), nil
+}
+
+// FilePart creates a file content part such as a PDF document
+func FilePart(data []byte, mimeType, filename string) ContentPart {
+	if mimeType == "" {
+		mimeType = http.DetectContentType(data)
+	}
+	return ContentPart{Type: ContentPartFile, Data: data, MIMEType: mimeType, Filename: filename}
+}
+
+// Base64 returns the inline data of the part base64 encoded
+func (p ContentPart) Base64() string {
+	return base64.StdEncoding.EncodeToString(p.Data)
+}
+
+// DataURL returns the part as a data URL, or its URL if it has no inline data
+func (p ContentPart) DataURL() string {
+	if len(p.Data) == 0 {
+		return p.URL
+	}
+	return "data:" + p.MIMEType + ";base64," + p.Base64()
+}
+
+// parseDataURL decodes a base64 data URL
+func parseDataURL(url string) ([]byte, string, bool) {
+	rest, ok := strings.CutPrefix(url, "data:")
+	if !ok {
+		return nil, "", false
+	}
+	header, encoded, ok := strings.Cut(rest, ",")
+	if !ok {
+		return nil, "", false
+	}
+	mimeType, ok := strings.CutSuffix(header, ";base64")
+	if !ok {
+		return nil, "", false
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, "", false
+	}
+	return data, mimeType, true
+}
+
+// ContentParts returns all parts of a message, with Content as a leading text part
+func (m Message) ContentParts() []ContentPart {
+	if m.Content == "" {
+		return m.Parts
+	}
+	return append([]ContentPart{TextPart(m.Content)}, m.Parts...)
+}
+
+// Text returns Content followed by the text of all text parts
+func (m Message) Text() string {
+	texts := make([]string, 0, len(m.Parts)+1)
+	for _, part := range m.ContentParts() {
+		if part.Type == ContentPartText && part.Text != "" {
+			texts = append(texts, part.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// HasMedia reports whether a message has image or file parts
+func (m Message) HasMedia() bool {
+	for _, part := range m.Parts {
+		if part.Type != ContentPartText {
+			return true
+		}
+	}
+	return false
+}
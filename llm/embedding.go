@@ -0,0 +1,159 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// EmbeddingRequest represents a request to embed a batch of texts
+type EmbeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+
+	// Dimensions requests shorter vectors from models that support it.
+	// Providers without native support truncate and renormalize the vectors.
+	Dimensions int `json:"dimensions,omitempty"`
+}
+
+// EmbeddingResponse holds one embedding per input, in input order
+type EmbeddingResponse struct {
+	Model      string      `json:"model"`
+	Embeddings [][]float32 `json:"embeddings"`
+	Usage      Usage       `json:"usage"`
+}
+
+// Dimensions returns the length of the returned vectors
+func (r EmbeddingResponse) Dimensions() int {
+	if len(r.Embeddings) == 0 {
+		return 0
+	}
+	return len(r.Embeddings[0])
+}
+
+// Embedder defines the interface for providers that create embeddings
+type Embedder interface {
+	CreateEmbeddings(ctx context.Context, req EmbeddingRequest) (EmbeddingResponse, error)
+}
+
+// EmbedderFunc adapts a function to the Embedder interface
+type EmbedderFunc func(ctx context.Context, req EmbeddingRequest) (EmbeddingResponse, error)
+
+// CreateEmbeddings calls f(ctx, req)
+func (f EmbedderFunc) CreateEmbeddings(ctx context.Context, req EmbeddingRequest) (EmbeddingResponse, error) {
+	return f(ctx, req)
+}
+
+// ErrEmbeddingCount is returned when a provider returns a different number of embeddings than inputs
+var ErrEmbeddingCount = errors.New("embedding count does not match input count")
+
+// EmbedBatches splits the input into batches of at most batchSize texts and merges
+// the results. A batchSize of zero or less sends a single request.
+func EmbedBatches(ctx context.Context, embedder Embedder, req EmbeddingRequest, batchSize int) (EmbeddingResponse, error) {
+	if batchSize <= 0 || len(req.Input) <= batchSize {
+		return embedder.CreateEmbeddings(ctx, req)
+	}
+
+	result := EmbeddingResponse{Model: req.Model, Embeddings: make([][]float32, 0, len(req.Input))}
+	for start := 0; start < len(req.Input); start += batchSize {
+		end := min(start+batchSize, len(req.Input))
+		batch := req
+		batch.Input = req.Input[start:end]
+
+		resp, err := embedder.CreateEmbeddings(ctx, batch)
+		if err != nil {
+			return EmbeddingResponse{}, fmt.Errorf("embedding batch %d-%d: %w", start, end, err)
+		}
+		if len(resp.Embeddings) != len(batch.Input) {
+			return EmbeddingResponse{}, fmt.Errorf("embedding batch %d-%d: %w", start, end, ErrEmbeddingCount)
+		}
+		if resp.Model != "" {
+			result.Model = resp.Model
+		}
+		result.Embeddings = append(result.Embeddings, resp.Embeddings...)
+		result.Usage.PromptTokens += resp.Usage.PromptTokens
+		result.Usage.TotalTokens += resp.Usage.TotalTokens
+	}
+	return result, nil
+}
+
+// truncateEmbeddings shortens vectors to dimensions and renormalizes them
+func truncateEmbeddings(embeddings [][]float32, dimensions int) [][]float32 {
+	if dimensions <= 0 {
+		return embeddings
+	}
+	for i, vector := range embeddings {
+		if len(vector) > dimensions {
+			embeddings[i] = normalize(vector[:dimensions])
+		}
+	}
+	return embeddings
+}
+
+// normalize scales a vector to unit length in place
+func normalize(vector []float32) []float32 {
+	var sum float64
+	for _, v := range vector {
+		sum += float64(v) * float64(v)
+	}
+	if sum == 0 {
+		return vector
+	}
+	norm := float32(math.Sqrt(sum))
+	for i := range vector {
+		vector[i] /= norm
+	}
+	return vector
+}
+
+// FakeEmbedder is a deterministic Embedder for tests. Words are hashed into a
+// unit vector, so texts that share words have a higher cosine similarity.
+type FakeEmbedder struct {
+	Dims int // Vector length, 64 when zero
+}
+
+// NewFakeEmbedder creates a FakeEmbedder producing vectors of length dims
+func NewFakeEmbedder(dims int) *FakeEmbedder {
+	return &FakeEmbedder{Dims: dims}
+}
+
+// CreateEmbeddings implements the Embedder interface
+func (f *FakeEmbedder) CreateEmbeddings(ctx context.Context, req EmbeddingRequest) (EmbeddingResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return EmbeddingResponse{}, err
+	}
+
+	dims := f.Dims
+	if req.Dimensions > 0 {
+		dims = req.Dimensions
+	}
+	if dims <= 0 {
+		dims = 64
+	}
+
+	resp := EmbeddingResponse{Model: req.Model, Embeddings: make([][]float32, len(req.Input))}
+	for i, text := range req.Input {
+		vector := make([]float32, dims)
+		words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+		})
+		for _, word := range words {
+			h := fnv.New64a()
+			_, _ = h.Write([]byte(word))
+			sum := h.Sum64()
+			sign := float32(1)
+			if sum&1 == 1 {
+				sign = -1
+			}
+			vector[(sum>>1)%uint64(dims)] += sign
+		}
+		resp.Embeddings[i] = normalize(vector)
+		resp.Usage.PromptTokens += len(words)
+	}
+	resp.Usage.TotalTokens = resp.Usage.PromptTokens
+	return resp, nil
+}
@@ -3,167 +3,243 @@ package llm
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
 	"strings"
 
 	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
 )
 
 // GeminiLLM implements the LLM interface for Google's Gemini
 type GeminiLLM struct {
-	client *genai.Client
+	client  *genai.Client
+	options GeminiOptions
 }
 
 // GeminiOptions contains configuration options for the Gemini model
 type GeminiOptions struct {
-	Model          string
-	HarmThreshold  genai.HarmBlockThreshold
+	Model          string                   // Used when a request does not name a model
+	HarmThreshold  genai.HarmBlockThreshold // Applied to every harm category not covered by SafetySettings
 	SafetySettings []*genai.SafetySetting
 }
 
-
+// geminiHarmCategories are the categories HarmThreshold applies to
+var geminiHarmCategories = []genai.HarmCategory{
+	genai.HarmCategoryHarassment,
+	genai.HarmCategoryHateSpeech,
+	genai.HarmCategorySexuallyExplicit,
+	genai.HarmCategoryDangerousContent,
+}
 
 // NewGeminiLLM creates a new Gemini LLM client
 func NewGeminiLLM(apiKey string, opts ...GeminiOptions) (*GeminiLLM, error) {
-	ctx := context.Background()
-	client, err := genai.NewClient(ctx, option.WithAPIKey(apiKey))
+	return NewGeminiLLMWithClient(apiKey, "", nil, opts...)
+}
+
+// NewGeminiLLMWithClient creates a new Gemini LLM client that talks to endpoint
+// through httpClient. Empty or nil values keep the defaults.
+func NewGeminiLLMWithClient(apiKey, endpoint string, httpClient *http.Client, opts ...GeminiOptions) (*GeminiLLM, error) {
+	clientOpts := []option.ClientOption{option.WithAPIKey(apiKey)}
+	if endpoint != "" {
+		clientOpts = append(clientOpts, option.WithEndpoint(endpoint))
+	}
+	if httpClient != nil {
+		// A custom HTTP client bypasses the API key option, so send the key as a header
+		clientOpts = append(clientOpts, option.WithHTTPClient(HTTPClientWithHeaders(httpClient, map[string]string{
+			"x-goog-api-key": apiKey,
+		})))
+	}
+
+	client, err := genai.NewClient(context.Background(), clientOpts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Gemini client: %v", err)
 	}
 
+	g := &GeminiLLM{client: client}
+	if len(opts) > 0 {
+		g.options = opts[0]
+	}
+	return g, nil
+}
+
+// safetySettings returns the explicit safety settings plus HarmThreshold for the remaining categories
+func (o GeminiOptions) safetySettings() []*genai.SafetySetting {
+	settings := append([]*genai.SafetySetting{}, o.SafetySettings...)
+	if o.HarmThreshold == genai.HarmBlockUnspecified {
+		return settings
+	}
 
-	return &GeminiLLM{
-		client: client,
-	}, nil
+	covered := make(map[genai.HarmCategory]bool)
+	for _, setting := range settings {
+		covered[setting.Category] = true
+	}
+	for _, category := range geminiHarmCategories {
+		if !covered[category] {
+			settings = append(settings, &genai.SafetySetting{Category: category, Threshold: o.HarmThreshold})
+		}
+	}
+	return settings
 }
 
-// convertToGeminiMessages converts our generic Message type to Gemini's content type
-func convertToGeminiMessages(messages []Message) []genai.Part {
-	var parts []genai.Part
+// geminiFunctionResponse wraps a tool result in the object Gemini expects
+func geminiFunctionResponse(content string) map[string]any {
+	var object map[string]any
+	if err := json.Unmarshal([]byte(content), &object); err == nil && object != nil {
+		return object
+	}
+	return map[string]any{"result": content}
+}
 
-	for _, msg := range messages {
-		content := strings.TrimSpace(msg.Content)
-		if content == "" {
-			continue // Skip empty messages
+// convertToGeminiParts converts content parts to Gemini parts. URLs are passed as
+// file data, which Gemini accepts for uploaded files and supported public URLs.
+func convertToGeminiParts(parts []ContentPart) []genai.Part {
+	var result []genai.Part
+	for _, part := range parts {
+		switch {
+		case part.Type == ContentPartText:
+			if strings.TrimSpace(part.Text) != "" {
+				result = append(result, genai.Text(part.Text))
+			}
+		case len(part.Data) > 0:
+			result = append(result, genai.Blob{MIMEType: part.MIMEType, Data: part.Data})
+		case part.URL != "":
+			result = append(result, genai.FileData{MIMEType: part.MIMEType, URI: part.URL})
 		}
+	}
+	return result
+}
+
+// convertToGeminiHistory converts our generic messages to Gemini chat contents.
+// System messages are returned separately as the system instruction.
+func convertToGeminiHistory(messages []Message) (*genai.Content, []*genai.Content) {
+	var systemParts []genai.Part
+	var contents []*genai.Content
+
+	// Tool results reference calls by ID, Gemini links them by function name
+	callNames := make(map[string]string)
 
+	appendParts := func(role string, parts ...genai.Part) {
+		if len(parts) == 0 {
+			return
+		}
+		// Consecutive turns of the same role are merged, e.g. parallel function responses
+		if n := len(contents); n > 0 && contents[n-1].Role == role {
+			contents[n-1].Parts = append(contents[n-1].Parts, parts...)
+			return
+		}
+		contents = append(contents, &genai.Content{Role: role, Parts: parts})
+	}
+
+	for _, msg := range messages {
 		switch msg.Role {
 		case RoleSystem:
-			parts = append(parts, genai.Text(fmt.Sprintf("[System]\n%s", content)))
-		case "function":
-			if msg.Name != "" {
-				parts = append(parts, genai.Text(fmt.Sprintf("[Function: %s]\n%s", msg.Name, content)))
-			} else {
-				parts = append(parts, genai.Text(fmt.Sprintf("[Function Result]\n%s", content)))
+			if strings.TrimSpace(msg.Content) != "" {
+				systemParts = append(systemParts, genai.Text(msg.Content))
 			}
+
 		case RoleAssistant:
-			parts = append(parts, genai.Text(fmt.Sprintf("[Assistant]\n%s", content)))
-		case RoleUser:
-			parts = append(parts, genai.Text(fmt.Sprintf("[User]\n%s", content)))
+			parts := convertToGeminiParts(msg.ContentParts())
+			for _, call := range msg.ToolCalls {
+				args := map[string]any{}
+				if call.Function.Arguments != "" {
+					_ = json.Unmarshal([]byte(call.Function.Arguments), &args)
+				}
+				parts = append(parts, genai.FunctionCall{Name: call.Function.Name, Args: args})
+				callNames[call.ID] = call.Function.Name
+			}
+			appendParts("model", parts...)
+
+		case RoleFunction, RoleTool:
+			name := msg.Name
+			if linked, ok := callNames[msg.ToolCallID]; ok && msg.ToolCallID != "" {
+				name = linked
+			}
+			appendParts("user", genai.FunctionResponse{Name: name, Response: geminiFunctionResponse(msg.Text())})
+			// Media returned by the tool follows the response in the same turn
+			appendParts("user", convertToGeminiParts(msg.Parts)...)
+
+		default:
+			appendParts("user", convertToGeminiParts(msg.ContentParts())...)
 		}
 	}
 
-	return parts
+	var system *genai.Content
+	if len(systemParts) > 0 {
+		system = &genai.Content{Parts: systemParts}
+	}
+	return system, contents
 }
 
-// convertFromGeminiResponse converts Gemini's response to our generic Message type
-func convertFromGeminiResponse(resp *genai.GenerateContentResponse) Message {
-	if resp == nil || len(resp.Candidates) == 0 {
-		return Message{Role: RoleAssistant, Content: ""}
+// convertToGeminiSchema converts a JSON Schema object to Gemini's schema type
+func convertToGeminiSchema(def map[string]interface{}) *genai.Schema {
+	schema := &genai.Schema{}
+	if typ, ok := def["type"].(string); ok {
+		schema.Type = convertSchemaType(typ)
 	}
-
-	content := resp.Candidates[0].Content
-	if content == nil || len(content.Parts) == 0 {
-		return Message{Role: RoleAssistant, Content: ""}
+	if desc, ok := def["description"].(string); ok {
+		schema.Description = desc
 	}
-
-	var textParts []string
-	var toolCalls []ToolCall
-
-	for _, part := range content.Parts {
-		switch p := part.(type) {
-		case genai.Text:
-			text := string(p)
-			// Remove role prefixes if present
-			text = strings.TrimPrefix(text, "[Assistant]\n")
-			text = strings.TrimSpace(text)
-			if text != "" {
-				textParts = append(textParts, text)
+	if format, ok := def["format"].(string); ok {
+		schema.Format = format
+	}
+	if enum, ok := def["enum"].([]interface{}); ok {
+		for _, value := range enum {
+			if str, ok := value.(string); ok {
+				schema.Enum = append(schema.Enum, str)
 			}
-		case genai.FunctionCall:
-			args, err := json.Marshal(p.Args)
-			if err == nil {
-				toolCalls = append(toolCalls, ToolCall{
-					Type: "function",
-					Function: ToolCallFunction{
-						Name:      p.Name,
-						Arguments: string(args),
-					},
-				})
+		}
+	}
+	if items, ok := def["items"].(map[string]interface{}); ok {
+		schema.Items = convertToGeminiSchema(items)
+	}
+	if properties, ok := def["properties"].(map[string]interface{}); ok {
+		schema.Properties = make(map[string]*genai.Schema, len(properties))
+		for name, prop := range properties {
+			if propMap, ok := prop.(map[string]interface{}); ok {
+				schema.Properties[name] = convertToGeminiSchema(propMap)
 			}
 		}
 	}
-
-	return Message{
-		Role:      RoleAssistant,
-		Content:   strings.Join(textParts, "\n"),
-		ToolCalls: toolCalls,
+	switch required := def["required"].(type) {
+	case []interface{}:
+		for _, r := range required {
+			if str, ok := r.(string); ok {
+				schema.Required = append(schema.Required, str)
+			}
+		}
+	case []string:
+		schema.Required = append(schema.Required, required...)
 	}
+	return schema
 }
 
 // convertToGeminiTools converts our generic Tool type to Gemini's tool type
 func convertToGeminiTools(tools []Tool) []*genai.Tool {
-	if len(tools) == 0 {
-		return nil
-	}
-
-	geminiTools := make([]*genai.Tool, len(tools))
-	for i, tool := range tools {
-		schema := &genai.Schema{
-			Type: genai.TypeObject,
+	var declarations []*genai.FunctionDeclaration
+	for _, tool := range tools {
+		if tool.Function == nil {
+			continue
 		}
-
-
-			schema.Properties = make(map[string]*genai.Schema)
-			
-			if properties, ok := tool.Function.Parameters["properties"].(map[string]interface{}); ok {
-				for name, prop := range properties {
-					if propMap, ok := prop.(map[string]interface{}); ok {
-						propSchema := &genai.Schema{}
-						if typ, ok := propMap["type"].(string); ok {
-							propSchema.Type = convertSchemaType(typ)
-						}
-						if desc, ok := propMap["description"].(string); ok {
-							propSchema.Description = desc
-						}
-						schema.Properties[name] = propSchema
-					}
-				}
-			}
-
-			if required, ok := tool.Function.Parameters["required"].([]interface{}); ok {
-				reqFields := make([]string, len(required))
-				for i, r := range required {
-					if str, ok := r.(string); ok {
-						reqFields[i] = str
-					}
-				}
-				schema.Required = reqFields
-			}
-		
-
-		geminiTools[i] = &genai.Tool{
-			FunctionDeclarations: []*genai.FunctionDeclaration{
-				{
-					Name:        tool.Function.Name,
-					Description: tool.Function.Description,
-					Parameters:  schema,
-				},
-			},
+		declaration := &genai.FunctionDeclaration{
+			Name:        tool.Function.Name,
+			Description: tool.Function.Description,
 		}
+		// Gemini rejects object schemas without properties, so parameterless functions omit them
+		if props, ok := tool.Function.Parameters["properties"].(map[string]interface{}); ok && len(props) > 0 {
+			declaration.Parameters = convertToGeminiSchema(tool.Function.Parameters)
+			declaration.Parameters.Type = genai.TypeObject
+		}
+		declarations = append(declarations, declaration)
+	}
+
+	if len(declarations) == 0 {
+		return nil
 	}
-	return geminiTools
+	return []*genai.Tool{{FunctionDeclarations: declarations}}
 }
 
 // convertSchemaType converts a JSON Schema type to Gemini schema type
@@ -186,305 +262,171 @@ func convertSchemaType(typ string) genai.Type {
 	}
 }
 
-// convertFromGeminiToolCalls converts Gemini's tool calls to our generic type
-func convertFromGeminiToolCalls(parts []genai.Part) []ToolCall {
-	var calls []ToolCall
-	
-	for _, part := range parts {
-		if fc, ok := part.(genai.FunctionCall); ok {
-			args, _ := json.Marshal(fc.Args)
-			calls = append(calls, ToolCall{
-				Type: "function",
-				Function: ToolCallFunction{
-					Name:      fc.Name,
-					Arguments: string(args),
-				},
-			})
-		}
+// convertFromGeminiFinishReason converts Gemini's finish reason to the OpenAI naming
+func convertFromGeminiFinishReason(reason genai.FinishReason) string {
+	switch reason {
+	case genai.FinishReasonUnspecified:
+		return ""
+	case genai.FinishReasonStop:
+		return "stop"
+	case genai.FinishReasonMaxTokens:
+		return "length"
+	case genai.FinishReasonSafety, genai.FinishReasonRecitation:
+		return "content_filter"
+	default:
+		return strings.ToLower(reason.String())
 	}
-	
-	return calls
 }
 
-// CreateChatCompletion implements the LLM interface for Gemini
-func (g *GeminiLLM) CreateChatCompletion(ctx context.Context, req ChatCompletionRequest) (ChatCompletionResponse, error) {
-	// Create model and configure settings
-	model := g.client.GenerativeModel(req.Model)
-
-	if req.Temperature > 0 {
-		model.SetTemperature(float32(req.Temperature))
-	}
-	if req.TopP > 0 {
-		model.SetTopP(float32(req.TopP))
-	}
-	if req.MaxTokens > 0 {
-		model.SetMaxOutputTokens(int32(req.MaxTokens))
-	}
-
-	// Check if we're in a function calling cycle
-	inFunctionCall := false
-	if len(req.Messages) > 0 {
-		lastMsg := req.Messages[len(req.Messages)-1]
-		inFunctionCall = lastMsg.Role == "function"
-	}
-
-	// Only set tools if we're not in a function calling cycle
-	if len(req.Tools) > 0 && !inFunctionCall {
-		model.Tools = convertToGeminiTools(req.Tools)
-	}
-
-	// Convert messages to Gemini format
-	parts := convertToGeminiMessages(req.Messages)
-
-	// Generate response
-	resp, err := model.GenerateContent(ctx, parts...)
-	if err != nil {
-		return ChatCompletionResponse{}, fmt.Errorf("failed to generate content: %v", err)
-	}
+// geminiCallIDs generates tool call IDs since Gemini does not provide them
+type geminiCallIDs struct {
+	next int
+}
 
-	// Convert response to our format
-	choices := make([]Choice, len(resp.Candidates))
-	for i, c := range resp.Candidates {
-		msg := Message{
-			Role:    RoleAssistant,
-			Content: "",
-		}
+func (g *geminiCallIDs) id(name string) string {
+	g.next++
+	return fmt.Sprintf("call_%d_%s", g.next, name)
+}
 
-		// Handle function calls and text separately
+// convertFromGeminiCandidate converts a Gemini candidate to a choice
+func convertFromGeminiCandidate(index int, c *genai.Candidate, ids *geminiCallIDs) Choice {
+	msg := Message{Role: RoleAssistant}
+	if c.Content != nil {
 		var textParts []string
 		for _, part := range c.Content.Parts {
 			switch p := part.(type) {
 			case genai.Text:
 				textParts = append(textParts, string(p))
 			case genai.FunctionCall:
-				if !inFunctionCall {
-					args, err := json.Marshal(p.Args)
-					if err != nil {
-						continue
-					}
-					msg.ToolCalls = append(msg.ToolCalls, ToolCall{
-						Type: "function",
-						Function: ToolCallFunction{
-							Name:      p.Name,
-							Arguments: string(args),
-						},
-					})
+				args, err := json.Marshal(p.Args)
+				if err != nil {
+					continue
 				}
+				msg.ToolCalls = append(msg.ToolCalls, ToolCall{
+					ID:   ids.id(p.Name),
+					Type: "function",
+					Function: ToolCallFunction{
+						Name:      p.Name,
+						Arguments: string(args),
+					},
+				})
 			}
 		}
 		msg.Content = strings.Join(textParts, "")
+	}
 
-		// If we have function calls and we're not in a function calling cycle
-		if len(msg.ToolCalls) > 0 && !inFunctionCall {
-			// Create new messages array with original messages plus function results
-			var newMessages []Message
-			newMessages = append(newMessages, req.Messages...)
-			
-			// Add assistant's message with function calls
-			newMessages = append(newMessages, Message{
-				Role:      RoleAssistant,
-				Content:   msg.Content,
-				ToolCalls: msg.ToolCalls,
-			})
-
-			// Add function results
-			for _, toolCall := range msg.ToolCalls {
-				newMessages = append(newMessages, Message{
-					Role:    "function",
-					Content: toolCall.Function.Arguments,
-					Name:    toolCall.Function.Name,
-				})
-			}
-
-			// Create a new request for the final response
-			finalReq := ChatCompletionRequest{
-				Model:       req.Model,
-				Messages:    newMessages,
-				Temperature: req.Temperature,
-				TopP:       req.TopP,
-				MaxTokens:   req.MaxTokens,
-			}
-
-			// Create a new model instance for the final response
-			finalModel := g.client.GenerativeModel(finalReq.Model)
-			if finalReq.Temperature > 0 {
-				finalModel.SetTemperature(float32(finalReq.Temperature))
-			}
-			if finalReq.TopP > 0 {
-				finalModel.SetTopP(float32(finalReq.TopP))
-			}
-			if finalReq.MaxTokens > 0 {
-				finalModel.SetMaxOutputTokens(int32(finalReq.MaxTokens))
-			}
-
-			// Convert messages to Gemini format
-			finalParts := convertToGeminiMessages(newMessages)
-
-			// Get the final response
-			finalResp, err := finalModel.GenerateContent(ctx, finalParts...)
-			if err != nil {
-				return ChatCompletionResponse{}, fmt.Errorf("failed to generate final response: %v", err)
-			}
+	finishReason := convertFromGeminiFinishReason(c.FinishReason)
+	if len(msg.ToolCalls) > 0 {
+		finishReason = "tool_calls"
+	}
+	return Choice{Index: index, Message: msg, FinishReason: finishReason}
+}
 
-			if len(finalResp.Candidates) > 0 {
-				var finalTextParts []string
-				for _, part := range finalResp.Candidates[0].Content.Parts {
-					if t, ok := part.(genai.Text); ok {
-						finalTextParts = append(finalTextParts, string(t))
-					}
-				}
-				msg.Content = strings.Join(finalTextParts, "")
-			}
-		}
+// convertFromGeminiUsage converts Gemini's usage metadata
+func convertFromGeminiUsage(usage *genai.UsageMetadata) Usage {
+	if usage == nil {
+		return Usage{}
+	}
+	return Usage{
+		PromptTokens:     int(usage.PromptTokenCount),
+		CompletionTokens: int(usage.CandidatesTokenCount),
+		TotalTokens:      int(usage.TotalTokenCount),
+	}
+}
 
-		choices[i] = Choice{
-			Index:        i,
-			Message:      msg,
-			FinishReason: string(c.FinishReason),
-		}
+// startChat configures a model for req and returns a chat session holding the
+// history plus the parts of the final turn
+func (g *GeminiLLM) startChat(req ChatCompletionRequest) (*genai.ChatSession, []genai.Part, error) {
+	modelName := req.Model
+	if modelName == "" {
+		modelName = g.options.Model
 	}
+	model := g.client.GenerativeModel(modelName)
 
-	// Build response with usage metrics if available
-	response := ChatCompletionResponse{
-		Choices: choices,
+	if req.Temperature > 0 {
+		model.SetTemperature(req.Temperature)
 	}
-	if resp.UsageMetadata != nil {
-		response.Usage = Usage{
-			PromptTokens:     int(resp.UsageMetadata.PromptTokenCount),
-			CompletionTokens: int(resp.UsageMetadata.CandidatesTokenCount),
-			TotalTokens:      int(resp.UsageMetadata.TotalTokenCount),
-		}
+	if req.TopP > 0 {
+		model.SetTopP(req.TopP)
+	}
+	if req.MaxTokens > 0 {
+		model.SetMaxOutputTokens(int32(req.MaxTokens))
+	}
+	if len(req.Stop) > 0 {
+		model.StopSequences = req.Stop
 	}
+	model.SafetySettings = g.options.safetySettings()
+	model.Tools = convertToGeminiTools(req.Tools)
 
-	return response, nil
-}
+	system, contents := convertToGeminiHistory(req.Messages)
+	model.SystemInstruction = system
 
-// geminiStreamWrapper wraps Gemini's stream to implement our ChatCompletionStream interface
-type geminiStreamWrapper struct {
-	iter              *genai.GenerateContentResponseIterator
-	client            *GeminiLLM
-	req               ChatCompletionRequest
-	ctx               context.Context
-	inFunctionCall    bool
-	currentToolCall   *ToolCall
-	toolCallBuffer    map[string]*ToolCall
+	if len(contents) == 0 {
+		return nil, nil, errors.New("gemini: no messages to send")
+	}
+	last := contents[len(contents)-1]
+	if last.Role != "user" {
+		return nil, nil, errors.New("gemini: the last message must be a user message or a function result")
+	}
+
+	session := model.StartChat()
+	session.History = contents[:len(contents)-1]
+	return session, last.Parts, nil
 }
 
-func (w *geminiStreamWrapper) Recv() (ChatCompletionResponse, error) {
-	// Get next response from iterator
-	resp, err := w.iter.Next()
+// CreateChatCompletion implements the LLM interface for Gemini
+func (g *GeminiLLM) CreateChatCompletion(ctx context.Context, req ChatCompletionRequest) (ChatCompletionResponse, error) {
+	session, parts, err := g.startChat(req)
 	if err != nil {
-		if w.inFunctionCall {
-			// If we're in a function call and hit the end, start a new stream for the response
-			w.inFunctionCall = false
-			w.iter = nil
-			return w.handleFunctionResponse()
-		}
 		return ChatCompletionResponse{}, err
 	}
 
+	resp, err := session.SendMessage(ctx, parts...)
+	if err != nil {
+		return ChatCompletionResponse{}, fmt.Errorf("failed to generate content: %w", err)
+	}
+
+	var ids geminiCallIDs
 	choices := make([]Choice, len(resp.Candidates))
 	for i, c := range resp.Candidates {
-		msg := Message{
-			Role:    RoleAssistant,
-			Content: "",
-		}
-
-		// Handle function calls and text separately
-		var textParts []string
-		for _, part := range c.Content.Parts {
-			switch p := part.(type) {
-			case genai.Text:
-				textParts = append(textParts, string(p))
-			case genai.FunctionCall:
-				if !w.inFunctionCall {
-					args, err := json.Marshal(p.Args)
-					if err != nil {
-						continue
-					}
-
-					// Create or update tool call
-					toolCall, exists := w.toolCallBuffer[p.Name]
-					if !exists {
-						toolCall = &ToolCall{
-							ID:   p.Name, // Use function name as ID since Gemini doesn't provide one
-							Type: "function",
-							Function: ToolCallFunction{
-								Name:      p.Name,
-								Arguments: "",
-							},
-						}
-						w.toolCallBuffer[p.Name] = toolCall
-						w.currentToolCall = toolCall
-					}
-
-					// Update arguments
-					toolCall.Function.Arguments += string(args)
-
-					// Try to parse the arguments to verify if it's complete JSON
-					var parsedArgs map[string]interface{}
-					if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &parsedArgs); err == nil {
-						// Add to message's tool calls and remove from buffer
-						msg.ToolCalls = append(msg.ToolCalls, *toolCall)
-						delete(w.toolCallBuffer, p.Name)
-						w.currentToolCall = nil
-						w.inFunctionCall = true
-					}
-				}
-			}
-		}
-		msg.Content = strings.Join(textParts, "")
-
-		choices[i] = Choice{
-			Index:        i,
-			Message:      msg,
-			FinishReason: string(c.FinishReason),
-		}
+		choices[i] = convertFromGeminiCandidate(i, c, &ids)
 	}
 
 	return ChatCompletionResponse{
 		Choices: choices,
+		Usage:   convertFromGeminiUsage(resp.UsageMetadata),
 	}, nil
 }
 
-func (w *geminiStreamWrapper) handleFunctionResponse() (ChatCompletionResponse, error) {
-	// Create new messages array with original messages plus function results
-	var newMessages []Message
-	newMessages = append(newMessages, w.req.Messages...)
-	
-	// Add the last assistant message with function calls
-	lastAssistantMsg := w.req.Messages[len(w.req.Messages)-1]
-	newMessages = append(newMessages, lastAssistantMsg)
+// geminiStreamWrapper wraps Gemini's stream to implement our ChatCompletionStream interface.
+// Gemini sends function calls whole, so every tool call is emitted complete.
+type geminiStreamWrapper struct {
+	iter *genai.GenerateContentResponseIterator
+	ids  geminiCallIDs
+}
 
-	// Add function results from tool calls
-	for _, toolCall := range lastAssistantMsg.ToolCalls {
-		newMessages = append(newMessages, Message{
-			Role:    "function",
-			Content: toolCall.Function.Arguments,
-			Name:    toolCall.Function.Name,
-		})
+func (w *geminiStreamWrapper) Recv() (ChatCompletionResponse, error) {
+	if w.iter == nil {
+		return ChatCompletionResponse{}, io.EOF
 	}
 
-	// Create a new model instance for the final response
-	model := w.client.client.GenerativeModel(w.req.Model)
-	if w.req.Temperature > 0 {
-		model.SetTemperature(float32(w.req.Temperature))
-	}
-	if w.req.TopP > 0 {
-		model.SetTopP(float32(w.req.TopP))
-	}
-	if w.req.MaxTokens > 0 {
-		model.SetMaxOutputTokens(int32(w.req.MaxTokens))
+	resp, err := w.iter.Next()
+	if err != nil {
+		if errors.Is(err, iterator.Done) {
+			return ChatCompletionResponse{}, io.EOF
+		}
+		return ChatCompletionResponse{}, fmt.Errorf("stream receive failed: %w", err)
 	}
 
-	// Convert messages to Gemini format
-	parts := convertToGeminiMessages(newMessages)
+	choices := make([]Choice, len(resp.Candidates))
+	for i, c := range resp.Candidates {
+		choices[i] = convertFromGeminiCandidate(i, c, &w.ids)
+	}
 
-	// Start new stream for the response
-	w.iter = model.GenerateContentStream(w.ctx, parts...)
-	
-	// Get first response from new stream
-	return w.Recv()
+	return ChatCompletionResponse{
+		Choices: choices,
+		Usage:   convertFromGeminiUsage(resp.UsageMetadata),
+	}, nil
 }
 
 func (w *geminiStreamWrapper) Close() error {
@@ -494,43 +436,41 @@ func (w *geminiStreamWrapper) Close() error {
 
 // CreateChatCompletionStream implements the LLM interface for Gemini streaming
 func (g *GeminiLLM) CreateChatCompletionStream(ctx context.Context, req ChatCompletionRequest) (ChatCompletionStream, error) {
-	// Create model and configure settings
-	model := g.client.GenerativeModel(req.Model)
-
-	if req.Temperature > 0 {
-		model.SetTemperature(float32(req.Temperature))
-	}
-	if req.TopP > 0 {
-		model.SetTopP(float32(req.TopP))
-	}
-	if req.MaxTokens > 0 {
-		model.SetMaxOutputTokens(int32(req.MaxTokens))
+	session, parts, err := g.startChat(req)
+	if err != nil {
+		return nil, err
 	}
 
-	// Check if we're in a function calling cycle
-	inFunctionCall := false
-	if len(req.Messages) > 0 {
-		lastMsg := req.Messages[len(req.Messages)-1]
-		inFunctionCall = lastMsg.Role == "function" || (lastMsg.Role == RoleAssistant && len(lastMsg.ToolCalls) > 0)
-	}
+	return &geminiStreamWrapper{iter: session.SendMessageStream(ctx, parts...)}, nil
+}
 
-	// Only set tools if we're not in a function calling cycle
-	if len(req.Tools) > 0 && !inFunctionCall {
-		model.Tools = convertToGeminiTools(req.Tools)
-	}
+// geminiEmbeddingBatchSize is the largest number of inputs per batchEmbedContents request
+const geminiEmbeddingBatchSize = 100
 
-	// Convert messages to Gemini format
-	parts := convertToGeminiMessages(req.Messages)
+// CreateEmbeddings implements the Embedder interface for Gemini
+func (g *GeminiLLM) CreateEmbeddings(ctx context.Context, req EmbeddingRequest) (EmbeddingResponse, error) {
+	return EmbedBatches(ctx, EmbedderFunc(g.createEmbeddings), req, geminiEmbeddingBatchSize)
+}
 
-	// Generate streaming response
-	iter := model.GenerateContentStream(ctx, parts...)
+// createEmbeddings sends a single batch of texts
+func (g *GeminiLLM) createEmbeddings(ctx context.Context, req EmbeddingRequest) (EmbeddingResponse, error) {
+	model := g.client.EmbeddingModel(req.Model)
+	batch := model.NewBatch()
+	for _, text := range req.Input {
+		batch.AddContent(genai.Text(text))
+	}
 
-	return &geminiStreamWrapper{
-		iter:           iter,
-		client:         g,
-		req:            req,
-		ctx:            ctx,
-		inFunctionCall: inFunctionCall,
-		toolCallBuffer: make(map[string]*ToolCall),
-	}, nil
+	resp, err := model.BatchEmbedContents(ctx, batch)
+	if err != nil {
+		return EmbeddingResponse{}, fmt.Errorf("Gemini embedding failed: %v", err)
+	}
+	if len(resp.Embeddings) != len(req.Input) {
+		return EmbeddingResponse{}, ErrEmbeddingCount
+	}
+
+	embeddings := make([][]float32, len(resp.Embeddings))
+	for i, embedding := range resp.Embeddings {
+		embeddings[i] = embedding.Values
+	}
+	return EmbeddingResponse{Model: req.Model, Embeddings: truncateEmbeddings(embeddings, req.Dimensions)}, nil
 }
@@ -32,10 +32,31 @@ const (
 
 // Message represents a single message in a chat conversation
 type Message struct {
-	Role      Role       `json:"role"`
-	Content   string     `json:"content"`
-	Name      string     `json:"name,omitempty"`
-	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	Role       Role       `json:"role"`
+	Content    string     `json:"content"`
+	Name       string     `json:"name,omitempty"`
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string     `json:"tool_call_id,omitempty"` // ID of the tool call a function result answers
+
+	// Parts holds images, files and further text sent after Content
+	Parts []ContentPart `json:"parts,omitempty"`
+
+	// Reasoning is the model's reasoning text, returned by reasoning models next to Content.
+	// It is for inspection only, providers decide whether it is sent back.
+	Reasoning string `json:"reasoning,omitempty"`
+
+	// Thinking holds extended thinking blocks, which must be sent back unchanged with tool results
+	Thinking []ThinkingBlock `json:"thinking,omitempty"`
+
+	// Interrupted marks a message cut short by a stopped or cancelled run
+	Interrupted bool `json:"interrupted,omitempty"`
+}
+
+// ThinkingBlock represents a block of extended thinking produced by the model
+type ThinkingBlock struct {
+	Text      string `json:"text,omitempty"`
+	Signature string `json:"signature,omitempty"`
+	Redacted  string `json:"redacted,omitempty"` // Encrypted content of a redacted block
 }
 
 // ChatCompletionRequest represents a generic request for chat completion
@@ -66,6 +87,10 @@ type Choice struct {
 	Index        int     `json:"index"`
 	Message      Message `json:"message"`
 	FinishReason string  `json:"finish_reason"`
+
+	// AssembledToolCalls may be set on the stream chunk that finishes a choice whose
+	// Message.ToolCalls were streamed as fragments. It holds the complete calls.
+	AssembledToolCalls []ToolCall `json:"assembled_tool_calls,omitempty"`
 }
 
 // Usage represents token usage
@@ -73,6 +98,11 @@ type Usage struct {
 	PromptTokens     int `json:"prompt_tokens"`
 	CompletionTokens int `json:"completion_tokens"`
 	TotalTokens      int `json:"total_tokens"`
+
+	// Prompt tokens read from and written to the provider's prompt cache,
+	// included in PromptTokens
+	CacheReadTokens     int `json:"cache_read_tokens,omitempty"`
+	CacheCreationTokens int `json:"cache_creation_tokens,omitempty"`
 }
 
 // LLM defines the interface that all LLM providers must implement
@@ -91,6 +121,7 @@ type ChatCompletionStream interface {
 type StreamDelta struct {
 	Role       Role       `json:"role"`
 	Content    string     `json:"content"`
+	Reasoning  string     `json:"reasoning,omitempty"`
 	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
 	ToolCallID string     `json:"tool_call_id,omitempty"`
 }
@@ -120,6 +151,7 @@ type ToolCall struct {
 	ID       string           `json:"id"`
 	Type     string           `json:"type"`
 	Function ToolCallFunction `json:"function"`
+	Index    int              `json:"index,omitempty"` // Position in the response, stream deltas of one call share it
 }
 
 type ToolCallFunction struct {
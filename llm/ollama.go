@@ -5,33 +5,183 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"net/http"
 	"net/url"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/ollama/ollama/api"
+	"github.com/ollama/ollama/envconfig"
+	"golang.org/x/sync/singleflight"
 )
 
 // OllamaLLM implements the LLM interface for Ollama
 type OllamaLLM struct {
-	client *api.Client
+	client  *api.Client
+	options OllamaOptions
+
+	mu      sync.Mutex
+	ensured map[string]bool    // Models known to be available when AutoPull is set
+	pulls   singleflight.Group // AutoPull checks in progress, keyed by model
+}
+
+// OllamaOptions contains configuration options for Ollama requests
+type OllamaOptions struct {
+	// KeepAlive controls how long the model stays loaded after a request.
+	// Zero uses the server default, a negative value keeps it loaded indefinitely.
+	KeepAlive time.Duration
+
+	// Options are passed as model options, e.g. "num_ctx" or "temperature".
+	// Request parameters such as Temperature and MaxTokens take precedence.
+	Options map[string]interface{}
+
+	// AutoPull pulls missing models before the first request that uses them
+	AutoPull bool
+
+	// PullProgress receives progress updates for pulls started by AutoPull
+	PullProgress func(PullProgress)
+}
+
+// PullProgress reports the progress of a model pull
+type PullProgress struct {
+	Model     string
+	Status    string
+	Digest    string
+	Total     int64
+	Completed int64
 }
 
 // NewOllamaLLM creates a new Ollama LLM client
 func NewOllamaLLM() (*OllamaLLM, error) {
-	client, err := api.ClientFromEnvironment()
-	if err != nil {
-		return nil, fmt.Errorf("failed to create Ollama client: %w", err)
-	}
-	return &OllamaLLM{client: client}, nil
+	return NewOllamaLLMWithClient("", nil)
 }
 
 // NewOllamaLLMWithURL creates a new Ollama LLM client with a custom URL
 func NewOllamaLLMWithURL(baseURL string) (*OllamaLLM, error) {
-	parsedURL, err := url.Parse(baseURL)
+	return NewOllamaLLMWithClient(baseURL, nil)
+}
+
+// NewOllamaLLMWithClient creates a new Ollama LLM client for baseURL using
+// httpClient. An empty baseURL falls back to the environment (OLLAMA_HOST).
+func NewOllamaLLMWithClient(baseURL string, httpClient *http.Client, opts ...OllamaOptions) (*OllamaLLM, error) {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	host := envconfig.Host()
+	if baseURL != "" {
+		parsedURL, err := url.Parse(baseURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse URL: %w", err)
+		}
+		host = parsedURL
+	}
+
+	o := &OllamaLLM{
+		client:  api.NewClient(host, httpClient),
+		ensured: make(map[string]bool),
+	}
+	if len(opts) > 0 {
+		o.options = opts[0]
+	}
+	return o, nil
+}
+
+// ListModels returns the names of the models available locally
+func (o *OllamaLLM) ListModels(ctx context.Context) ([]string, error) {
+	resp, err := o.client.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list Ollama models: %w", err)
+	}
+	names := make([]string, len(resp.Models))
+	for i, model := range resp.Models {
+		names[i] = model.Name
+	}
+	return names, nil
+}
+
+// HasModel reports whether a model is available locally. Names without a tag match ":latest".
+func (o *OllamaLLM) HasModel(ctx context.Context, model string) (bool, error) {
+	names, err := o.ListModels(ctx)
+	if err != nil {
+		return false, err
+	}
+	for _, name := range names {
+		if name == model || (!strings.Contains(model, ":") && name == model+":latest") {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// PullModel downloads a model, reporting progress to progress if it is not nil
+func (o *OllamaLLM) PullModel(ctx context.Context, model string, progress func(PullProgress)) error {
+	err := o.client.Pull(ctx, &api.PullRequest{Model: model}, func(resp api.ProgressResponse) error {
+		if progress != nil {
+			progress(PullProgress{
+				Model:     model,
+				Status:    resp.Status,
+				Digest:    resp.Digest,
+				Total:     resp.Total,
+				Completed: resp.Completed,
+			})
+		}
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse URL: %w", err)
+		return fmt.Errorf("failed to pull Ollama model %s: %w", model, err)
+	}
+	return nil
+}
+
+// EnsureModel pulls a model unless it is already available locally
+func (o *OllamaLLM) EnsureModel(ctx context.Context, model string, progress func(PullProgress)) error {
+	ok, err := o.HasModel(ctx, model)
+	if err != nil {
+		return err
+	}
+	if ok {
+		return nil
+	}
+	return o.PullModel(ctx, model, progress)
+}
+
+// ensureModel runs EnsureModel once per model when AutoPull is enabled.
+// Concurrent requests for a model share one pull, other models are not held up.
+func (o *OllamaLLM) ensureModel(ctx context.Context, model string) error {
+	if !o.options.AutoPull {
+		return nil
+	}
+
+	isEnsured := func() bool {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		return o.ensured[model]
+	}
+	if isEnsured() {
+		return nil
+	}
+
+	result := o.pulls.DoChan(model, func() (interface{}, error) {
+		// A pull may have finished since the check above
+		if isEnsured() {
+			return nil, nil
+		}
+		if err := o.EnsureModel(ctx, model, o.options.PullProgress); err != nil {
+			return nil, err
+		}
+		o.mu.Lock()
+		o.ensured[model] = true
+		o.mu.Unlock()
+		return nil, nil
+	})
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case res := <-result:
+		return res.Err
 	}
-	client := api.NewClient(parsedURL, nil)
-	return &OllamaLLM{client: client}, nil
 }
 
 // convertToOllamaRole converts our Role type to Ollama's role string
@@ -54,81 +204,111 @@ func convertFromOllamaRole(role string) Role {
 func convertToOllamaMessages(messages []Message) []api.Message {
 	ollamaMessages := make([]api.Message, len(messages))
 	for i, msg := range messages {
+		content, images := convertToOllamaContent(msg)
 		ollamaMessages[i] = api.Message{
 			Role:      convertToOllamaRole(msg.Role),
-			Content:   msg.Content,
+			Content:   content,
+			Images:    images,
 			ToolCalls: convertToOllamaToolCalls(msg.ToolCalls),
 		}
 	}
 	return ollamaMessages
 }
 
-// convertToOllamaTools converts our generic Tool type to Ollama's tool type
+// convertToOllamaContent splits a message into text and inline images. Ollama
+// only accepts image bytes, so text files are inlined and other media is named in the text.
+func convertToOllamaContent(msg Message) (string, []api.ImageData) {
+	var texts []string
+	var images []api.ImageData
+	for _, part := range msg.ContentParts() {
+		switch {
+		case part.Type == ContentPartText:
+			if part.Text != "" {
+				texts = append(texts, part.Text)
+			}
+		case part.Type == ContentPartImage && len(part.Data) > 0:
+			images = append(images, api.ImageData(part.Data))
+		case part.Type == ContentPartFile && strings.HasPrefix(part.MIMEType, "text/") && len(part.Data) > 0:
+			texts = append(texts, string(part.Data))
+		default:
+			name := part.Filename
+			if name == "" {
+				name = part.URL
+			}
+			texts = append(texts, fmt.Sprintf("[%s: %s]", part.Type, name))
+		}
+	}
+	return strings.Join(texts, "\n"), images
+}
+
+// ollamaSchemaType returns the type of a JSON Schema property. Union types use
+// their first non-null member.
+func ollamaSchemaType(value interface{}) string {
+	switch typ := value.(type) {
+	case string:
+		return typ
+	case []interface{}:
+		for _, member := range typ {
+			if str, ok := member.(string); ok && str != "null" {
+				return str
+			}
+		}
+	}
+	return "string"
+}
+
+// convertToOllamaTools converts our generic Tool type to Ollama's tool type.
+// Ollama only models flat properties, anything it cannot represent is dropped.
 func convertToOllamaTools(tools []Tool) api.Tools {
 	if len(tools) == 0 {
 		return nil
 	}
 
-	ollamaTools := make([]api.Tool, len(tools))
-	for i, tool := range tools {
-		// Convert required array
-		required := make([]string, len(tool.Function.Parameters["required"].([]interface{})))
-		for i, v := range tool.Function.Parameters["required"].([]interface{}) {
-			required[i] = v.(string)
+	ollamaTools := make([]api.Tool, 0, len(tools))
+	for _, tool := range tools {
+		if tool.Function == nil {
+			continue
 		}
 
-		// Convert properties map
-		rawProps := tool.Function.Parameters["properties"].(map[string]interface{})
-		properties := make(map[string]struct {
+		var fn api.ToolFunction
+		fn.Name = tool.Function.Name
+		fn.Description = tool.Function.Description
+		fn.Parameters.Type = "object"
+		fn.Parameters.Properties = make(map[string]struct {
 			Type        string   `json:"type"`
 			Description string   `json:"description"`
 			Enum        []string `json:"enum,omitempty"`
 		})
 
-		for propName, propValue := range rawProps {
-			propMap := propValue.(map[string]interface{})
-			prop := struct {
-				Type        string   `json:"type"`
-				Description string   `json:"description"`
-				Enum        []string `json:"enum,omitempty"`
-			}{
-				Type:        propMap["type"].(string),
-				Description: propMap["description"].(string),
+		switch required := tool.Function.Parameters["required"].(type) {
+		case []interface{}:
+			for _, name := range required {
+				if str, ok := name.(string); ok {
+					fn.Parameters.Required = append(fn.Parameters.Required, str)
+				}
 			}
+		case []string:
+			fn.Parameters.Required = append(fn.Parameters.Required, required...)
+		}
 
-			// Handle optional enum field
-			if enumVal, ok := propMap["enum"]; ok {
-				enumInterface := enumVal.([]interface{})
-				enumStrings := make([]string, len(enumInterface))
-				for j, e := range enumInterface {
-					enumStrings[j] = e.(string)
+		properties, _ := tool.Function.Parameters["properties"].(map[string]interface{})
+		for name, value := range properties {
+			propMap, ok := value.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			prop := fn.Parameters.Properties[name]
+			prop.Type = ollamaSchemaType(propMap["type"])
+			prop.Description, _ = propMap["description"].(string)
+			if enum, ok := propMap["enum"].([]interface{}); ok {
+				for _, e := range enum {
+					prop.Enum = append(prop.Enum, fmt.Sprint(e))
 				}
-				prop.Enum = enumStrings
 			}
-
-			properties[propName] = prop
+			fn.Parameters.Properties[name] = prop
 		}
 
-		ollamaTools[i] = api.Tool{
-			Type: "function",
-			Function: api.ToolFunction{
-				Name:        tool.Function.Name,
-				Description: tool.Function.Description,
-				Parameters: struct {
-					Type       string   `json:"type"`
-					Required   []string `json:"required"`
-					Properties map[string]struct {
-						Type        string   `json:"type"`
-						Description string   `json:"description"`
-						Enum        []string `json:"enum,omitempty"`
-					} `json:"properties"`
-				}{
-					Type:       tool.Function.Parameters["type"].(string),
-					Required:   required,
-					Properties: properties,
-				},
-			},
-		}
+		ollamaTools = append(ollamaTools, api.Tool{Type: "function", Function: fn})
 	}
 	return ollamaTools
 }
@@ -159,18 +339,26 @@ func convertToOllamaToolCalls(toolCalls []ToolCall) []api.ToolCall {
 	return calls
 }
 
+// ollamaCallIDs generates tool call IDs since Ollama does not provide them
+type ollamaCallIDs struct {
+	next int
+}
+
+func (c *ollamaCallIDs) id(name string) string {
+	c.next++
+	return fmt.Sprintf("call_%d_%s", c.next, name)
+}
+
 // convertFromOllamaToolCalls converts Ollama's tool calls to our generic type
-func convertFromOllamaToolCalls(toolCalls []api.ToolCall) []ToolCall {
+func convertFromOllamaToolCalls(toolCalls []api.ToolCall, ids *ollamaCallIDs) []ToolCall {
 	if len(toolCalls) == 0 {
 		return nil
 	}
 
 	calls := make([]ToolCall, len(toolCalls))
 	for i, call := range toolCalls {
-		// Convert api.ToolCallFunctionArguments to map[string]interface{}
-
 		calls[i] = ToolCall{
-			ID:   call.Function.Name, // Using name as ID since Ollama doesn't have a separate ID field
+			ID:   ids.id(call.Function.Name),
 			Type: "function",
 			Function: ToolCallFunction{
 				Name:      call.Function.Name,
@@ -181,63 +369,132 @@ func convertFromOllamaToolCalls(toolCalls []api.ToolCall) []ToolCall {
 	return calls
 }
 
-// CreateChatCompletion implements the LLM interface for Ollama
-func (o *OllamaLLM) CreateChatCompletion(ctx context.Context, req ChatCompletionRequest) (ChatCompletionResponse, error) {
-	stream := false
+// convertFromOllamaDoneReason converts Ollama's done reason to the OpenAI naming
+func convertFromOllamaDoneReason(reason string, hasToolCalls bool) string {
+	if hasToolCalls {
+		return "tool_calls"
+	}
+	if reason == "" {
+		return "stop"
+	}
+	return reason
+}
+
+// buildRequest converts a generic request, merging the configured options
+func (o *OllamaLLM) buildRequest(req ChatCompletionRequest, stream bool) *api.ChatRequest {
+	options := make(map[string]interface{}, len(o.options.Options)+4)
+	for key, value := range o.options.Options {
+		options[key] = value
+	}
+	if req.Temperature > 0 {
+		options["temperature"] = req.Temperature
+	}
+	if req.TopP > 0 {
+		options["top_p"] = req.TopP
+	}
+	if req.MaxTokens > 0 {
+		options["num_predict"] = req.MaxTokens
+	}
+	if len(req.Stop) > 0 {
+		options["stop"] = req.Stop
+	}
+
 	ollamaReq := &api.ChatRequest{
 		Model:    req.Model,
 		Messages: convertToOllamaMessages(req.Messages),
 		Stream:   &stream,
 		Tools:    convertToOllamaTools(req.Tools),
-		Options:  make(map[string]interface{}),
+		Options:  options,
 	}
+	if o.options.KeepAlive != 0 {
+		ollamaReq.KeepAlive = &api.Duration{Duration: o.options.KeepAlive}
+	}
+	return ollamaReq
+}
 
-	var response ChatCompletionResponse
-	var finalMessage Message
+// CreateChatCompletion implements the LLM interface for Ollama
+func (o *OllamaLLM) CreateChatCompletion(ctx context.Context, req ChatCompletionRequest) (ChatCompletionResponse, error) {
+	if err := o.ensureModel(ctx, req.Model); err != nil {
+		return ChatCompletionResponse{}, err
+	}
 
-	err := o.client.Chat(ctx, ollamaReq, func(resp api.ChatResponse) error {
+	var final api.ChatResponse
+	err := o.client.Chat(ctx, o.buildRequest(req, false), func(resp api.ChatResponse) error {
 		if resp.Done {
-			finalMessage = Message{
-				Role:      convertFromOllamaRole(resp.Message.Role),
-				Content:   resp.Message.Content,
-				ToolCalls: convertFromOllamaToolCalls(resp.Message.ToolCalls),
-			}
+			final = resp
 		}
 		return nil
 	})
-
 	if err != nil {
 		return ChatCompletionResponse{}, fmt.Errorf("Ollama chat completion failed: %w", err)
 	}
 
-	response.Choices = []Choice{
-		{
-			Index:        0,
-			Message:      finalMessage,
-			FinishReason: "stop",
-		},
+	var ids ollamaCallIDs
+	message := Message{
+		Role:      convertFromOllamaRole(final.Message.Role),
+		Content:   final.Message.Content,
+		ToolCalls: convertFromOllamaToolCalls(final.Message.ToolCalls, &ids),
 	}
 
-	return response, nil
+	return ChatCompletionResponse{
+		Choices: []Choice{
+			{
+				Index:        0,
+				Message:      message,
+				FinishReason: convertFromOllamaDoneReason(final.DoneReason, len(message.ToolCalls) > 0),
+			},
+		},
+		Usage: Usage{
+			PromptTokens:     final.PromptEvalCount,
+			CompletionTokens: final.EvalCount,
+			TotalTokens:      final.PromptEvalCount + final.EvalCount,
+		},
+	}, nil
 }
 
+// ollamaStreamChunk carries one streamed response or the final error
+type ollamaStreamChunk struct {
+	resp api.ChatResponse
+	err  error
+}
+
+// ollamaStreamWrapper adapts Ollama's callback-based streaming to ChatCompletionStream.
+// The request runs in a goroutine that hands each chunk over a channel.
 type ollamaStreamWrapper struct {
-	ctx             context.Context
-	client          *api.Client
-	req             *api.ChatRequest
-	done            bool
-	content         string
-	currentToolCall *ToolCall
-	toolCallBuffer  map[string]*ToolCall
+	chunks       chan ollamaStreamChunk
+	cancel       context.CancelFunc
+	ids          ollamaCallIDs
+	hasToolCalls bool
+	done         bool
+	closeOnce    sync.Once
 }
 
 func newOllamaStreamWrapper(ctx context.Context, client *api.Client, req *api.ChatRequest) *ollamaStreamWrapper {
-	return &ollamaStreamWrapper{
-		ctx:            ctx,
-		client:         client,
-		req:            req,
-		toolCallBuffer: make(map[string]*ToolCall),
+	ctx, cancel := context.WithCancel(ctx)
+	w := &ollamaStreamWrapper{
+		chunks: make(chan ollamaStreamChunk),
+		cancel: cancel,
 	}
+
+	go func() {
+		defer close(w.chunks)
+		err := client.Chat(ctx, req, func(resp api.ChatResponse) error {
+			select {
+			case w.chunks <- ollamaStreamChunk{resp: resp}:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		if err != nil {
+			select {
+			case w.chunks <- ollamaStreamChunk{err: err}:
+			case <-ctx.Done():
+			}
+		}
+	}()
+
+	return w
 }
 
 func (s *ollamaStreamWrapper) Recv() (ChatCompletionResponse, error) {
@@ -245,54 +502,90 @@ func (s *ollamaStreamWrapper) Recv() (ChatCompletionResponse, error) {
 		return ChatCompletionResponse{}, io.EOF
 	}
 
-	var response ChatCompletionResponse
-	err := s.client.Chat(s.ctx, s.req, func(resp api.ChatResponse) error {
-		if resp.Done {
-			s.done = true
-			return io.EOF
-		}
+	chunk, ok := <-s.chunks
+	if !ok {
+		s.done = true
+		return ChatCompletionResponse{}, io.EOF
+	}
+	if chunk.err != nil {
+		s.done = true
+		return ChatCompletionResponse{}, fmt.Errorf("Ollama stream failed: %w", chunk.err)
+	}
 
-		s.content += resp.Message.Content
-		response.Choices = []Choice{
+	resp := chunk.resp
+	toolCalls := convertFromOllamaToolCalls(resp.Message.ToolCalls, &s.ids)
+	if len(toolCalls) > 0 {
+		s.hasToolCalls = true
+	}
+
+	response := ChatCompletionResponse{
+		Choices: []Choice{
 			{
 				Index: 0,
 				Message: Message{
 					Role:      convertFromOllamaRole(resp.Message.Role),
 					Content:   resp.Message.Content,
-					ToolCalls: convertFromOllamaToolCalls(resp.Message.ToolCalls),
+					ToolCalls: toolCalls,
 				},
-				FinishReason: "",
 			},
-		}
-		return nil
-	})
-
-	if err == io.EOF {
-		response.Choices[0].FinishReason = "stop"
-		return response, nil
+		},
 	}
-
-	if err != nil {
-		return ChatCompletionResponse{}, fmt.Errorf("Ollama stream failed: %w", err)
+	if resp.Done {
+		s.done = true
+		response.Choices[0].FinishReason = convertFromOllamaDoneReason(resp.DoneReason, s.hasToolCalls)
+		response.Usage = Usage{
+			PromptTokens:     resp.PromptEvalCount,
+			CompletionTokens: resp.EvalCount,
+			TotalTokens:      resp.PromptEvalCount + resp.EvalCount,
+		}
 	}
-
 	return response, nil
 }
 
 func (s *ollamaStreamWrapper) Close() error {
+	s.closeOnce.Do(func() {
+		s.cancel()
+		// Drain so the goroutine can exit
+		go func() {
+			for range s.chunks {
+			}
+		}()
+	})
 	return nil
 }
 
 // CreateChatCompletionStream implements the LLM interface for Ollama streaming
 func (o *OllamaLLM) CreateChatCompletionStream(ctx context.Context, req ChatCompletionRequest) (ChatCompletionStream, error) {
-	stream := true
-	ollamaReq := &api.ChatRequest{
-		Model:    req.Model,
-		Messages: convertToOllamaMessages(req.Messages),
-		Stream:   &stream,
-		Tools:    convertToOllamaTools(req.Tools),
-		Options:  make(map[string]interface{}),
+	if err := o.ensureModel(ctx, req.Model); err != nil {
+		return nil, err
+	}
+	return newOllamaStreamWrapper(ctx, o.client, o.buildRequest(req, true)), nil
+}
+
+// CreateEmbeddings implements the Embedder interface for Ollama
+func (o *OllamaLLM) CreateEmbeddings(ctx context.Context, req EmbeddingRequest) (EmbeddingResponse, error) {
+	if err := o.ensureModel(ctx, req.Model); err != nil {
+		return EmbeddingResponse{}, err
 	}
 
-	return newOllamaStreamWrapper(ctx, o.client, ollamaReq), nil
+	embedReq := &api.EmbedRequest{Model: req.Model, Input: req.Input}
+	if o.options.KeepAlive != 0 {
+		embedReq.KeepAlive = &api.Duration{Duration: o.options.KeepAlive}
+	}
+	resp, err := o.client.Embed(ctx, embedReq)
+	if err != nil {
+		return EmbeddingResponse{}, fmt.Errorf("Ollama embedding failed: %w", err)
+	}
+	if len(resp.Embeddings) != len(req.Input) {
+		return EmbeddingResponse{}, ErrEmbeddingCount
+	}
+
+	return EmbeddingResponse{
+		Model:      resp.Model,
+		Embeddings: truncateEmbeddings(resp.Embeddings, req.Dimensions),
+		Usage: Usage{
+			PromptTokens: resp.PromptEvalCount,
+			TotalTokens:  resp.PromptEvalCount,
+		},
+	}, nil
 }
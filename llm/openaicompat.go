@@ -0,0 +1,531 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// CompatibilityProfile describes which parts of the OpenAI chat protocol a server supports
+type CompatibilityProfile struct {
+	SupportsTools            bool // Accepts tools and returns tool_calls
+	SupportsStreamToolDeltas bool // Streams tool calls as deltas instead of only in full responses
+	SupportsSystemRole       bool // Accepts messages with the system role
+	SupportsStreamUsage      bool // Accepts stream_options.include_usage
+}
+
+// DefaultCompatibilityProfile returns the profile of a server implementing the full protocol
+func DefaultCompatibilityProfile() CompatibilityProfile {
+	return CompatibilityProfile{
+		SupportsTools:            true,
+		SupportsStreamToolDeltas: true,
+		SupportsSystemRole:       true,
+		SupportsStreamUsage:      true,
+	}
+}
+
+// OpenAICompatibleConfig holds the settings of a client for a server speaking the
+// OpenAI chat completions protocol (vLLM, llama.cpp, LM Studio, Together, ...)
+type OpenAICompatibleConfig struct {
+	BaseURL    string            // API root, e.g. "http://localhost:8000/v1"
+	APIKey     string            // Sent as a bearer token
+	Headers    map[string]string // Extra headers sent with every request
+	HTTPClient *http.Client
+	Profile    CompatibilityProfile
+
+	OrgID              string // Sent as OpenAI-Organization
+	APIVersion         string // Sent as the api-version query parameter when set
+	EmptyMessagesLimit uint   // Maximum consecutive non-data stream lines, go-openai's default when 0
+}
+
+// OpenAICompatibleLLM implements the LLM interface for servers speaking the
+// OpenAI chat completions protocol
+type OpenAICompatibleLLM struct {
+	client  *openai.Client
+	profile CompatibilityProfile
+}
+
+// NewOpenAICompatibleLLM creates a new client for an OpenAI-compatible server
+func NewOpenAICompatibleLLM(config OpenAICompatibleConfig) *OpenAICompatibleLLM {
+	clientConfig := openai.DefaultConfig(config.APIKey)
+	if config.BaseURL != "" {
+		clientConfig.BaseURL = config.BaseURL
+	}
+	clientConfig.OrgID = config.OrgID
+	clientConfig.APIVersion = config.APIVersion
+	if config.EmptyMessagesLimit > 0 {
+		clientConfig.EmptyMessagesLimit = config.EmptyMessagesLimit
+	}
+	if config.HTTPClient != nil || len(config.Headers) > 0 {
+		clientConfig.HTTPClient = HTTPClientWithHeaders(config.HTTPClient, config.Headers)
+	}
+	return newOpenAICompatibleLLM(clientConfig, config.Profile)
+}
+
+// newOpenAICompatibleLLM creates a client from a go-openai config
+func newOpenAICompatibleLLM(config openai.ClientConfig, profile CompatibilityProfile) *OpenAICompatibleLLM {
+	return &OpenAICompatibleLLM{client: openai.NewClientWithConfig(config), profile: profile}
+}
+
+// validName matches the names accepted by the OpenAI API
+var validName = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// findToolCallID returns the ID of the most recent tool call named name before index i
+func findToolCallID(messages []Message, i int, name string) string {
+	for j := i - 1; j >= 0; j-- {
+		if messages[j].Role != RoleAssistant {
+			continue
+		}
+		for _, call := range messages[j].ToolCalls {
+			if call.Function.Name == name {
+				return call.ID
+			}
+		}
+	}
+	return ""
+}
+
+// convertToOpenAIParts converts content parts to OpenAI's type. The chat API
+// of go-openai only carries text and images, so files are referenced in text.
+func convertToOpenAIParts(parts []ContentPart) []openai.ChatMessagePart {
+	result := make([]openai.ChatMessagePart, 0, len(parts))
+	for _, part := range parts {
+		switch part.Type {
+		case ContentPartImage:
+			result = append(result, openai.ChatMessagePart{
+				Type:     openai.ChatMessagePartTypeImageURL,
+				ImageURL: &openai.ChatMessageImageURL{URL: part.DataURL()},
+			})
+		case ContentPartFile:
+			name := part.Filename
+			if name == "" {
+				name = part.URL
+			}
+			result = append(result, openai.ChatMessagePart{Type: openai.ChatMessagePartTypeText, Text: fmt.Sprintf("[file: %s]", name)})
+		default:
+			if part.Text != "" {
+				result = append(result, openai.ChatMessagePart{Type: openai.ChatMessagePartTypeText, Text: part.Text})
+			}
+		}
+	}
+	return result
+}
+
+// convertToOpenAIMessages converts our generic messages to OpenAI's type,
+// degrading features the server does not support
+func convertToOpenAIMessages(messages []Message, profile CompatibilityProfile) []openai.ChatCompletionMessage {
+	result := make([]openai.ChatCompletionMessage, 0, len(messages))
+	var pendingSystem []string
+
+	for i, msg := range messages {
+		switch msg.Role {
+		case RoleSystem:
+			if msg.Content == "" {
+				continue
+			}
+			if !profile.SupportsSystemRole {
+				// Folded into the next user message
+				pendingSystem = append(pendingSystem, msg.Content)
+				continue
+			}
+			result = append(result, openai.ChatCompletionMessage{Role: openai.ChatMessageRoleSystem, Content: msg.Content})
+
+		case RoleFunction, RoleTool:
+			toolCallID := msg.ToolCallID
+			if toolCallID == "" {
+				toolCallID = findToolCallID(messages, i, msg.Name)
+			}
+			if toolCallID == "" || !profile.SupportsTools {
+				// Without a matching call the result can only be passed on as text
+				result = append(result, openai.ChatCompletionMessage{
+					Role:    openai.ChatMessageRoleUser,
+					Content: fmt.Sprintf("Result of %s: %s", msg.Name, msg.Text()),
+				})
+				continue
+			}
+			result = append(result, openai.ChatCompletionMessage{
+				Role:       openai.ChatMessageRoleTool,
+				Content:    msg.Text(),
+				ToolCallID: toolCallID,
+			})
+			if msg.HasMedia() {
+				// Tool messages only carry text, media follows as a user message
+				parts := append([]ContentPart{TextPart(fmt.Sprintf("Attachments returned by %s:", msg.Name))}, msg.Parts...)
+				result = append(result, openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, MultiContent: convertToOpenAIParts(parts)})
+			}
+
+		case RoleAssistant:
+			out := openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: msg.Text()}
+			if profile.SupportsTools {
+				for _, call := range msg.ToolCalls {
+					out.ToolCalls = append(out.ToolCalls, openai.ToolCall{
+						ID:   call.ID,
+						Type: openai.ToolTypeFunction,
+						Function: openai.FunctionCall{
+							Name:      call.Function.Name,
+							Arguments: call.Function.Arguments,
+						},
+					})
+				}
+			}
+			if out.Content == "" && len(out.ToolCalls) == 0 {
+				continue
+			}
+			if validName.MatchString(msg.Name) {
+				out.Name = msg.Name
+			}
+			result = append(result, out)
+
+		default:
+			if msg.Content == "" && len(msg.Parts) == 0 {
+				continue
+			}
+			out := openai.ChatCompletionMessage{Role: string(msg.Role), Content: msg.Text()}
+			if len(pendingSystem) > 0 && msg.Role == RoleUser {
+				out.Content = strings.Join(append(pendingSystem, msg.Text()), "\n\n")
+			}
+			if msg.HasMedia() {
+				parts := msg.ContentParts()
+				if len(pendingSystem) > 0 && msg.Role == RoleUser {
+					parts = append([]ContentPart{TextPart(strings.Join(pendingSystem, "\n\n"))}, parts...)
+				}
+				out.Content = ""
+				out.MultiContent = convertToOpenAIParts(parts)
+			}
+			if msg.Role == RoleUser {
+				pendingSystem = nil
+			}
+			if validName.MatchString(msg.Name) {
+				out.Name = msg.Name
+			}
+			result = append(result, out)
+		}
+	}
+
+	// System messages without a following user message are sent as one
+	if len(pendingSystem) > 0 {
+		result = append(result, openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, Content: strings.Join(pendingSystem, "\n\n")})
+	}
+	return result
+}
+
+// convertToOpenAITools converts our generic Tool type to OpenAI's tool type
+func convertToOpenAITools(tools []Tool) []openai.Tool {
+	if len(tools) == 0 {
+		return nil
+	}
+
+	openAITools := make([]openai.Tool, 0, len(tools))
+	for _, tool := range tools {
+		if tool.Function == nil {
+			continue
+		}
+		openAITools = append(openAITools, openai.Tool{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        tool.Function.Name,
+				Description: tool.Function.Description,
+				Parameters:  tool.Function.Parameters,
+			},
+		})
+	}
+	return openAITools
+}
+
+// convertFromOpenAIToolCalls converts OpenAI's tool calls to our generic type
+func convertFromOpenAIToolCalls(toolCalls []openai.ToolCall) []ToolCall {
+	if len(toolCalls) == 0 {
+		return nil
+	}
+	calls := make([]ToolCall, len(toolCalls))
+	for i, call := range toolCalls {
+		calls[i] = ToolCall{
+			ID:   call.ID,
+			Type: string(call.Type),
+			Function: ToolCallFunction{
+				Name:      call.Function.Name,
+				Arguments: call.Function.Arguments,
+			},
+			Index: i,
+		}
+		if calls[i].Type == "" {
+			calls[i].Type = "function"
+		}
+	}
+	return calls
+}
+
+// convertFromOpenAIUsage converts OpenAI's usage to our generic type
+func convertFromOpenAIUsage(usage openai.Usage) Usage {
+	result := Usage{
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		TotalTokens:      usage.TotalTokens,
+	}
+	if usage.PromptTokensDetails != nil {
+		result.CacheReadTokens = usage.PromptTokensDetails.CachedTokens
+	}
+	return result
+}
+
+// buildRequest converts a generic request to OpenAI's type
+func (c *OpenAICompatibleLLM) buildRequest(req ChatCompletionRequest, stream bool) openai.ChatCompletionRequest {
+	openAIReq := openai.ChatCompletionRequest{
+		Model:            req.Model,
+		Messages:         convertToOpenAIMessages(req.Messages, c.profile),
+		Temperature:      req.Temperature,
+		TopP:             req.TopP,
+		N:                req.N,
+		Stop:             req.Stop,
+		MaxTokens:        req.MaxTokens,
+		PresencePenalty:  req.PresencePenalty,
+		FrequencyPenalty: req.FrequencyPenalty,
+		User:             req.User,
+		Stream:           stream,
+	}
+	if c.profile.SupportsTools {
+		openAIReq.Tools = convertToOpenAITools(req.Tools)
+	}
+	if stream && c.profile.SupportsStreamUsage {
+		openAIReq.StreamOptions = &openai.StreamOptions{IncludeUsage: true}
+	}
+	return openAIReq
+}
+
+// CreateChatCompletion implements the LLM interface for OpenAI-compatible servers
+func (c *OpenAICompatibleLLM) CreateChatCompletion(ctx context.Context, req ChatCompletionRequest) (ChatCompletionResponse, error) {
+	resp, err := c.client.CreateChatCompletion(ctx, c.buildRequest(req, false))
+	if err != nil {
+		return ChatCompletionResponse{}, err
+	}
+
+	result := ChatCompletionResponse{ID: resp.ID, Choices: make([]Choice, len(resp.Choices)), Usage: convertFromOpenAIUsage(resp.Usage)}
+	for i, choice := range resp.Choices {
+		result.Choices[i] = Choice{
+			Index: choice.Index,
+			Message: Message{
+				Role:      Role(choice.Message.Role),
+				Content:   choice.Message.Content,
+				Reasoning: choice.Message.ReasoningContent,
+				ToolCalls: convertFromOpenAIToolCalls(choice.Message.ToolCalls),
+			},
+			FinishReason: string(choice.FinishReason),
+		}
+	}
+	return result, nil
+}
+
+// CreateChatCompletionStream implements the LLM interface for OpenAI-compatible servers.
+// Servers that cannot stream tool deltas get a non-streaming request whenever
+// tools are present, replayed as a single chunk.
+func (c *OpenAICompatibleLLM) CreateChatCompletionStream(ctx context.Context, req ChatCompletionRequest) (ChatCompletionStream, error) {
+	if len(req.Tools) > 0 && c.profile.SupportsTools && !c.profile.SupportsStreamToolDeltas {
+		resp, err := c.CreateChatCompletion(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		return &singleResponseStream{response: resp}, nil
+	}
+
+	stream, err := c.client.CreateChatCompletionStream(ctx, c.buildRequest(req, true))
+	if err != nil {
+		return nil, err
+	}
+	return newOpenAIStream(stream), nil
+}
+
+// singleResponseStream replays a complete response as a one-chunk stream
+type singleResponseStream struct {
+	response ChatCompletionResponse
+	done     bool
+}
+
+func (s *singleResponseStream) Recv() (ChatCompletionResponse, error) {
+	if s.done {
+		return ChatCompletionResponse{}, io.EOF
+	}
+	s.done = true
+	return s.response, nil
+}
+
+func (s *singleResponseStream) Close() error {
+	return nil
+}
+
+// openAIStream wraps a go-openai stream. Tool call deltas are passed through with
+// their index as they arrive, and the assembled calls are sent again as
+// AssembledToolCalls with the chunk that finishes the choice.
+type openAIStream struct {
+	stream    *openai.ChatCompletionStream
+	toolCalls map[int]*ToolCallAccumulator // Choice index -> pending tool calls
+	done      bool
+}
+
+func newOpenAIStream(stream *openai.ChatCompletionStream) *openAIStream {
+	return &openAIStream{stream: stream, toolCalls: make(map[int]*ToolCallAccumulator)}
+}
+
+// accumulate merges tool call deltas for a choice and returns them as fragments
+func (s *openAIStream) accumulate(choice int, deltas []openai.ToolCall) []ToolCall {
+	if len(deltas) == 0 {
+		return nil
+	}
+	calls, ok := s.toolCalls[choice]
+	if !ok {
+		calls = &ToolCallAccumulator{}
+		s.toolCalls[choice] = calls
+	}
+	fragments := make([]ToolCall, 0, len(deltas))
+	for position, delta := range deltas {
+		index := position
+		if delta.Index != nil {
+			index = *delta.Index
+		}
+		fragments = append(fragments, ToolCall{
+			ID:   delta.ID,
+			Type: string(delta.Type),
+			Function: ToolCallFunction{
+				Name:      delta.Function.Name,
+				Arguments: delta.Function.Arguments,
+			},
+			Index: index,
+		})
+	}
+	calls.Add(fragments...)
+	return fragments
+}
+
+// flush returns and forgets the assembled tool calls of a choice
+func (s *openAIStream) flush(choice int) []ToolCall {
+	calls, ok := s.toolCalls[choice]
+	if !ok {
+		return nil
+	}
+	delete(s.toolCalls, choice)
+	return calls.Flush()
+}
+
+// flushAll returns a chunk with the tool calls of choices that never finished
+func (s *openAIStream) flushAll() (ChatCompletionResponse, bool) {
+	choices := make([]int, 0, len(s.toolCalls))
+	for choice := range s.toolCalls {
+		choices = append(choices, choice)
+	}
+	sort.Ints(choices)
+
+	var result ChatCompletionResponse
+	for _, choice := range choices {
+		result.Choices = append(result.Choices, Choice{
+			Index:              choice,
+			Message:            Message{Role: RoleAssistant},
+			FinishReason:       "tool_calls",
+			AssembledToolCalls: s.flush(choice),
+		})
+	}
+	return result, len(result.Choices) > 0
+}
+
+func (s *openAIStream) Recv() (ChatCompletionResponse, error) {
+	for {
+		if s.done {
+			return ChatCompletionResponse{}, io.EOF
+		}
+
+		resp, err := s.stream.Recv()
+		if errors.Is(err, io.EOF) {
+			s.done = true
+			if pending, ok := s.flushAll(); ok {
+				return pending, nil
+			}
+			return ChatCompletionResponse{}, io.EOF
+		}
+		if err != nil {
+			return ChatCompletionResponse{}, fmt.Errorf("stream receive failed: %w", err)
+		}
+
+		result := ChatCompletionResponse{ID: resp.ID}
+		if resp.Usage != nil {
+			result.Usage = convertFromOpenAIUsage(*resp.Usage)
+		}
+		for _, choice := range resp.Choices {
+			out := Choice{
+				Index: choice.Index,
+				Message: Message{
+					Role:      Role(choice.Delta.Role),
+					Content:   choice.Delta.Content,
+					Reasoning: choice.Delta.ReasoningContent,
+					ToolCalls: s.accumulate(choice.Index, choice.Delta.ToolCalls),
+				},
+				FinishReason: string(choice.FinishReason),
+			}
+			if choice.FinishReason != "" {
+				out.AssembledToolCalls = s.flush(choice.Index)
+			}
+			if out.Message.Role == "" && out.Message.Content == "" && out.Message.Reasoning == "" &&
+				len(out.Message.ToolCalls) == 0 && out.FinishReason == "" {
+				continue
+			}
+			result.Choices = append(result.Choices, out)
+		}
+
+		// Skip empty chunks
+		if len(result.Choices) == 0 && resp.Usage == nil {
+			continue
+		}
+		return result, nil
+	}
+}
+
+func (s *openAIStream) Close() error {
+	return s.stream.Close()
+}
+
+// openAIEmbeddingBatchSize is the largest number of inputs per embeddings request
+const openAIEmbeddingBatchSize = 2048
+
+// CreateEmbeddings implements the Embedder interface for OpenAI-compatible servers
+func (c *OpenAICompatibleLLM) CreateEmbeddings(ctx context.Context, req EmbeddingRequest) (EmbeddingResponse, error) {
+	return EmbedBatches(ctx, EmbedderFunc(c.createEmbeddings), req, openAIEmbeddingBatchSize)
+}
+
+// createEmbeddings sends a single embeddings request
+func (c *OpenAICompatibleLLM) createEmbeddings(ctx context.Context, req EmbeddingRequest) (EmbeddingResponse, error) {
+	resp, err := c.client.CreateEmbeddings(ctx, openai.EmbeddingRequest{
+		Model:          openai.EmbeddingModel(req.Model),
+		Input:          req.Input,
+		Dimensions:     req.Dimensions,
+		EncodingFormat: openai.EmbeddingEncodingFormatFloat,
+	})
+	if err != nil {
+		return EmbeddingResponse{}, err
+	}
+	if len(resp.Data) != len(req.Input) {
+		return EmbeddingResponse{}, ErrEmbeddingCount
+	}
+
+	result := EmbeddingResponse{
+		Model:      string(resp.Model),
+		Embeddings: make([][]float32, len(resp.Data)),
+		Usage:      convertFromOpenAIUsage(resp.Usage),
+	}
+	for i, data := range resp.Data {
+		index := data.Index
+		if index < 0 || index >= len(result.Embeddings) {
+			index = i
+		}
+		result.Embeddings[index] = data.Embedding
+	}
+	return result, nil
+}
+
+// NewSingleResponseStream returns a stream that replays a complete response as one chunk
+func NewSingleResponseStream(response ChatCompletionResponse) ChatCompletionStream {
+	return &singleResponseStream{response: response}
+}
@@ -0,0 +1,156 @@
+package llm
+
+import "fmt"
+
+// ToolCallAccumulator assembles streamed tool call deltas. Deltas are merged by
+// index, only the first delta of a call needs to carry its ID and name. A delta
+// with a different ID at a known index starts a new call, so streams that send
+// whole calls without an index are assembled as well.
+type ToolCallAccumulator struct {
+	calls     []ToolCall
+	positions map[int]int // Tool call index -> position in calls
+}
+
+// Add merges tool call deltas
+func (a *ToolCallAccumulator) Add(deltas ...ToolCall) {
+	if a.positions == nil {
+		a.positions = make(map[int]int)
+	}
+	for _, delta := range deltas {
+		position, ok := a.positions[delta.Index]
+		if ok && delta.ID != "" && a.calls[position].ID != "" && a.calls[position].ID != delta.ID {
+			ok = false
+		}
+		if !ok {
+			a.calls = append(a.calls, ToolCall{Type: "function", Index: delta.Index})
+			position = len(a.calls) - 1
+			a.positions[delta.Index] = position
+		}
+
+		call := &a.calls[position]
+		if delta.ID != "" {
+			call.ID = delta.ID
+		}
+		if delta.Type != "" {
+			call.Type = delta.Type
+		}
+		if delta.Function.Name != "" {
+			call.Function.Name = delta.Function.Name
+		}
+		call.Function.Arguments += delta.Function.Arguments
+	}
+}
+
+// Len returns the number of tool calls seen so far
+func (a *ToolCallAccumulator) Len() int {
+	return len(a.calls)
+}
+
+// Calls returns the assembled tool calls in arrival order. Calls without an ID
+// get one from their position and empty arguments become "{}".
+func (a *ToolCallAccumulator) Calls() []ToolCall {
+	if len(a.calls) == 0 {
+		return nil
+	}
+	calls := make([]ToolCall, len(a.calls))
+	copy(calls, a.calls)
+	for i := range calls {
+		if calls[i].ID == "" {
+			calls[i].ID = fmt.Sprintf("call_%d", i)
+		}
+		if calls[i].Function.Arguments == "" {
+			calls[i].Function.Arguments = "{}"
+		}
+	}
+	return calls
+}
+
+// Flush returns the assembled tool calls and resets the accumulator
+func (a *ToolCallAccumulator) Flush() []ToolCall {
+	calls := a.Calls()
+	a.calls = nil
+	a.positions = nil
+	return calls
+}
+
+// StreamAccumulator assembles the chunks of a ChatCompletionStream into the
+// response a non-streaming request would have returned
+type StreamAccumulator struct {
+	id      string
+	choices []*accumulatedChoice
+	usage   Usage
+}
+
+type accumulatedChoice struct {
+	message      Message
+	toolCalls    ToolCallAccumulator
+	assembled    []ToolCall // Complete calls sent by the stream, preferred over toolCalls
+	finishReason string
+}
+
+// Add merges a stream chunk. Usage is taken from the last chunk reporting it,
+// since providers send it once or cumulatively.
+func (a *StreamAccumulator) Add(chunk ChatCompletionResponse) {
+	if chunk.ID != "" {
+		a.id = chunk.ID
+	}
+	if chunk.Usage.TotalTokens > 0 || chunk.Usage.PromptTokens > 0 {
+		a.usage = chunk.Usage
+	}
+	for _, choice := range chunk.Choices {
+		for len(a.choices) <= choice.Index {
+			a.choices = append(a.choices, &accumulatedChoice{message: Message{Role: RoleAssistant}})
+		}
+		acc := a.choices[choice.Index]
+		if choice.Message.Role != "" {
+			acc.message.Role = choice.Message.Role
+		}
+		acc.message.Content += choice.Message.Content
+		acc.message.Reasoning += choice.Message.Reasoning
+		acc.message.Parts = append(acc.message.Parts, choice.Message.Parts...)
+		acc.message.Thinking = append(acc.message.Thinking, choice.Message.Thinking...)
+		acc.toolCalls.Add(choice.Message.ToolCalls...)
+		if len(choice.AssembledToolCalls) > 0 {
+			acc.assembled = choice.AssembledToolCalls
+		}
+		if choice.FinishReason != "" {
+			acc.finishReason = choice.FinishReason
+		}
+	}
+}
+
+// Usage returns the usage reported so far
+func (a *StreamAccumulator) Usage() Usage {
+	return a.usage
+}
+
+// FinishReason returns the finish reason of the first choice, empty while it is streaming
+func (a *StreamAccumulator) FinishReason() string {
+	if len(a.choices) == 0 {
+		return ""
+	}
+	return a.choices[0].finishReason
+}
+
+// Response returns the assembled response
+func (a *StreamAccumulator) Response() ChatCompletionResponse {
+	resp := ChatCompletionResponse{ID: a.id, Usage: a.usage}
+	for i, acc := range a.choices {
+		message := acc.message
+		message.ToolCalls = acc.toolCalls.Calls()
+		if acc.assembled != nil {
+			message.ToolCalls = acc.assembled
+		}
+		resp.Choices = append(resp.Choices, Choice{Index: i, Message: message, FinishReason: acc.finishReason})
+	}
+	return resp
+}
+
+// Message returns the assembled message of the first choice
+func (a *StreamAccumulator) Message() Message {
+	resp := a.Response()
+	if len(resp.Choices) == 0 {
+		return Message{Role: RoleAssistant}
+	}
+	return resp.Choices[0].Message
+}
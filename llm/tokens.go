@@ -0,0 +1,264 @@
+package llm
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+// TokenizerFamily identifies the tokenizer a model family uses
+type TokenizerFamily string
+
+const (
+	TokenizerO200k     TokenizerFamily = "o200k"     // GPT-4o, GPT-4.1 and o-series models
+	TokenizerCL100k    TokenizerFamily = "cl100k"    // GPT-4, GPT-3.5 and OpenAI embeddings
+	TokenizerClaude    TokenizerFamily = "claude"    // Anthropic Claude models
+	TokenizerGemini    TokenizerFamily = "gemini"    // Gemini and Gemma SentencePiece models
+	TokenizerLlama     TokenizerFamily = "llama"     // Llama, Mistral, Qwen, DeepSeek and other open models
+	TokenizerHeuristic TokenizerFamily = "heuristic" // Four characters per token
+)
+
+// tokenizerProfile holds the parameters used to approximate a tokenizer offline
+type tokenizerProfile struct {
+	shortWord   int     // Words up to this many letters are a single token
+	wordChars   float64 // Characters per token in the rest of longer words
+	digitGroup  int     // Digits merged into one token
+	punctChars  int     // Punctuation characters merged into one token
+	nonASCII    float64 // Tokens per non-ASCII rune
+	perMessage  int     // Role and separator tokens around every message
+	perName     int     // Extra tokens for a message name
+	perRequest  int     // Tokens priming the reply
+	perTool     int     // Tokens wrapping each tool definition
+	toolsPrompt int     // Tokens of the system prompt added when tools are present
+	perImage    int     // Tokens of an image of typical size
+}
+
+var tokenizerProfiles = map[TokenizerFamily]tokenizerProfile{
+	TokenizerO200k:  {shortWord: 8, wordChars: 4.5, digitGroup: 3, punctChars: 2, nonASCII: 0.7, perMessage: 3, perName: 1, perRequest: 3, perTool: 8, toolsPrompt: 12, perImage: 765},
+	TokenizerCL100k: {shortWord: 7, wordChars: 4, digitGroup: 3, punctChars: 2, nonASCII: 1, perMessage: 3, perName: 1, perRequest: 3, perTool: 8, toolsPrompt: 12, perImage: 765},
+	TokenizerClaude: {shortWord: 7, wordChars: 3.5, digitGroup: 1, punctChars: 1, nonASCII: 1, perMessage: 4, perRequest: 3, perTool: 10, toolsPrompt: 346, perImage: 1600},
+	TokenizerGemini: {shortWord: 8, wordChars: 4, digitGroup: 1, punctChars: 1, nonASCII: 0.8, perMessage: 4, perRequest: 2, perTool: 6, perImage: 258},
+	TokenizerLlama:  {shortWord: 7, wordChars: 4, digitGroup: 3, punctChars: 2, nonASCII: 1, perMessage: 5, perRequest: 4, perTool: 10, toolsPrompt: 60, perImage: 576},
+}
+
+// TokenEstimator approximates token counts without network access or vocabulary files
+type TokenEstimator struct {
+	Family  TokenizerFamily
+	profile tokenizerProfile
+	counter func(string) int
+}
+
+// NewTokenEstimator returns the estimator for a tokenizer family. Unknown
+// families fall back to the four characters per token heuristic.
+func NewTokenEstimator(family TokenizerFamily) *TokenEstimator {
+	profile, ok := tokenizerProfiles[family]
+	if !ok {
+		family = TokenizerHeuristic
+		profile = tokenizerProfile{perMessage: 4, perImage: 765}
+	}
+	return &TokenEstimator{Family: family, profile: profile}
+}
+
+// TokenEstimatorFor returns the estimator for a model, selected by its name
+func TokenEstimatorFor(model string) *TokenEstimator {
+	return NewTokenEstimator(TokenizerFamilyOf(model))
+}
+
+// TokenizerFamilyOf returns the tokenizer family of a model by its name, falling
+// back to the provider of the model in the default catalog
+func TokenizerFamilyOf(model string) TokenizerFamily {
+	name := strings.ToLower(model)
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+
+	switch {
+	case strings.HasPrefix(name, "gpt-4o"), strings.HasPrefix(name, "chatgpt-4o"),
+		strings.HasPrefix(name, "gpt-4.1"), strings.HasPrefix(name, "gpt-4.5"), strings.HasPrefix(name, "gpt-5"),
+		strings.HasPrefix(name, "o1"), strings.HasPrefix(name, "o3"), strings.HasPrefix(name, "o4"):
+		return TokenizerO200k
+	case strings.HasPrefix(name, "gpt-4"), strings.HasPrefix(name, "gpt-3.5"), strings.HasPrefix(name, "text-embedding"):
+		return TokenizerCL100k
+	case strings.Contains(name, "claude"):
+		return TokenizerClaude
+	case strings.Contains(name, "gemini"), strings.Contains(name, "gemma"):
+		return TokenizerGemini
+	case modelMatches(name, "llama", "mistral", "mixtral", "qwen", "deepseek", "phi", "llava", "nomic"):
+		return TokenizerLlama
+	}
+
+	if info, ok := DefaultModelCatalog().Lookup(model); ok {
+		switch info.Provider {
+		case OpenAI, Azure, AzureAD:
+			return TokenizerO200k
+		case Claude:
+			return TokenizerClaude
+		case Gemini:
+			return TokenizerGemini
+		case Ollama, DeepSeek:
+			return TokenizerLlama
+		}
+	}
+	return TokenizerHeuristic
+}
+
+// CountTokens estimates the tokens of text for a model
+func CountTokens(model, text string) int {
+	return TokenEstimatorFor(model).CountText(text)
+}
+
+// CountRequestTokens estimates the prompt tokens of a request for its model
+func CountRequestTokens(req ChatCompletionRequest) int {
+	return TokenEstimatorFor(req.Model).CountRequest(req)
+}
+
+// WithCounter returns a copy of the estimator that counts text with counter,
+// keeping the message and tool overheads of the family
+func (e *TokenEstimator) WithCounter(counter func(string) int) *TokenEstimator {
+	copied := *e
+	copied.counter = counter
+	return &copied
+}
+
+// CountText estimates the tokens of text
+func (e *TokenEstimator) CountText(text string) int {
+	if text == "" {
+		return 0
+	}
+	if e.counter != nil {
+		return e.counter(text)
+	}
+	if e.Family == TokenizerHeuristic {
+		return (len(text) + 3) / 4
+	}
+	return e.profile.count(text)
+}
+
+// CountMessage estimates the tokens of a message including its overhead
+func (e *TokenEstimator) CountMessage(msg Message) int {
+	total := e.profile.perMessage + e.CountText(msg.Content)
+	if msg.Name != "" {
+		total += e.profile.perName + e.CountText(msg.Name)
+	}
+	for _, part := range msg.Parts {
+		switch {
+		case part.Type == ContentPartText:
+			total += e.CountText(part.Text)
+		case part.Type == ContentPartFile && strings.HasPrefix(part.MIMEType, "text/"):
+			total += e.CountText(string(part.Data))
+		default:
+			total += e.profile.perImage
+		}
+	}
+	for _, tc := range msg.ToolCalls {
+		total += e.profile.perTool/2 + e.CountText(tc.Function.Name) + e.CountText(tc.Function.Arguments)
+	}
+	if msg.ToolCallID != "" {
+		total += e.CountText(msg.ToolCallID)
+	}
+	for _, block := range msg.Thinking {
+		total += e.CountText(block.Text)
+	}
+	return total
+}
+
+// CountTools estimates the tokens of tool definitions
+func (e *TokenEstimator) CountTools(tools []Tool) int {
+	if len(tools) == 0 {
+		return 0
+	}
+	total := e.profile.toolsPrompt
+	for _, tool := range tools {
+		if tool.Function == nil {
+			continue
+		}
+		total += e.profile.perTool + e.CountText(tool.Function.Name) + e.CountText(tool.Function.Description)
+		if tool.Function.Parameters != nil {
+			if data, err := json.Marshal(tool.Function.Parameters); err == nil {
+				total += e.CountText(string(data))
+			}
+		}
+	}
+	return total
+}
+
+// CountRequest estimates the prompt tokens of a request, including message
+// overhead and tool schemas
+func (e *TokenEstimator) CountRequest(req ChatCompletionRequest) int {
+	total := e.profile.perRequest + e.CountTools(req.Tools)
+	for _, msg := range req.Messages {
+		total += e.CountMessage(msg)
+	}
+	return total
+}
+
+// count splits text the way BPE pre-tokenizers do and estimates each piece
+func (p tokenizerProfile) count(text string) int {
+	var tokens float64
+	var nonASCII int
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		switch {
+		case r >= utf8.RuneSelf:
+			nonASCII++
+			i += size
+		case isASCIILetter(r) || (r == ' ' && i+1 < len(text) && isASCIILetter(rune(text[i+1]))):
+			// A word with its leading space
+			if r == ' ' {
+				i++
+			}
+			letters := 0
+			for i < len(text) && isASCIILetter(rune(text[i])) {
+				letters++
+				i++
+			}
+			tokens += p.wordTokens(letters)
+		case r >= '0' && r <= '9':
+			digits := 0
+			for i < len(text) && text[i] >= '0' && text[i] <= '9' {
+				digits++
+				i++
+			}
+			tokens += math.Ceil(float64(digits) / float64(p.digitGroup))
+		case isASCIISpace(byte(r)):
+			for i < len(text) && isASCIISpace(text[i]) && !(text[i] == ' ' && i+1 < len(text) && isASCIILetter(rune(text[i+1]))) {
+				i++
+			}
+			tokens++
+		default:
+			punct := 0
+			for i < len(text) && text[i] < utf8.RuneSelf && isASCIIPunct(rune(text[i])) {
+				punct++
+				i++
+			}
+			if punct == 0 {
+				i++
+				punct = 1
+			}
+			tokens += math.Ceil(float64(punct) / float64(p.punctChars))
+		}
+	}
+	tokens += math.Ceil(float64(nonASCII) * p.nonASCII)
+	return int(tokens)
+}
+
+// wordTokens estimates the tokens of a word with the given number of letters
+func (p tokenizerProfile) wordTokens(letters int) float64 {
+	if letters <= p.shortWord {
+		return 1
+	}
+	return 1 + math.Ceil(float64(letters-p.shortWord)/p.wordChars)
+}
+
+func isASCIILetter(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+}
+
+func isASCIISpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r' || b == '\v' || b == '\f'
+}
+
+func isASCIIPunct(r rune) bool {
+	return r > ' ' && r < utf8.RuneSelf && !isASCIILetter(r) && !(r >= '0' && r <= '9')
+}
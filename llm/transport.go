@@ -0,0 +1,144 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// headerTransport sets fixed headers on every outgoing request
+type headerTransport struct {
+	base    http.RoundTripper
+	headers map[string]string
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// RoundTrippers must not modify the caller's request
+	req = req.Clone(req.Context())
+	for key, value := range t.headers {
+		req.Header.Set(key, value)
+	}
+	return t.base.RoundTrip(req)
+}
+
+// HTTPClientWithHeaders returns a copy of client that sets headers on every request.
+// A nil client is treated as http.DefaultClient.
+func HTTPClientWithHeaders(client *http.Client, headers map[string]string) *http.Client {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if len(headers) == 0 {
+		return client
+	}
+
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	wrapped := *client
+	wrapped.Transport = &headerTransport{base: base, headers: headers}
+	return &wrapped
+}
+
+// reasoningDoer decodes the reasoning field OpenRouter sends where DeepSeek sends
+// reasoning_content, the field go-openai decodes. Streams are read one line at a
+// time so they are not buffered.
+type reasoningDoer struct {
+	doer openai.HTTPDoer
+}
+
+func (d *reasoningDoer) Do(req *http.Request) (*http.Response, error) {
+	resp, err := d.doer.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		resp.Body = &reasoningBody{ReadCloser: resp.Body, reader: bufio.NewReader(resp.Body)}
+		return resp, nil
+	}
+
+	// Other responses are a single JSON document, possibly spread over several lines
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	body = withReasoningContent(body)
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	return resp, nil
+}
+
+// reasoningBody copies reasoning to reasoning_content in the choices of a
+// response body, one line at a time
+type reasoningBody struct {
+	io.ReadCloser
+	reader  *bufio.Reader
+	pending []byte
+	err     error
+}
+
+func (b *reasoningBody) Read(p []byte) (int, error) {
+	for len(b.pending) == 0 {
+		if b.err != nil {
+			return 0, b.err
+		}
+		var line []byte
+		line, b.err = b.reader.ReadBytes('\n')
+		b.pending = withReasoningContent(line)
+	}
+	n := copy(p, b.pending)
+	b.pending = b.pending[n:]
+	return n, nil
+}
+
+// withReasoningContent returns line with reasoning copied to reasoning_content in
+// the message or delta of every choice that has no reasoning_content. Lines that
+// are not a JSON response or a server-sent event carrying one are returned unchanged.
+func withReasoningContent(line []byte) []byte {
+	if !bytes.Contains(line, []byte(`"reasoning"`)) {
+		return line
+	}
+	prefix, data := []byte{}, bytes.TrimSpace(line)
+	if rest, ok := bytes.CutPrefix(data, []byte("data:")); ok {
+		prefix, data = []byte("data: "), bytes.TrimSpace(rest)
+	}
+
+	var resp map[string]json.RawMessage
+	var choices []map[string]json.RawMessage
+	if json.Unmarshal(data, &resp) != nil || json.Unmarshal(resp["choices"], &choices) != nil {
+		return line
+	}
+	changed := false
+	for _, choice := range choices {
+		for _, key := range []string{"message", "delta"} {
+			var fields map[string]json.RawMessage
+			if json.Unmarshal(choice[key], &fields) != nil || fields["reasoning"] == nil || fields["reasoning_content"] != nil {
+				continue
+			}
+			fields["reasoning_content"] = fields["reasoning"]
+			if encoded, err := json.Marshal(fields); err == nil {
+				choice[key] = encoded
+				changed = true
+			}
+		}
+	}
+	if !changed {
+		return line
+	}
+
+	encoded, err := json.Marshal(choices)
+	if err != nil {
+		return line
+	}
+	resp["choices"] = encoded
+	if data, err = json.Marshal(resp); err != nil {
+		return line
+	}
+	return append(append(prefix, data...), '\n')
+}
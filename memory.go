@@ -1,54 +1,128 @@
 package swarmgo
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
+	"log"
+	"slices"
 	"sync"
 	"time"
+
+	"github.com/google/uuid"
 )
 
 // Memory represents a single memory entry
 type Memory struct {
-	Content     string                 `json:"content"`     // The actual memory content
-	Type        string                 `json:"type"`        // Type of memory (e.g., "conversation", "fact", "task")
-	Context     map[string]interface{} `json:"context"`     // Associated context
-	Timestamp   time.Time             `json:"timestamp"`   // When the memory was created
-	Importance  float64               `json:"importance"`  // Importance score (0-1)
-	References  []string              `json:"references"`  // References to related memories
+	ID         string                 `json:"id,omitempty"`        // Unique ID, assigned when the memory is added
+	Content    string                 `json:"content"`             // The actual memory content
+	Type       string                 `json:"type"`                // Type of memory (e.g., "conversation", "fact", "task")
+	Context    map[string]interface{} `json:"context"`             // Associated context
+	Timestamp  time.Time              `json:"timestamp"`           // When the memory was created
+	Importance float64                `json:"importance"`          // Importance score (0-1)
+	References []string               `json:"references"`          // References to related memories
+	Embedding  []float32              `json:"embedding,omitempty"` // Vector of the content, set when semantic search is enabled
 }
 
-// MemoryStore manages agent memories
+// MemoryStore manages agent memories. Recent memories are kept in a short-term
+// buffer, typed memories are also stored in a long-term backend.
 type MemoryStore struct {
-	shortTerm  []Memory              // Recent memories (FIFO buffer)
-	longTerm   map[string][]Memory   // Organized long-term memories
-	maxShort   int                   // Maximum number of short-term memories
-	mu         sync.RWMutex          // For thread safety
+	shortTerm []Memory        // Recent memories (FIFO buffer)
+	backend   MemoryBackend   // Long-term memories
+	semantic  *semanticSearch // Embedder and index, nil without semantic search
+	maxShort  int             // Maximum number of short-term memories
+	mu        sync.RWMutex    // For thread safety
 }
 
-// NewMemoryStore creates a new memory store with default settings
+// NewMemoryStore creates a new memory store that keeps its long-term memories in memory
 func NewMemoryStore(maxShortTerm int) *MemoryStore {
 	return &MemoryStore{
 		shortTerm: make([]Memory, 0),
-		longTerm:  make(map[string][]Memory),
+		backend:   NewInMemoryBackend(),
 		maxShort:  maxShortTerm,
 	}
 }
 
-// AddMemory adds a new memory to both short and long-term storage
+// NewMemoryStoreWithBackend creates a memory store on a long-term backend, such
+// as a FileMemoryBackend or SQLiteMemoryBackend. The short-term buffer starts
+// with the backend's most recent memories.
+func NewMemoryStoreWithBackend(ctx context.Context, maxShortTerm int, backend MemoryBackend) (*MemoryStore, error) {
+	recent := make([]Memory, 0)
+	if maxShortTerm > 0 {
+		var err error
+		if recent, err = backend.Search(ctx, MemoryQuery{Limit: maxShortTerm}); err != nil {
+			return nil, fmt.Errorf("error loading recent memories: %w", err)
+		}
+	}
+	return &MemoryStore{
+		shortTerm: recent,
+		backend:   backend,
+		maxShort:  maxShortTerm,
+	}, nil
+}
+
+// Backend returns the long-term backend of the store
+func (ms *MemoryStore) Backend() MemoryBackend {
+	return ms.backend
+}
+
+// AddMemory adds a new memory like AddMemoryContext. Errors of the long-term
+// backend are logged, use AddMemoryContext to handle them.
 func (ms *MemoryStore) AddMemory(memory Memory) {
-	ms.mu.Lock()
-	defer ms.mu.Unlock()
+	if err := ms.AddMemoryContext(context.Background(), memory); err != nil {
+		log.Printf("Warning: Failed to store memory: %v", err)
+	}
+}
+
+// AddMemoryContext adds a new memory to short-term storage and, if it has a
+// type, to the long-term backend. Memories without an ID are given one. With
+// semantic search enabled, long-term memories are embedded and indexed.
+func (ms *MemoryStore) AddMemoryContext(ctx context.Context, memory Memory) error {
+	return ms.AddMemoriesContext(ctx, memory)
+}
+
+// AddMemoriesContext adds memories like AddMemoryContext, embedding them in a
+// single request when semantic search is enabled
+func (ms *MemoryStore) AddMemoriesContext(ctx context.Context, memories ...Memory) error {
+	memories = slices.Clone(memories)
+	var long []Memory // Memories for the long-term backend
+	for i := range memories {
+		memories[i] = withMemoryID(memories[i])
+		if memories[i].Type != "" {
+			long = append(long, memories[i])
+		}
+	}
+
+	ms.mu.RLock()
+	search := ms.semantic
+	ms.mu.RUnlock()
+	if search != nil {
+		if _, err := search.embedMissing(ctx, long); err != nil {
+			return err
+		}
+	}
 
+	ms.mu.Lock()
 	// Add to short-term memory
-	ms.shortTerm = append(ms.shortTerm, memory)
+	ms.shortTerm = append(ms.shortTerm, memories...)
 	if len(ms.shortTerm) > ms.maxShort {
-		// Remove oldest memory when capacity is exceeded
-		ms.shortTerm = ms.shortTerm[1:]
+		// Remove the oldest memories when capacity is exceeded
+		ms.shortTerm = ms.shortTerm[len(ms.shortTerm)-max(ms.maxShort, 0):]
 	}
+	ms.mu.Unlock()
 
 	// Add to long-term memory
-	if memory.Type != "" {
-		ms.longTerm[memory.Type] = append(ms.longTerm[memory.Type], memory)
+	for _, memory := range long {
+		if err := ms.backend.Add(ctx, memory); err != nil {
+			return err
+		}
+		if search != nil {
+			if err := search.index.Add(ctx, memory); err != nil {
+				return err
+			}
+		}
 	}
+	return nil
 }
 
 // GetRecentMemories retrieves the n most recent memories
@@ -59,78 +133,138 @@ func (ms *MemoryStore) GetRecentMemories(n int) []Memory {
 	if n > len(ms.shortTerm) {
 		n = len(ms.shortTerm)
 	}
-	
+
 	start := len(ms.shortTerm) - n
 	if start < 0 {
 		start = 0
 	}
-	
+
 	return ms.shortTerm[start:]
 }
 
-// SearchMemories searches for memories based on type and context
-func (ms *MemoryStore) SearchMemories(memoryType string, context map[string]interface{}) []Memory {
-	ms.mu.RLock()
-	defer ms.mu.RUnlock()
-
-	if memories, exists := ms.longTerm[memoryType]; exists {
-		if context == nil {
-			return memories
-		}
-
-		// Filter memories by context match
-		var filtered []Memory
-		for _, memory := range memories {
-			if matchContext(memory.Context, context) {
-				filtered = append(filtered, memory)
-			}
-		}
-		return filtered
+// SearchMemories searches long-term memories of memoryType by context, oldest
+// first. It returns nil for an empty or unknown type. Backend errors are logged
+// and return nil, use Query to handle them or to search across all types.
+func (ms *MemoryStore) SearchMemories(memoryType string, searchContext map[string]interface{}) []Memory {
+	if memoryType == "" {
+		return nil
 	}
-	return nil
+	memories, err := ms.Query(context.Background(), MemoryQuery{Type: memoryType, Context: searchContext})
+	if err != nil {
+		log.Printf("Warning: Failed to search memories: %v", err)
+		return nil
+	}
+	if len(memories) == 0 {
+		return nil
+	}
+	return memories
 }
 
-// matchContext checks if a memory's context matches the search context
+// Query searches long-term memories with the filters of query
+func (ms *MemoryStore) Query(ctx context.Context, query MemoryQuery) ([]Memory, error) {
+	return ms.backend.Search(ctx, query)
+}
+
+// matchContext checks if a memory's context matches the search context. Values
+// are compared by their JSON encoding, so numbers match after a round trip.
 func matchContext(memContext, searchContext map[string]interface{}) bool {
 	for key, searchVal := range searchContext {
-		if memVal, exists := memContext[key]; !exists || memVal != searchVal {
+		if memVal, exists := memContext[key]; !exists || contextValue(memVal) != contextValue(searchVal) {
 			return false
 		}
 	}
 	return true
 }
 
-// SerializeMemories serializes all memories to JSON
+// contextValue encodes a context value for comparison and indexing
+func contextValue(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
+
+// withMemoryID gives a memory a new ID if it has none
+func withMemoryID(memory Memory) Memory {
+	if memory.ID == "" {
+		memory.ID = uuid.New().String()
+	}
+	return memory
+}
+
+// serializedMemories is the JSON encoding of a MemoryStore
+type serializedMemories struct {
+	ShortTerm []Memory            `json:"short_term"`
+	LongTerm  map[string][]Memory `json:"long_term"`
+}
+
+// SerializeMemories serializes all memories to JSON. Persistent backends save
+// each memory as it is added, so this is only needed for exports.
 func (ms *MemoryStore) SerializeMemories() ([]byte, error) {
+	memories, err := ms.backend.Search(context.Background(), MemoryQuery{})
+	if err != nil {
+		return nil, err
+	}
+
 	ms.mu.RLock()
 	defer ms.mu.RUnlock()
 
-	data := struct {
-		ShortTerm []Memory              `json:"short_term"`
-		LongTerm  map[string][]Memory   `json:"long_term"`
-	}{
+	data := serializedMemories{
 		ShortTerm: ms.shortTerm,
-		LongTerm:  ms.longTerm,
+		LongTerm:  make(map[string][]Memory),
+	}
+	for _, memory := range memories {
+		data.LongTerm[memory.Type] = append(data.LongTerm[memory.Type], memory)
 	}
 
 	return json.Marshal(data)
 }
 
-// LoadMemories loads memories from JSON data
+// LoadMemories loads memories from JSON data, replacing the memories of the store
 func (ms *MemoryStore) LoadMemories(data []byte) error {
+	var loaded serializedMemories
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		return err
+	}
+
 	ms.mu.Lock()
 	defer ms.mu.Unlock()
 
-	var loaded struct {
-		ShortTerm []Memory              `json:"short_term"`
-		LongTerm  map[string][]Memory   `json:"long_term"`
+	var memories []Memory
+	for _, typed := range loaded.LongTerm {
+		for _, memory := range typed {
+			memories = append(memories, withMemoryID(memory))
+		}
 	}
 
-	if err := json.Unmarshal(data, &loaded); err != nil {
+	ctx := context.Background()
+	if ms.semantic != nil {
+		if _, err := ms.semantic.embedMissing(ctx, memories); err != nil {
+			return err
+		}
+		if err := ms.semantic.index.Clear(ctx); err != nil {
+			return err
+		}
+	}
+	if err := ms.backend.Clear(ctx); err != nil {
 		return err
 	}
-
+	for _, memory := range memories {
+		if err := ms.backend.Add(ctx, memory); err != nil {
+			return err
+		}
+		if ms.semantic != nil {
+			if err := ms.semantic.index.Add(ctx, memory); err != nil {
+				return err
+			}
+		}
+	}
 	ms.shortTerm = loaded.ShortTerm
-	ms.longTerm = loaded.LongTerm
 	return nil
 }
+
+// Close closes the long-term backend
+func (ms *MemoryStore) Close() error {
+	return ms.backend.Close()
+}
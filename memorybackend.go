@@ -0,0 +1,264 @@
+package swarmgo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryBackend stores the long-term memories of a MemoryStore. Adding a memory
+// with the ID of a stored one replaces it.
+type MemoryBackend interface {
+	Add(ctx context.Context, memory Memory) error
+	Search(ctx context.Context, query MemoryQuery) ([]Memory, error)
+	Clear(ctx context.Context) error
+	Close() error
+}
+
+// MemoryQuery filters memories. Results are ordered by timestamp, oldest first.
+type MemoryQuery struct {
+	Type    string                 // Empty matches all types
+	Context map[string]interface{} // Context values the memories must have
+	Since   time.Time              // Earliest timestamp, zero for no bound
+	Until   time.Time              // Latest timestamp, zero for no bound
+	Limit   int                    // Keep only the most recent matches, 0 for all
+}
+
+// matches reports whether a memory passes the filters of the query
+func (q MemoryQuery) matches(memory Memory) bool {
+	return (q.Type == "" || memory.Type == q.Type) &&
+		(q.Since.IsZero() || !memory.Timestamp.Before(q.Since)) &&
+		(q.Until.IsZero() || !memory.Timestamp.After(q.Until)) &&
+		matchContext(memory.Context, q.Context)
+}
+
+// limitMemories keeps the last n memories, all if n is 0
+func limitMemories(memories []Memory, n int) []Memory {
+	if n > 0 && len(memories) > n {
+		return memories[len(memories)-n:]
+	}
+	return memories
+}
+
+// memoryIndex indexes memories by type, context value and timestamp so
+// searches only scan the memories of the most selective filter
+type memoryIndex struct {
+	memories  []Memory
+	live      map[string]int   // Position of the current memory of each ID
+	byType    map[string][]int // Positions by type
+	byContext map[string][]int // Positions by context key and encoded value
+	byTime    []int            // Positions ordered by timestamp
+}
+
+func newMemoryIndex() *memoryIndex {
+	return &memoryIndex{
+		live:      make(map[string]int),
+		byType:    make(map[string][]int),
+		byContext: make(map[string][]int),
+	}
+}
+
+// contextIndexKey returns the byContext key of a context value
+func contextIndexKey(key string, value interface{}) string {
+	return key + "\x00" + contextValue(value)
+}
+
+// add indexes a memory, replacing any memory with the same ID
+func (x *memoryIndex) add(memory Memory) {
+	pos := len(x.memories)
+	x.memories = append(x.memories, memory)
+	x.live[memory.ID] = pos
+	x.byType[memory.Type] = append(x.byType[memory.Type], pos)
+	for key, value := range memory.Context {
+		indexKey := contextIndexKey(key, value)
+		x.byContext[indexKey] = append(x.byContext[indexKey], pos)
+	}
+
+	// Memories are usually added in order, so this inserts at the end
+	i := sort.Search(len(x.byTime), func(i int) bool {
+		return x.memories[x.byTime[i]].Timestamp.After(memory.Timestamp)
+	})
+	x.byTime = append(x.byTime, 0)
+	copy(x.byTime[i+1:], x.byTime[i:])
+	x.byTime[i] = pos
+}
+
+// search returns the live memories matching query
+func (x *memoryIndex) search(query MemoryQuery) []Memory {
+	// Start from the smallest posting list, or the timestamp range without one
+	var candidates []int
+	ordered := false
+	if query.Type != "" {
+		candidates = x.byType[query.Type]
+	}
+	for key, value := range query.Context {
+		list := x.byContext[contextIndexKey(key, value)]
+		if candidates == nil || len(list) < len(candidates) {
+			candidates = list
+		}
+		if len(list) == 0 {
+			return nil
+		}
+	}
+	if query.Type == "" && len(query.Context) == 0 {
+		start, end := 0, len(x.byTime)
+		if !query.Since.IsZero() {
+			start = sort.Search(len(x.byTime), func(i int) bool {
+				return !x.memories[x.byTime[i]].Timestamp.Before(query.Since)
+			})
+		}
+		if !query.Until.IsZero() {
+			end = sort.Search(len(x.byTime), func(i int) bool {
+				return x.memories[x.byTime[i]].Timestamp.After(query.Until)
+			})
+		}
+		candidates = x.byTime[start:max(start, end)]
+		ordered = true
+	}
+
+	var results []Memory
+	for _, pos := range candidates {
+		memory := x.memories[pos]
+		if x.live[memory.ID] == pos && query.matches(memory) {
+			results = append(results, memory)
+		}
+	}
+	if !ordered {
+		sort.SliceStable(results, func(i, j int) bool {
+			return results[i].Timestamp.Before(results[j].Timestamp)
+		})
+	}
+	return limitMemories(results, query.Limit)
+}
+
+// InMemoryBackend keeps memories in memory. It is the default backend of NewMemoryStore.
+type InMemoryBackend struct {
+	mu    sync.RWMutex
+	index *memoryIndex
+}
+
+// NewInMemoryBackend creates an empty in-memory backend
+func NewInMemoryBackend() *InMemoryBackend {
+	return &InMemoryBackend{index: newMemoryIndex()}
+}
+
+// Add stores a memory
+func (b *InMemoryBackend) Add(ctx context.Context, memory Memory) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.index.add(withMemoryID(memory))
+	return nil
+}
+
+// Search returns the memories matching query
+func (b *InMemoryBackend) Search(ctx context.Context, query MemoryQuery) ([]Memory, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.index.search(query), nil
+}
+
+// Clear removes all memories
+func (b *InMemoryBackend) Clear(ctx context.Context) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.index = newMemoryIndex()
+	return nil
+}
+
+// Close does nothing, in-memory backends hold no resources
+func (b *InMemoryBackend) Close() error {
+	return nil
+}
+
+// FileMemoryBackend appends each memory to a JSON lines file and keeps an index
+// in memory. Opening the file replays it, later records replacing earlier ones
+// with the same ID.
+type FileMemoryBackend struct {
+	mu    sync.RWMutex
+	file  *os.File
+	index *memoryIndex
+}
+
+// NewFileMemoryBackend opens or creates the memory file at path. A record cut
+// off by a crash during a write is dropped.
+func NewFileMemoryBackend(path string) (*FileMemoryBackend, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("error creating memory directory: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	index := newMemoryIndex()
+	decoder := json.NewDecoder(file)
+	var valid int64
+	for {
+		var memory Memory
+		err := decoder.Decode(&memory)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			if err = file.Truncate(valid); err == nil {
+				break
+			}
+		}
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("error reading %s: %w", path, err)
+		}
+		index.add(withMemoryID(memory))
+		valid = decoder.InputOffset()
+	}
+	return &FileMemoryBackend{file: file, index: index}, nil
+}
+
+// Add appends a memory to the file
+func (b *FileMemoryBackend) Add(ctx context.Context, memory Memory) error {
+	memory = withMemoryID(memory)
+	data, err := json.Marshal(memory)
+	if err != nil {
+		return fmt.Errorf("error encoding memory %s: %w", memory.ID, err)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, err := b.file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	b.index.add(memory)
+	return nil
+}
+
+// Search returns the memories matching query
+func (b *FileMemoryBackend) Search(ctx context.Context, query MemoryQuery) ([]Memory, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.index.search(query), nil
+}
+
+// Clear removes all memories and empties the file
+func (b *FileMemoryBackend) Clear(ctx context.Context) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.file.Truncate(0); err != nil {
+		return err
+	}
+	b.index = newMemoryIndex()
+	return nil
+}
+
+// Close closes the file
+func (b *FileMemoryBackend) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.file.Close()
+}
@@ -0,0 +1,187 @@
+package swarmgo
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryBackends creates one backend of each kind, with a function that reopens it
+func memoryBackends(t *testing.T) map[string]func() MemoryBackend {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "memories.jsonl")
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "memories.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	inMemory := NewInMemoryBackend()
+	return map[string]func() MemoryBackend{
+		"memory": func() MemoryBackend { return inMemory },
+		"file": func() MemoryBackend {
+			backend, err := NewFileMemoryBackend(path)
+			require.NoError(t, err)
+			t.Cleanup(func() { backend.Close() })
+			return backend
+		},
+		"sqlite": func() MemoryBackend {
+			backend, err := NewSQLiteMemoryBackend(context.Background(), db, "agent")
+			require.NoError(t, err)
+			return backend
+		},
+	}
+}
+
+// TestMemoryBackends tests searching by type, context, time range and limit,
+// replacing by ID and that memories survive reopening the backend
+func TestMemoryBackends(t *testing.T) {
+	ctx := context.Background()
+	start := time.Now()
+	for name, open := range memoryBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			backend := open()
+			memories := []Memory{
+				{ID: "1", Content: "likes tea", Type: "preference", Context: map[string]interface{}{"user": "ada"}, Timestamp: start},
+				{ID: "2", Content: "lives in Paris", Type: "fact", Context: map[string]interface{}{"user": "ada", "turn": 2}, Timestamp: start.Add(2 * time.Minute)},
+				{ID: "3", Content: "likes coffee", Type: "preference", Context: map[string]interface{}{"user": "bob"}, Timestamp: start.Add(time.Minute)},
+			}
+			for _, memory := range memories {
+				require.NoError(t, backend.Add(ctx, memory))
+			}
+			if name != "memory" {
+				backend = open()
+			}
+
+			all, err := backend.Search(ctx, MemoryQuery{})
+			require.NoError(t, err)
+			assert.Equal(t, []string{"likes tea", "likes coffee", "lives in Paris"}, memoryContents(all))
+
+			found, err := backend.Search(ctx, MemoryQuery{Type: "preference", Context: map[string]interface{}{"user": "ada"}})
+			require.NoError(t, err)
+			assert.Equal(t, []string{"likes tea"}, memoryContents(found))
+
+			// Numbers match after a JSON round trip
+			found, err = backend.Search(ctx, MemoryQuery{Context: map[string]interface{}{"turn": 2}})
+			require.NoError(t, err)
+			assert.Equal(t, []string{"lives in Paris"}, memoryContents(found))
+
+			found, err = backend.Search(ctx, MemoryQuery{Since: start.Add(time.Minute), Until: start.Add(time.Minute)})
+			require.NoError(t, err)
+			assert.Equal(t, []string{"likes coffee"}, memoryContents(found))
+
+			found, err = backend.Search(ctx, MemoryQuery{Limit: 2})
+			require.NoError(t, err)
+			assert.Equal(t, []string{"likes coffee", "lives in Paris"}, memoryContents(found))
+
+			require.NoError(t, backend.Add(ctx, Memory{ID: "3", Content: "likes cocoa", Type: "preference", Context: map[string]interface{}{"user": "cy"}, Timestamp: start.Add(time.Minute)}))
+			found, err = backend.Search(ctx, MemoryQuery{Type: "preference"})
+			require.NoError(t, err)
+			assert.Equal(t, []string{"likes tea", "likes cocoa"}, memoryContents(found))
+			found, err = backend.Search(ctx, MemoryQuery{Context: map[string]interface{}{"user": "bob"}})
+			require.NoError(t, err)
+			assert.Empty(t, found)
+
+			require.NoError(t, backend.Clear(ctx))
+			all, err = backend.Search(ctx, MemoryQuery{})
+			require.NoError(t, err)
+			assert.Empty(t, all)
+		})
+	}
+}
+
+// TestSQLiteMemoryBackendNamespaces tests that namespaces sharing a database
+// keep memories with the same ID apart
+func TestSQLiteMemoryBackendNamespaces(t *testing.T) {
+	ctx := context.Background()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "memories.db"))
+	require.NoError(t, err)
+	defer db.Close()
+	alice, err := NewSQLiteMemoryBackend(ctx, db, "alice")
+	require.NoError(t, err)
+	bob, err := NewSQLiteMemoryBackend(ctx, db, "bob")
+	require.NoError(t, err)
+
+	require.NoError(t, alice.Add(ctx, Memory{ID: "1", Content: "likes tea", Type: "preference", Context: map[string]interface{}{"topic": "drinks"}}))
+	require.NoError(t, bob.Add(ctx, Memory{ID: "1", Content: "likes coffee", Type: "preference", Context: map[string]interface{}{"topic": "drinks"}}))
+	require.NoError(t, bob.Add(ctx, Memory{ID: "1", Content: "likes cocoa", Type: "preference", Context: map[string]interface{}{"mood": "cosy"}}))
+
+	found, err := alice.Search(ctx, MemoryQuery{Context: map[string]interface{}{"topic": "drinks"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"likes tea"}, memoryContents(found))
+	found, err = bob.Search(ctx, MemoryQuery{Context: map[string]interface{}{"mood": "cosy"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"likes cocoa"}, memoryContents(found))
+
+	require.NoError(t, bob.Clear(ctx))
+	found, err = alice.Search(ctx, MemoryQuery{Context: map[string]interface{}{"topic": "drinks"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"likes tea"}, memoryContents(found))
+}
+
+// TestFileMemoryBackendTruncatedRecord tests that a record cut off by a crash is dropped
+func TestFileMemoryBackendTruncatedRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "memories.jsonl")
+	backend, err := NewFileMemoryBackend(path)
+	require.NoError(t, err)
+	require.NoError(t, backend.Add(context.Background(), Memory{Content: "kept", Type: "fact"}))
+	require.NoError(t, backend.Close())
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	_, err = file.WriteString(`{"content":"lost","ty`)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	backend, err = NewFileMemoryBackend(path)
+	require.NoError(t, err)
+	defer backend.Close()
+	require.NoError(t, backend.Add(context.Background(), Memory{Content: "added", Type: "fact"}))
+	all, err := backend.Search(context.Background(), MemoryQuery{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"kept", "added"}, memoryContents(all))
+}
+
+// TestMemoryStoreWithBackend tests that a store resumes its short-term buffer
+// from a persistent backend and exports and imports its memories
+func TestMemoryStoreWithBackend(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "memories.jsonl")
+	backend, err := NewFileMemoryBackend(path)
+	require.NoError(t, err)
+	store, err := NewMemoryStoreWithBackend(ctx, 2, backend)
+	require.NoError(t, err)
+	for i, content := range []string{"a", "b", "c"} {
+		require.NoError(t, store.AddMemoryContext(ctx, Memory{Content: content, Type: "fact", Timestamp: time.Now().Add(time.Duration(i) * time.Second)}))
+	}
+	require.NoError(t, store.AddMemoryContext(ctx, Memory{Content: "untyped"}))
+	data, err := store.SerializeMemories()
+	require.NoError(t, err)
+	require.NoError(t, store.Close())
+
+	backend, err = NewFileMemoryBackend(path)
+	require.NoError(t, err)
+	store, err = NewMemoryStoreWithBackend(ctx, 2, backend)
+	require.NoError(t, err)
+	defer store.Close()
+	assert.Equal(t, []string{"b", "c"}, memoryContents(store.GetRecentMemories(5)))
+	assert.Len(t, store.SearchMemories("fact", nil), 3)
+	assert.Nil(t, store.SearchMemories("", nil))
+	assert.Nil(t, store.SearchMemories("unknown", nil))
+
+	require.NoError(t, store.LoadMemories(data))
+	assert.Equal(t, []string{"c", "untyped"}, memoryContents(store.GetRecentMemories(5)))
+	assert.Equal(t, []string{"a", "b", "c"}, memoryContents(store.SearchMemories("fact", nil)))
+}
+
+func memoryContents(memories []Memory) []string {
+	texts := make([]string, len(memories))
+	for i, memory := range memories {
+		texts[i] = memory.Content
+	}
+	return texts
+}
@@ -0,0 +1,164 @@
+package swarmgo
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mohan2020coder/swarmgo/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestOllamaStreamsToolCalls tests host selection, options, auto-pull and streamed tool calls
+func TestOllamaStreamsToolCalls(t *testing.T) {
+	var chatBody map[string]interface{}
+	var pulled []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/tags":
+			_, _ = w.Write([]byte(`{"models":[{"name":"other:latest"}]}`))
+		case "/api/pull":
+			var body map[string]interface{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			pulled = append(pulled, body["model"].(string))
+			_, _ = io.WriteString(w, `{"status":"pulling","digest":"sha256:1","total":10,"completed":5}`+"\n")
+			_, _ = io.WriteString(w, `{"status":"success"}`+"\n")
+		case "/api/chat":
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&chatBody))
+			_, _ = io.WriteString(w, `{"model":"llama3.2","message":{"role":"assistant","content":"Checking"},"done":false}`+"\n")
+			_, _ = io.WriteString(w, `{"model":"llama3.2","message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"lookup","arguments":{"q":"swarm"}}}]},"done":false}`+"\n")
+			_, _ = io.WriteString(w, `{"model":"llama3.2","message":{"role":"assistant","content":""},"done":true,"done_reason":"stop","prompt_eval_count":7,"eval_count":3}`+"\n")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	var progress []llm.PullProgress
+	client, err := NewClientFromConfig(&ClientConfig{
+		Provider:  llm.Ollama,
+		AuthToken: server.URL,
+		Options: map[string]interface{}{
+			OptionOllama: llm.OllamaOptions{
+				KeepAlive:    5 * time.Minute,
+				Options:      map[string]interface{}{"num_ctx": 8192},
+				AutoPull:     true,
+				PullProgress: func(p llm.PullProgress) { progress = append(progress, p) },
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	stream, err := client.CreateChatCompletionStream(context.Background(), llm.ChatCompletionRequest{
+		Model:       "llama3.2",
+		Messages:    []llm.Message{{Role: llm.RoleUser, Content: "find swarm"}},
+		Temperature: 0.2,
+		Tools: []llm.Tool{{Type: "function", Function: &llm.Function{
+			Name: "lookup",
+			Parameters: map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{"q": map[string]interface{}{"type": []interface{}{"string", "null"}}},
+				"required":   []interface{}{"q"},
+			},
+		}}},
+	})
+	require.NoError(t, err)
+	defer stream.Close()
+
+	var content string
+	var calls []llm.ToolCall
+	var last llm.ChatCompletionResponse
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		content += resp.Choices[0].Message.Content
+		calls = append(calls, resp.Choices[0].Message.ToolCalls...)
+		last = resp
+	}
+
+	assert.Equal(t, []string{"llama3.2"}, pulled)
+	require.Len(t, progress, 2)
+	assert.Equal(t, int64(5), progress[0].Completed)
+
+	assert.Equal(t, "Checking", content)
+	require.Len(t, calls, 1)
+	assert.Equal(t, "lookup", calls[0].Function.Name)
+	assert.NotEmpty(t, calls[0].ID)
+	assert.JSONEq(t, `{"q":"swarm"}`, calls[0].Function.Arguments)
+	assert.Equal(t, "tool_calls", last.Choices[0].FinishReason)
+	assert.Equal(t, 10, last.Usage.TotalTokens)
+
+	assert.Equal(t, "5m0s", chatBody["keep_alive"])
+	options := chatBody["options"].(map[string]interface{})
+	assert.Equal(t, float64(8192), options["num_ctx"])
+	assert.Equal(t, 0.2, options["temperature"])
+	tools := chatBody["tools"].([]interface{})
+	params := tools[0].(map[string]interface{})["function"].(map[string]interface{})["parameters"].(map[string]interface{})
+	assert.Equal(t, "string", params["properties"].(map[string]interface{})["q"].(map[string]interface{})["type"])
+}
+
+// TestOllamaAutoPullPerModel tests that concurrent requests share the pull of a
+// model and that a slow pull does not hold up requests for other models
+func TestOllamaAutoPullPerModel(t *testing.T) {
+	release := make(chan struct{})
+	var mu sync.Mutex
+	pulls := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/tags":
+			_, _ = w.Write([]byte(`{"models":[]}`))
+		case "/api/pull":
+			var body map[string]interface{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			model := body["model"].(string)
+			mu.Lock()
+			pulls[model]++
+			mu.Unlock()
+			if model == "big-model" {
+				<-release
+			}
+			_, _ = io.WriteString(w, `{"status":"success"}`+"\n")
+		case "/api/chat":
+			_, _ = io.WriteString(w, `{"message":{"role":"assistant","content":"ok"},"done":true,"done_reason":"stop"}`+"\n")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client, err := llm.NewOllamaLLMWithClient(server.URL, nil, llm.OllamaOptions{AutoPull: true})
+	require.NoError(t, err)
+	request := func(model string) error {
+		_, err := client.CreateChatCompletion(context.Background(), llm.ChatCompletionRequest{
+			Model:    model,
+			Messages: []llm.Message{{Role: llm.RoleUser, Content: "hi"}},
+		})
+		return err
+	}
+
+	var wg sync.WaitGroup
+	for range 3 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, request("big-model"))
+		}()
+	}
+
+	// Another model is pulled and answered while big-model is still pulling
+	require.NoError(t, request("small-model"))
+	close(release)
+	wg.Wait()
+	require.NoError(t, request("big-model"))
+
+	assert.Equal(t, map[string]int{"big-model": 1, "small-model": 1}, pulls)
+}
@@ -0,0 +1,261 @@
+package swarmgo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mohan2020coder/swarmgo/llm"
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// toolCallChunks stream one tool call in three fragments
+var toolCallChunks = []string{
+	`{"id":"1","choices":[{"index":0,"delta":{"role":"assistant","tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"get_weather","arguments":""}}]}}]}`,
+	`{"id":"1","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"city\":"}}]}}]}`,
+	`{"id":"1","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"Paris\"}"}}]}}]}`,
+	`{"id":"1","choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`,
+	`{"id":"1","choices":[],"usage":{"prompt_tokens":10,"completion_tokens":5,"total_tokens":15}}`,
+}
+
+// streamChunks starts a server that streams chunks as server-sent events
+func streamChunks(t *testing.T, chunks []string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "secret", r.Header.Get("X-Api-Token"))
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, chunk := range chunks {
+			fmt.Fprintf(w, "data: %s\n\n", chunk)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+}
+
+// TestOpenAICompatibleStreamAssemblesToolCalls tests that tool call fragments are passed
+// through and the assembled calls are sent with the finish chunk
+func TestOpenAICompatibleStreamAssemblesToolCalls(t *testing.T) {
+	server := streamChunks(t, toolCallChunks)
+	defer server.Close()
+
+	client := llm.NewOpenAICompatibleLLM(llm.OpenAICompatibleConfig{
+		BaseURL: server.URL + "/v1",
+		Headers: map[string]string{"X-Api-Token": "secret"},
+		Profile: llm.DefaultCompatibilityProfile(),
+	})
+	stream, err := client.CreateChatCompletionStream(context.Background(), llm.ChatCompletionRequest{
+		Model:    "local-model",
+		Messages: []llm.Message{{Role: llm.RoleUser, Content: "Weather in Paris?"}},
+	})
+	require.NoError(t, err)
+	defer stream.Close()
+
+	var acc llm.StreamAccumulator
+	var fragments, assembled []llm.ToolCall
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		acc.Add(resp)
+		for _, choice := range resp.Choices {
+			fragments = append(fragments, choice.Message.ToolCalls...)
+			assembled = append(assembled, choice.AssembledToolCalls...)
+		}
+	}
+
+	assert.Len(t, fragments, 3)
+	assert.Equal(t, acc.Message().ToolCalls, assembled)
+	toolCalls := acc.Message().ToolCalls
+	require.Len(t, toolCalls, 1)
+	assert.Equal(t, "call_1", toolCalls[0].ID)
+	assert.Equal(t, "get_weather", toolCalls[0].Function.Name)
+	assert.Equal(t, `{"city":"Paris"}`, toolCalls[0].Function.Arguments)
+	assert.Equal(t, 15, acc.Usage().TotalTokens)
+}
+
+// TestRunStreamEmitsToolCallFragments tests that tool call arguments render live
+func TestRunStreamEmitsToolCallFragments(t *testing.T) {
+	server := streamChunks(t, toolCallChunks)
+	defer server.Close()
+
+	client := llm.NewOpenAICompatibleLLM(llm.OpenAICompatibleConfig{
+		BaseURL: server.URL + "/v1",
+		Headers: map[string]string{"X-Api-Token": "secret"},
+		Profile: llm.DefaultCompatibilityProfile(),
+	})
+	agent := &Agent{Name: "Weather", Model: "local-model"}
+	swarm := NewSwarmWithCustomProvider(client, nil)
+
+	var deltas []llm.ToolCall
+	var complete []llm.ToolCall
+	for event := range swarm.RunStream(context.Background(), agent, []llm.Message{{Role: llm.RoleUser, Content: "Weather in Paris?"}}, nil, "", false, 1, false) {
+		switch event.Type {
+		case EventToolCallDelta:
+			deltas = append(deltas, *event.ToolCall)
+		case EventToolCallComplete:
+			complete = append(complete, *event.ToolCall)
+		}
+	}
+
+	require.Greater(t, len(deltas), 1)
+	for _, delta := range deltas {
+		assert.Equal(t, 0, delta.Index)
+	}
+	assert.Equal(t, "call_1", deltas[0].ID)
+	require.Len(t, complete, 1)
+	assert.Equal(t, `{"city":"Paris"}`, complete[0].Function.Arguments)
+}
+
+// TestOpenAICompatibleProfileDegradesRequest tests system role folding, tool removal and tool result IDs
+func TestOpenAICompatibleProfileDegradesRequest(t *testing.T) {
+	var body struct {
+		Messages []map[string]interface{} `json:"messages"`
+		Tools    []interface{}            `json:"tools"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"1","choices":[{"index":0,"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	messages := []llm.Message{
+		{Role: llm.RoleSystem, Content: "Be brief."},
+		{Role: llm.RoleUser, Content: "Hi"},
+	}
+	tools := []llm.Tool{{Type: "function", Function: &llm.Function{Name: "noop"}}}
+
+	limited := llm.NewOpenAICompatibleLLM(llm.OpenAICompatibleConfig{BaseURL: server.URL})
+	_, err := limited.CreateChatCompletion(context.Background(), llm.ChatCompletionRequest{Model: "m", Messages: messages, Tools: tools})
+	require.NoError(t, err)
+	require.Len(t, body.Messages, 1)
+	assert.Equal(t, "user", body.Messages[0]["role"])
+	assert.Equal(t, "Be brief.\n\nHi", body.Messages[0]["content"])
+	assert.Empty(t, body.Tools)
+
+	full := llm.NewOpenAICompatibleLLM(llm.OpenAICompatibleConfig{BaseURL: server.URL, Profile: llm.DefaultCompatibilityProfile()})
+	call := llm.ToolCall{ID: "call_9", Type: "function", Function: llm.ToolCallFunction{Name: "noop", Arguments: "{}"}}
+	_, err = full.CreateChatCompletion(context.Background(), llm.ChatCompletionRequest{
+		Model: "m",
+		Messages: append(messages,
+			llm.Message{Role: llm.RoleAssistant, Name: "Support Agent", ToolCalls: []llm.ToolCall{call}},
+			llm.Message{Role: llm.RoleFunction, Name: "noop", Content: "done"},
+		),
+		Tools: tools,
+	})
+	require.NoError(t, err)
+	require.Len(t, body.Messages, 4)
+	assert.Nil(t, body.Messages[2]["name"], "invalid names are not sent")
+	assert.Equal(t, "tool", body.Messages[3]["role"])
+	assert.Equal(t, "call_9", body.Messages[3]["tool_call_id"])
+	assert.Len(t, body.Tools, 1)
+}
+
+// TestOpenAIStreamingInterleavedToolCalls tests interleaved tool call deltas end to end,
+// with IDs and names only on the first delta of each call
+func TestOpenAIStreamingInterleavedToolCalls(t *testing.T) {
+	turn := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		turn++
+		chunks := []string{`{"id":"2","choices":[{"index":0,"delta":{"content":"Sunny in both."},"finish_reason":"stop"}]}`}
+		if turn == 1 {
+			chunks = []string{
+				`{"id":"1","choices":[{"index":0,"delta":{"role":"assistant","tool_calls":[{"index":0,"id":"call_paris","type":"function","function":{"name":"get_weather","arguments":""}}]}}]}`,
+				`{"id":"1","choices":[{"index":0,"delta":{"tool_calls":[{"index":1,"id":"call_rome","type":"function","function":{"name":"get_weather","arguments":"{\"city\":"}}]}}]}`,
+				`{"id":"1","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{}"}}]}}]}`,
+				`{"id":"1","choices":[{"index":0,"delta":{"tool_calls":[{"index":1,"function":{"arguments":"\"Rome\"}"}}]}}]}`,
+				`{"id":"1","choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`,
+			}
+		}
+		for _, chunk := range chunks {
+			fmt.Fprintf(w, "data: %s\n\n", chunk)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	var cities []interface{}
+	agent := &Agent{Name: "Weather", Model: "gpt-4o", Functions: []AgentFunction{{
+		Name: "get_weather",
+		Function: func(args map[string]interface{}, _ map[string]interface{}) Result {
+			cities = append(cities, args["city"])
+			return Result{Success: true, Data: "sunny"}
+		},
+	}}}
+	swarm := NewSwarmFromClientConfig(&ClientConfig{Provider: llm.OpenAI, AuthToken: "test-key", BaseURL: server.URL}, nil)
+
+	resp, err := swarm.StreamingResponse(context.Background(), agent,
+		[]llm.Message{{Role: llm.RoleUser, Content: "Weather in Paris and Rome?"}}, nil, "", nil, false, 3)
+	require.NoError(t, err)
+
+	// The Paris call received "{}" before any real arguments, it must not run early or twice
+	assert.Equal(t, []interface{}{nil, "Rome"}, cities)
+	calls := resp.Messages[0].ToolCalls
+	require.Len(t, calls, 2)
+	assert.Equal(t, "call_paris", calls[0].ID)
+	assert.Equal(t, "call_rome", calls[1].ID)
+	assert.Equal(t, `{"city":"Rome"}`, calls[1].Function.Arguments)
+	assert.Equal(t, "Sunny in both.", resp.Messages[3].Content)
+}
+
+// TestStreamAccumulator tests assembling chunks into a response
+func TestStreamAccumulator(t *testing.T) {
+	var acc llm.StreamAccumulator
+	acc.Add(llm.ChatCompletionResponse{ID: "1", Choices: []llm.Choice{{Message: llm.Message{Role: llm.RoleAssistant, Content: "Let me ", Reasoning: "user wants"}}}})
+	acc.Add(llm.ChatCompletionResponse{Choices: []llm.Choice{{Message: llm.Message{Content: "check.", ToolCalls: []llm.ToolCall{
+		{Index: 0, ID: "call_a", Function: llm.ToolCallFunction{Name: "a", Arguments: `{"x":`}},
+		{Index: 1, Function: llm.ToolCallFunction{Name: "b"}},
+	}}}}})
+	acc.Add(llm.ChatCompletionResponse{Choices: []llm.Choice{{Message: llm.Message{ToolCalls: []llm.ToolCall{
+		{Index: 0, Function: llm.ToolCallFunction{Arguments: `1}`}},
+	}}, FinishReason: "tool_calls"}}})
+	// Providers that send whole calls reuse the index with a new ID
+	acc.Add(llm.ChatCompletionResponse{Choices: []llm.Choice{{Message: llm.Message{ToolCalls: []llm.ToolCall{
+		{ID: "call_c", Function: llm.ToolCallFunction{Name: "c", Arguments: "{}"}},
+	}}}}, Usage: llm.Usage{TotalTokens: 9}})
+
+	resp := acc.Response()
+	assert.Equal(t, "1", resp.ID)
+	assert.Equal(t, 9, resp.Usage.TotalTokens)
+	assert.Equal(t, "tool_calls", acc.FinishReason())
+
+	message := acc.Message()
+	assert.Equal(t, "Let me check.", message.Content)
+	assert.Equal(t, "user wants", message.Reasoning)
+	require.Len(t, message.ToolCalls, 3)
+	assert.Equal(t, `{"x":1}`, message.ToolCalls[0].Function.Arguments)
+	assert.Equal(t, "call_1", message.ToolCalls[1].ID)
+	assert.Equal(t, "{}", message.ToolCalls[1].Function.Arguments)
+	assert.Equal(t, "call_c", message.ToolCalls[2].ID)
+}
+
+// TestOpenAICompatibleEmptyMessagesLimit tests that a stream of keep-alive lines
+// without data is cut off after the configured limit
+func TestOpenAICompatibleEmptyMessagesLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for range 5 {
+			fmt.Fprint(w, ": keep-alive\n")
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	client := llm.NewOpenAICompatibleLLM(llm.OpenAICompatibleConfig{BaseURL: server.URL, EmptyMessagesLimit: 3})
+	stream, err := client.CreateChatCompletionStream(context.Background(), llm.ChatCompletionRequest{
+		Model:    "local-model",
+		Messages: []llm.Message{{Role: llm.RoleUser, Content: "Hi"}},
+	})
+	require.NoError(t, err)
+	defer stream.Close()
+
+	_, err = stream.Recv()
+	assert.ErrorIs(t, err, openai.ErrTooManyEmptyStreamMessages)
+}
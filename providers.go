@@ -0,0 +1,127 @@
+package swarmgo
+
+import (
+	"log"
+	"sync"
+
+	"github.com/mohan2020coder/swarmgo/llm"
+)
+
+// providerRegistry lazily builds and caches the clients used by agents whose
+// provider differs from the swarm default
+type providerRegistry struct {
+	mu            sync.Mutex
+	configs       map[llm.LLMProvider]*ClientConfig // Registered per-provider configs
+	clients       map[llm.LLMProvider]llm.LLM       // Clients built from registered configs or set directly
+	configClients map[*ClientConfig]llm.LLM         // Clients built from agent configs
+}
+
+// RegisterProvider registers the configuration used for agents that select
+// config.Provider without carrying their own ClientConfig. The client is built
+// lazily on first use.
+func (s *Swarm) RegisterProvider(config *ClientConfig) {
+	s.registry.mu.Lock()
+	defer s.registry.mu.Unlock()
+
+	if s.registry.configs == nil {
+		s.registry.configs = make(map[llm.LLMProvider]*ClientConfig)
+	}
+	s.registry.configs[config.Provider] = config
+	delete(s.registry.clients, config.Provider)
+}
+
+// RegisterClient registers a ready-made client for a provider
+func (s *Swarm) RegisterClient(provider llm.LLMProvider, client llm.LLM) {
+	s.registry.mu.Lock()
+	defer s.registry.mu.Unlock()
+
+	if s.registry.clients == nil {
+		s.registry.clients = make(map[llm.LLMProvider]llm.LLM)
+	}
+	s.registry.clients[provider] = client
+}
+
+// clientFor returns the client and provider an agent's requests are routed to.
+// Agents with a ClientConfig get a client built from it, agents with only a
+// Provider use the client registered for that provider. Anything that cannot
+// be resolved falls back to the swarm default.
+func (s *Swarm) clientFor(agent *Agent) (llm.LLM, llm.LLMProvider) {
+	if agent == nil {
+		return s.client, s.provider
+	}
+
+	if agent.Config != nil {
+		provider := agent.Config.Provider
+		if provider == "" {
+			provider = agent.Provider
+		}
+		if client := s.registry.fromConfig(agent.Config, provider); client != nil {
+			return client, provider
+		}
+		return s.client, s.provider
+	}
+
+	if agent.Provider == "" || (agent.Provider == s.provider && s.client != nil) {
+		return s.client, s.provider
+	}
+	if client := s.registry.forProvider(agent.Provider); client != nil {
+		return client, agent.Provider
+	}
+	return s.client, s.provider
+}
+
+// fromConfig returns the cached client for an agent config, building it if needed
+func (r *providerRegistry) fromConfig(config *ClientConfig, provider llm.LLMProvider) llm.LLM {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if client, ok := r.configClients[config]; ok {
+		return client
+	}
+
+	resolved := *config
+	resolved.Provider = provider
+	client, err := NewClientFromConfig(&resolved)
+	if err != nil {
+		log.Printf("Failed to create %s client from agent config, using swarm default: %v", provider, err)
+		client = nil
+	}
+
+	// Cache failures too so a broken config is not rebuilt on every request
+	if r.configClients == nil {
+		r.configClients = make(map[*ClientConfig]llm.LLM)
+	}
+	r.configClients[config] = client
+	return client
+}
+
+// forProvider returns the client for a provider, building it from the registered
+// config when needed. Providers that work without credentials are built on demand.
+func (r *providerRegistry) forProvider(provider llm.LLMProvider) llm.LLM {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if client, ok := r.clients[provider]; ok {
+		return client
+	}
+
+	config, ok := r.configs[provider]
+	if !ok {
+		if requiresAuthToken(provider) {
+			return nil
+		}
+		config = &ClientConfig{Provider: provider}
+	}
+
+	client, err := NewClientFromConfig(config)
+	if err != nil {
+		log.Printf("Failed to create %s client, using swarm default: %v", provider, err)
+		client = nil
+	}
+
+	if r.clients == nil {
+		r.clients = make(map[llm.LLMProvider]llm.LLM)
+	}
+	r.clients[provider] = client
+	return client
+}
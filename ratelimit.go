@@ -0,0 +1,291 @@
+package swarmgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mohan2020coder/swarmgo/llm"
+)
+
+// ErrRateLimitExceeded is returned when a request does not fit in the current budget
+// and the swarm is configured with RateLimitFail.
+var ErrRateLimitExceeded = errors.New("client-side rate limit exceeded")
+
+// RateLimit describes the budget for a provider/model pair.
+// A zero value for either field disables that dimension.
+type RateLimit struct {
+	RequestsPerMinute int // Maximum number of requests per minute
+	TokensPerMinute   int // Maximum number of estimated tokens per minute
+}
+
+// RateLimiter enforces requests-per-minute and tokens-per-minute budgets using
+// token buckets keyed by provider and model. Callers waiting on the same key are
+// served in FIFO order. A single RateLimiter can be shared between Swarm,
+// ConcurrentSwarm and Graph instances.
+type RateLimiter struct {
+	mu           sync.Mutex
+	limits       map[string]RateLimit
+	defaultLimit RateLimit
+	buckets      map[string]*rateBucket
+	now          func() time.Time
+}
+
+// rateBucket holds the request and token buckets for a single provider/model pair
+type rateBucket struct {
+	mu       sync.Mutex
+	requests *tokenBucket
+	tokens   *tokenBucket
+	queue    []chan struct{} // FIFO of waiters, the head may consume
+}
+
+// tokenBucket is a continuously refilling bucket with a per-minute capacity
+type tokenBucket struct {
+	capacity  float64
+	available float64
+	last      time.Time
+}
+
+// NewRateLimiter creates a rate limiter that applies defaultLimit to every
+// provider/model pair without a more specific limit.
+func NewRateLimiter(defaultLimit RateLimit) *RateLimiter {
+	return &RateLimiter{
+		limits:       make(map[string]RateLimit),
+		defaultLimit: defaultLimit,
+		buckets:      make(map[string]*rateBucket),
+		now:          time.Now,
+	}
+}
+
+// SetLimit sets the budget for a provider and model. An empty model applies the
+// limit to every model of the provider; each model still gets its own bucket.
+func (rl *RateLimiter) SetLimit(provider llm.LLMProvider, model string, limit RateLimit) *RateLimiter {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	rl.limits[limitKey(provider, model)] = limit
+
+	// Drop existing buckets so the new limit takes effect on the next request.
+	// A provider-wide limit replaces the buckets of the provider's models that
+	// have no limit of their own.
+	prefix := limitKey(provider, "")
+	for key := range rl.buckets {
+		if key == limitKey(provider, model) {
+			delete(rl.buckets, key)
+		} else if _, own := rl.limits[key]; model == "" && !own && strings.HasPrefix(key, prefix) {
+			delete(rl.buckets, key)
+		}
+	}
+	return rl
+}
+
+// limitKey builds the map key for a provider/model pair
+func limitKey(provider llm.LLMProvider, model string) string {
+	return string(provider) + "/" + model
+}
+
+// limitFor resolves the most specific limit for a provider/model pair
+func (rl *RateLimiter) limitFor(provider llm.LLMProvider, model string) RateLimit {
+	if limit, ok := rl.limits[limitKey(provider, model)]; ok {
+		return limit
+	}
+	if limit, ok := rl.limits[limitKey(provider, "")]; ok {
+		return limit
+	}
+	return rl.defaultLimit
+}
+
+// bucket returns the bucket for a provider/model pair, or nil when it is unlimited
+func (rl *RateLimiter) bucket(provider llm.LLMProvider, model string) *rateBucket {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	key := limitKey(provider, model)
+	if b, ok := rl.buckets[key]; ok {
+		return b
+	}
+
+	limit := rl.limitFor(provider, model)
+	if limit.RequestsPerMinute <= 0 && limit.TokensPerMinute <= 0 {
+		return nil
+	}
+
+	now := rl.now()
+	b := &rateBucket{
+		requests: newTokenBucket(limit.RequestsPerMinute, now),
+		tokens:   newTokenBucket(limit.TokensPerMinute, now),
+	}
+	rl.buckets[key] = b
+	return b
+}
+
+// newTokenBucket creates a full bucket, or nil when the limit is disabled
+func newTokenBucket(perMinute int, now time.Time) *tokenBucket {
+	if perMinute <= 0 {
+		return nil
+	}
+	return &tokenBucket{
+		capacity:  float64(perMinute),
+		available: float64(perMinute),
+		last:      now,
+	}
+}
+
+// refill adds the tokens accrued since the last refill
+func (tb *tokenBucket) refill(now time.Time) {
+	elapsed := now.Sub(tb.last)
+	if elapsed <= 0 {
+		return
+	}
+	tb.available = math.Min(tb.capacity, tb.available+elapsed.Minutes()*tb.capacity)
+	tb.last = now
+}
+
+// delay returns how long to wait until n tokens are available
+func (tb *tokenBucket) delay(n float64) time.Duration {
+	if tb == nil || tb.available >= n {
+		return 0
+	}
+	missing := n - tb.available
+	return time.Duration(missing / tb.capacity * float64(time.Minute))
+}
+
+// reserve consumes one request and the given tokens if both fit, otherwise it
+// returns the time to wait before trying again.
+func (b *rateBucket) reserve(now time.Time, tokens int) time.Duration {
+	need := float64(tokens)
+	if b.tokens != nil {
+		b.tokens.refill(now)
+		// Requests larger than the whole budget wait for a full bucket
+		need = math.Min(need, b.tokens.capacity)
+	}
+	if b.requests != nil {
+		b.requests.refill(now)
+	}
+
+	wait := b.requests.delay(1)
+	if d := b.tokens.delay(need); d > wait {
+		wait = d
+	}
+	if wait > 0 {
+		// Always make progress on the next attempt
+		if wait < time.Millisecond {
+			wait = time.Millisecond
+		}
+		return wait
+	}
+
+	if b.requests != nil {
+		b.requests.available--
+	}
+	if b.tokens != nil {
+		b.tokens.available -= need
+	}
+	return 0
+}
+
+// leave removes a waiter from the queue and hands the turn to the next one
+func (b *rateBucket) leave(turn chan struct{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for i, t := range b.queue {
+		if t != turn {
+			continue
+		}
+		b.queue = append(b.queue[:i], b.queue[i+1:]...)
+		if i == 0 && len(b.queue) > 0 {
+			close(b.queue[0])
+		}
+		return
+	}
+}
+
+// Wait blocks until a request with the given estimated token count fits in the
+// budget for provider and model, or until ctx is done.
+func (rl *RateLimiter) Wait(ctx context.Context, provider llm.LLMProvider, model string, tokens int) error {
+	b := rl.bucket(provider, model)
+	if b == nil {
+		return nil
+	}
+
+	// Join the queue and wait for our turn
+	turn := make(chan struct{})
+	b.mu.Lock()
+	b.queue = append(b.queue, turn)
+	if len(b.queue) == 1 {
+		close(turn)
+	}
+	b.mu.Unlock()
+	defer b.leave(turn)
+
+	select {
+	case <-turn:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	for {
+		b.mu.Lock()
+		wait := b.reserve(rl.now(), tokens)
+		b.mu.Unlock()
+		if wait == 0 {
+			return nil
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// TryAcquire consumes budget for a request without blocking. It returns
+// ErrRateLimitExceeded when the request does not fit or other callers are queued.
+func (rl *RateLimiter) TryAcquire(provider llm.LLMProvider, model string, tokens int) error {
+	b := rl.bucket(provider, model)
+	if b == nil {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.queue) > 0 {
+		return fmt.Errorf("%w: %d requests queued for %s", ErrRateLimitExceeded, len(b.queue), limitKey(provider, model))
+	}
+	if wait := b.reserve(rl.now(), tokens); wait > 0 {
+		return fmt.Errorf("%w: retry in %v for %s", ErrRateLimitExceeded, wait.Round(time.Millisecond), limitKey(provider, model))
+	}
+	return nil
+}
+
+// Record corrects the token budget once the actual usage of a request is known
+func (rl *RateLimiter) Record(provider llm.LLMProvider, model string, estimated, actual int) {
+	if actual <= 0 || actual == estimated {
+		return
+	}
+	b := rl.bucket(provider, model)
+	if b == nil || b.tokens == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.tokens.refill(rl.now())
+	b.tokens.available = math.Min(b.tokens.capacity, b.tokens.available-float64(actual-estimated))
+}
+
+// estimateRequestTokens estimates the tokens of a request for rate limiting,
+// including the completion budget
+func (s *Swarm) estimateRequestTokens(req llm.ChatCompletionRequest) int {
+	return s.EstimateTokens(req) + req.MaxTokens
+}
@@ -0,0 +1,108 @@
+package swarmgo
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mohan2020coder/swarmgo/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// TestRateLimiterRequestsPerMinute tests that requests beyond the RPM budget block
+func TestRateLimiterRequestsPerMinute(t *testing.T) {
+	rl := NewRateLimiter(RateLimit{RequestsPerMinute: 2})
+	ctx := context.Background()
+
+	assert.NoError(t, rl.Wait(ctx, llm.OpenAI, "gpt-4o", 0))
+	assert.NoError(t, rl.Wait(ctx, llm.OpenAI, "gpt-4o", 0))
+
+	waitCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	err := rl.Wait(waitCtx, llm.OpenAI, "gpt-4o", 0)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+
+	// Other models have their own bucket
+	assert.NoError(t, rl.Wait(ctx, llm.OpenAI, "gpt-4o-mini", 0))
+}
+
+// TestRateLimiterTokensPerMinute tests that the token budget is enforced and corrected by Record
+func TestRateLimiterTokensPerMinute(t *testing.T) {
+	rl := NewRateLimiter(RateLimit{})
+	rl.SetLimit(llm.Claude, "", RateLimit{TokensPerMinute: 1000})
+
+	assert.NoError(t, rl.TryAcquire(llm.Claude, "claude-3-opus", 600))
+	assert.ErrorIs(t, rl.TryAcquire(llm.Claude, "claude-3-opus", 600), ErrRateLimitExceeded)
+
+	// The first request actually used fewer tokens than estimated
+	rl.Record(llm.Claude, "claude-3-opus", 600, 100)
+	assert.NoError(t, rl.TryAcquire(llm.Claude, "claude-3-opus", 600))
+
+	// Providers without a limit are never throttled
+	assert.NoError(t, rl.TryAcquire(llm.OpenAI, "gpt-4o", 1_000_000))
+}
+
+// TestRateLimiterSetLimitKeepsOtherProviders tests that a provider-wide limit
+// only resets the buckets of that provider
+func TestRateLimiterSetLimitKeepsOtherProviders(t *testing.T) {
+	rl := NewRateLimiter(RateLimit{RequestsPerMinute: 1})
+
+	assert.NoError(t, rl.TryAcquire(llm.OpenAI, "gpt-4o", 0))
+	assert.NoError(t, rl.TryAcquire(llm.Claude, "claude-3-opus", 0))
+	rl.SetLimit(llm.Claude, "", RateLimit{RequestsPerMinute: 1})
+
+	// OpenAI's bucket keeps its usage, Claude's starts over
+	assert.ErrorIs(t, rl.TryAcquire(llm.OpenAI, "gpt-4o", 0), ErrRateLimitExceeded)
+	assert.NoError(t, rl.TryAcquire(llm.Claude, "claude-3-opus", 0))
+	assert.ErrorIs(t, rl.TryAcquire(llm.Claude, "claude-3-opus", 0), ErrRateLimitExceeded)
+}
+
+// TestRateLimiterCancelledWaiterLeavesQueue tests that a cancelled waiter does not block later callers
+func TestRateLimiterCancelledWaiterLeavesQueue(t *testing.T) {
+	rl := NewRateLimiter(RateLimit{RequestsPerMinute: 1})
+	ctx := context.Background()
+	assert.NoError(t, rl.Wait(ctx, llm.OpenAI, "gpt-4o", 0))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			waitCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+			defer cancel()
+			assert.Error(t, rl.Wait(waitCtx, llm.OpenAI, "gpt-4o", 0))
+		}()
+	}
+	wg.Wait()
+
+	b := rl.bucket(llm.OpenAI, "gpt-4o")
+	b.mu.Lock()
+	assert.Empty(t, b.queue)
+	b.mu.Unlock()
+}
+
+// TestRunWithRateLimitFail tests that the swarm fails fast when configured with RateLimitFail
+func TestRunWithRateLimitFail(t *testing.T) {
+	mockClient := new(MockLLM)
+	sw := NewSwarmWithCustomProvider(mockClient, &Config{
+		RateLimitStrategy: RateLimitFail,
+		RateLimiter:       NewRateLimiter(RateLimit{RequestsPerMinute: 1}),
+	})
+
+	mockClient.On("CreateChatCompletion", mock.Anything, mock.Anything).Return(llm.ChatCompletionResponse{
+		Choices: []llm.Choice{{Message: llm.Message{Role: llm.RoleAssistant, Content: "Hi"}}},
+	}, nil).Once()
+
+	agent := &Agent{Name: "TestAgent", Model: "test-model"}
+	messages := []llm.Message{{Role: llm.RoleUser, Content: "Hello"}}
+
+	_, err := sw.Run(context.Background(), agent, messages, nil, "", false, false, 1, true)
+	assert.NoError(t, err)
+
+	_, err = sw.Run(context.Background(), agent, messages, nil, "", false, false, 1, true)
+	assert.ErrorIs(t, err, ErrRateLimitExceeded)
+	mockClient.AssertExpectations(t)
+}
@@ -0,0 +1,103 @@
+package swarmgo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mohan2020coder/swarmgo/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// reasoningHandler records reasoning and content tokens
+type reasoningHandler struct {
+	DefaultStreamHandler
+	reasoning []string
+	message   llm.Message
+}
+
+func (h *reasoningHandler) OnReasoning(token string)       { h.reasoning = append(h.reasoning, token) }
+func (h *reasoningHandler) OnComplete(message llm.Message) { h.message = message }
+
+// TestDeepSeekReasoningIsNotSentBack tests that reasoning_content is returned but never replayed
+func TestDeepSeekReasoningIsNotSentBack(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		_, _ = w.Write([]byte(`{"id":"1","choices":[{"index":0,"message":{"role":"assistant","content":"42","reasoning_content":"6 times 7"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	client := llm.NewDeepSeekLLMWithClient("test-key", server.URL, server.Client())
+	resp, err := client.CreateChatCompletion(context.Background(), llm.ChatCompletionRequest{
+		Model: "deepseek-reasoner",
+		Messages: []llm.Message{
+			{Role: llm.RoleUser, Content: "6*7?"},
+			{Role: llm.RoleAssistant, Content: "42", Reasoning: "earlier reasoning"},
+			{Role: llm.RoleUser, Content: "Sure?"},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "6 times 7", resp.Choices[0].Message.Reasoning)
+
+	assistant := body["messages"].([]interface{})[1].(map[string]interface{})
+	assert.NotContains(t, assistant, "reasoning_content")
+	assert.NotContains(t, assistant, "reasoning")
+}
+
+// TestStreamingResponseReportsReasoning tests that streamed OpenRouter reasoning reaches the handler
+func TestStreamingResponseReportsReasoning(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, chunk := range []string{
+			`{"id":"1","choices":[{"index":0,"delta":{"role":"assistant","reasoning":"Think"}}]}`,
+			`{"id":"1","choices":[{"index":0,"delta":{"reasoning":"ing"}}]}`,
+			`{"id":"1","choices":[{"index":0,"delta":{"content":"Done"},"finish_reason":"stop"}]}`,
+		} {
+			fmt.Fprintf(w, "data: %s\n\n", chunk)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	swarm := NewSwarmFromClientConfig(&ClientConfig{Provider: llm.OpenRouter, AuthToken: "test-key", BaseURL: server.URL}, nil)
+	handler := &reasoningHandler{}
+	_, err := swarm.StreamingResponse(context.Background(), &Agent{Name: "thinker", Model: "deepseek/deepseek-r1"},
+		[]llm.Message{{Role: llm.RoleUser, Content: "Go"}}, nil, "", handler, false, 1)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"Think", "ing"}, handler.reasoning)
+	assert.Equal(t, "Thinking", handler.message.Reasoning)
+	assert.Equal(t, "Done", handler.message.Content)
+}
+
+// TestOpenRouterReasoningIsDecodedOnlyForOpenRouter tests that reasoning is read from
+// OpenRouter's reasoning field without overriding reasoning_content, and that other
+// clients leave the response alone
+func TestOpenRouterReasoningIsDecodedOnlyForOpenRouter(t *testing.T) {
+	reply := `{"id":"1","choices":[
+		{"index":0,"message":{"role":"assistant","content":"42","reasoning":"6 times 7"},"finish_reason":"stop"},
+		{"index":1,"message":{"role":"assistant","content":"42","reasoning":"summary","reasoning_content":"full"},"finish_reason":"stop"}
+	]}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(reply))
+	}))
+	defer server.Close()
+
+	req := llm.ChatCompletionRequest{Model: "deepseek/deepseek-r1", Messages: []llm.Message{{Role: llm.RoleUser, Content: "6*7?"}}}
+	resp, err := llm.NewOpenRouterLLMWithHost("test-key", server.URL).CreateChatCompletion(context.Background(), req)
+	require.NoError(t, err)
+	require.Len(t, resp.Choices, 2)
+	assert.Equal(t, "6 times 7", resp.Choices[0].Message.Reasoning)
+	assert.Equal(t, "full", resp.Choices[1].Message.Reasoning)
+
+	compatible := llm.NewOpenAICompatibleLLM(llm.OpenAICompatibleConfig{BaseURL: server.URL, Profile: llm.DefaultCompatibilityProfile()})
+	resp, err = compatible.CreateChatCompletion(context.Background(), req)
+	require.NoError(t, err)
+	assert.Empty(t, resp.Choices[0].Message.Reasoning)
+}
@@ -0,0 +1,279 @@
+package swarmgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mohan2020coder/swarmgo/llm"
+)
+
+// ErrSemanticSearchDisabled is returned by SemanticSearch on stores without an embedder
+var ErrSemanticSearchDisabled = errors.New("semantic search is not enabled")
+
+// SemanticQuery searches memories by meaning. Memories are ranked by a score
+// combining their similarity to Text, their recency and their importance.
+type SemanticQuery struct {
+	Text          string
+	TopK          int       // Results to return, 5 when zero
+	Type          string    // Empty matches all types
+	Since         time.Time // Earliest timestamp, zero for no bound
+	Until         time.Time // Latest timestamp, zero for no bound
+	MinImportance float64   // Skip memories less important than this
+	Weights       ScoreWeights
+}
+
+// matches reports whether a memory passes the filters of the query
+func (q SemanticQuery) matches(memory Memory) bool {
+	return MemoryQuery{Type: q.Type, Since: q.Since, Until: q.Until}.matches(memory) &&
+		memory.Importance >= q.MinImportance
+}
+
+// ScoreWeights weigh the parts of a memory's ranking score. Zero fields take
+// their value from DefaultScoreWeights, so ScoreWeights{HalfLife: time.Hour}
+// only changes the half-life. Use NewScoreWeights to set weights to zero.
+type ScoreWeights struct {
+	Similarity float64
+	Recency    float64
+	Importance float64
+	HalfLife   time.Duration // Age at which recency falls to 0.5
+	exact      bool          // Set by NewScoreWeights, zero fields are kept
+}
+
+// DefaultScoreWeights favour similarity, with recency halving every week
+var DefaultScoreWeights = ScoreWeights{Similarity: 0.7, Recency: 0.15, Importance: 0.15, HalfLife: 7 * 24 * time.Hour}
+
+// NewScoreWeights returns weights used exactly as given, including zeros. A
+// zero halfLife keeps the recency of every memory at 1.
+func NewScoreWeights(similarity, recency, importance float64, halfLife time.Duration) ScoreWeights {
+	return ScoreWeights{Similarity: similarity, Recency: recency, Importance: importance, HalfLife: halfLife, exact: true}
+}
+
+// withDefaults fills the zero fields of w from DefaultScoreWeights
+func (w ScoreWeights) withDefaults() ScoreWeights {
+	if w.exact {
+		return w
+	}
+	if w.Similarity == 0 {
+		w.Similarity = DefaultScoreWeights.Similarity
+	}
+	if w.Recency == 0 {
+		w.Recency = DefaultScoreWeights.Recency
+	}
+	if w.Importance == 0 {
+		w.Importance = DefaultScoreWeights.Importance
+	}
+	if w.HalfLife == 0 {
+		w.HalfLife = DefaultScoreWeights.HalfLife
+	}
+	return w
+}
+
+// ScoredMemory is a search result with its ranking
+type ScoredMemory struct {
+	Memory
+	Score      float64
+	Similarity float64 // Cosine similarity to the query
+	Recency    float64 // 1 for new memories, decaying with age
+}
+
+// Score ranks a memory with the given similarity to the query at time now
+func (w ScoreWeights) Score(memory Memory, similarity float64, now time.Time) ScoredMemory {
+	w = w.withDefaults()
+	recency := 1.0
+	if age := now.Sub(memory.Timestamp); w.HalfLife > 0 && age > 0 {
+		recency = math.Pow(0.5, float64(age)/float64(w.HalfLife))
+	}
+	return ScoredMemory{
+		Memory:     memory,
+		Score:      w.Similarity*similarity + w.Recency*recency + w.Importance*memory.Importance,
+		Similarity: similarity,
+		Recency:    recency,
+	}
+}
+
+// cosineSimilarity returns the cosine of the angle between two vectors, 0 if
+// their lengths differ or either is zero
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(normA*normB)
+}
+
+// VectorIndex stores memory embeddings for semantic search. Search returns the
+// TopK memories passing the query's filters with the highest Score. Adding a
+// memory with the ID of an indexed one replaces it.
+type VectorIndex interface {
+	Add(ctx context.Context, memory Memory) error
+	Search(ctx context.Context, vector []float32, query SemanticQuery) ([]ScoredMemory, error)
+	Clear(ctx context.Context) error
+}
+
+// InMemoryVectorIndex is a VectorIndex that compares the query with every
+// indexed memory. It needs no external services.
+type InMemoryVectorIndex struct {
+	mu       sync.RWMutex
+	memories map[string]Memory
+}
+
+// NewInMemoryVectorIndex creates an empty index
+func NewInMemoryVectorIndex() *InMemoryVectorIndex {
+	return &InMemoryVectorIndex{memories: make(map[string]Memory)}
+}
+
+// Add indexes a memory, ignoring memories without an embedding
+func (x *InMemoryVectorIndex) Add(ctx context.Context, memory Memory) error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if len(memory.Embedding) == 0 {
+		delete(x.memories, memory.ID)
+		return nil
+	}
+	x.memories[memory.ID] = memory
+	return nil
+}
+
+// Search returns the best scoring memories for the query vector
+func (x *InMemoryVectorIndex) Search(ctx context.Context, vector []float32, query SemanticQuery) ([]ScoredMemory, error) {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	now := time.Now()
+	var results []ScoredMemory
+	for _, memory := range x.memories {
+		if query.matches(memory) {
+			results = append(results, query.Weights.Score(memory, cosineSimilarity(vector, memory.Embedding), now))
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].ID < results[j].ID
+	})
+	if len(results) > query.TopK {
+		results = results[:query.TopK]
+	}
+	return results, nil
+}
+
+// Clear removes all memories from the index
+func (x *InMemoryVectorIndex) Clear(ctx context.Context) error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.memories = make(map[string]Memory)
+	return nil
+}
+
+// semanticSearch holds the embedder and index of a MemoryStore
+type semanticSearch struct {
+	embedder llm.Embedder
+	model    string
+	index    VectorIndex
+}
+
+// embed returns one embedding per text
+func (s *semanticSearch) embed(ctx context.Context, texts []string) ([][]float32, error) {
+	resp, err := llm.EmbedBatches(ctx, s.embedder, llm.EmbeddingRequest{Model: s.model, Input: texts}, 100)
+	if err != nil {
+		return nil, fmt.Errorf("error embedding memories: %w", err)
+	}
+	if len(resp.Embeddings) != len(texts) {
+		return nil, llm.ErrEmbeddingCount
+	}
+	return resp.Embeddings, nil
+}
+
+// embedMissing embeds the memories that have content but no embedding, in
+// place, and returns them
+func (s *semanticSearch) embedMissing(ctx context.Context, memories []Memory) ([]Memory, error) {
+	var missing []int
+	var texts []string
+	for i, memory := range memories {
+		if len(memory.Embedding) == 0 && memory.Content != "" {
+			missing = append(missing, i)
+			texts = append(texts, memory.Content)
+		}
+	}
+	if len(texts) == 0 {
+		return nil, nil
+	}
+	embeddings, err := s.embed(ctx, texts)
+	if err != nil {
+		return nil, err
+	}
+	embedded := make([]Memory, len(missing))
+	for i, pos := range missing {
+		memories[pos].Embedding = embeddings[i]
+		embedded[i] = memories[pos]
+	}
+	return embedded, nil
+}
+
+// EnableSemanticSearch embeds memories with model when they are added and
+// indexes them in index, or an InMemoryVectorIndex if index is nil. Long-term
+// memories already stored are indexed, embedding those without an embedding
+// and saving them back to the backend.
+func (ms *MemoryStore) EnableSemanticSearch(ctx context.Context, embedder llm.Embedder, model string, index VectorIndex) error {
+	if index == nil {
+		index = NewInMemoryVectorIndex()
+	}
+	search := &semanticSearch{embedder: embedder, model: model, index: index}
+
+	memories, err := ms.backend.Search(ctx, MemoryQuery{})
+	if err != nil {
+		return err
+	}
+	embedded, err := search.embedMissing(ctx, memories)
+	if err != nil {
+		return err
+	}
+	for _, memory := range embedded {
+		if err := ms.backend.Add(ctx, memory); err != nil {
+			return err
+		}
+	}
+	for _, memory := range memories {
+		if err := index.Add(ctx, memory); err != nil {
+			return err
+		}
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.semantic = search
+	return nil
+}
+
+// SemanticSearch returns the long-term memories that best match the query text
+func (ms *MemoryStore) SemanticSearch(ctx context.Context, query SemanticQuery) ([]ScoredMemory, error) {
+	ms.mu.RLock()
+	search := ms.semantic
+	ms.mu.RUnlock()
+	if search == nil {
+		return nil, ErrSemanticSearchDisabled
+	}
+
+	if query.TopK <= 0 {
+		query.TopK = 5
+	}
+	embeddings, err := search.embed(ctx, []string{query.Text})
+	if err != nil {
+		return nil, err
+	}
+	return search.index.Search(ctx, embeddings[0], query)
+}
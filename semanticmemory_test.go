@@ -0,0 +1,154 @@
+package swarmgo
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mohan2020coder/swarmgo/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func scoredContents(results []ScoredMemory) []string {
+	texts := make([]string, len(results))
+	for i, result := range results {
+		texts[i] = result.Content
+	}
+	return texts
+}
+
+// TestSemanticSearch tests that memories are embedded on insert and ranked by
+// similarity, with filters on type, time range and importance
+func TestSemanticSearch(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore(10)
+	_, err := store.SemanticSearch(ctx, SemanticQuery{Text: "tea"})
+	assert.ErrorIs(t, err, ErrSemanticSearchDisabled)
+	require.NoError(t, store.EnableSemanticSearch(ctx, llm.NewFakeEmbedder(256), "fake", nil))
+
+	now := time.Now()
+	for _, memory := range []Memory{
+		{Content: "the user drinks green tea every morning", Type: "preference", Timestamp: now, Importance: 0.5},
+		{Content: "the user lives in a small flat in Paris", Type: "fact", Timestamp: now, Importance: 0.9},
+		{Content: "the user dislikes black tea", Type: "preference", Timestamp: now.Add(-30 * 24 * time.Hour), Importance: 0.2},
+		{Content: "the user asked about the weather", Type: "conversation", Timestamp: now, Importance: 0.1},
+	} {
+		require.NoError(t, store.AddMemoryContext(ctx, memory))
+	}
+	assert.NotEmpty(t, store.SearchMemories("fact", nil)[0].Embedding)
+
+	results, err := store.SemanticSearch(ctx, SemanticQuery{Text: "tea", TopK: 2})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"the user drinks green tea every morning", "the user dislikes black tea"}, scoredContents(results))
+	assert.Greater(t, results[0].Score, results[1].Score)
+	assert.Greater(t, results[0].Recency, results[1].Recency)
+
+	results, err = store.SemanticSearch(ctx, SemanticQuery{Text: "tea", Type: "preference", Since: now.Add(-time.Hour)})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"the user drinks green tea every morning"}, scoredContents(results))
+
+	results, err = store.SemanticSearch(ctx, SemanticQuery{Text: "tea", MinImportance: 0.8})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"the user lives in a small flat in Paris"}, scoredContents(results))
+
+	// With only importance weighted, the most important memory ranks first
+	results, err = store.SemanticSearch(ctx, SemanticQuery{Text: "tea", TopK: 1, Weights: NewScoreWeights(0, 0, 1, 0)})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"the user lives in a small flat in Paris"}, scoredContents(results))
+}
+
+// TestScoreWeightsDefaults tests that zero weights default one by one, unless
+// the weights were created with NewScoreWeights
+func TestScoreWeightsDefaults(t *testing.T) {
+	now := time.Now()
+	memory := Memory{Timestamp: now.Add(-time.Hour), Importance: 0.5}
+
+	scored := ScoreWeights{HalfLife: time.Hour}.Score(memory, 0.8, now)
+	assert.InDelta(t, 0.5, scored.Recency, 1e-9)
+	assert.InDelta(t, 0.7*0.8+0.15*0.5+0.15*0.5, scored.Score, 1e-9)
+
+	scored = ScoreWeights{Similarity: 1}.Score(memory, 0.8, now)
+	assert.InDelta(t, 0.8+0.15*scored.Recency+0.15*0.5, scored.Score, 1e-9)
+
+	scored = NewScoreWeights(1, 0, 0, 0).Score(memory, 0.8, now)
+	assert.Equal(t, 1.0, scored.Recency)
+	assert.InDelta(t, 0.8, scored.Score, 1e-9)
+}
+
+// TestEnableSemanticSearchEmbedsStoredMemories tests that memories stored before
+// semantic search was enabled are embedded, saved back and indexed
+func TestEnableSemanticSearchEmbedsStoredMemories(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "memories.jsonl")
+	backend, err := NewFileMemoryBackend(path)
+	require.NoError(t, err)
+	store, err := NewMemoryStoreWithBackend(ctx, 10, backend)
+	require.NoError(t, err)
+	require.NoError(t, store.AddMemoryContext(ctx, Memory{Content: "the meeting moved to Friday", Type: "fact", Timestamp: time.Now()}))
+	require.NoError(t, store.AddMemoryContext(ctx, Memory{Content: "the user likes jazz", Type: "fact", Timestamp: time.Now()}))
+	require.NoError(t, store.Close())
+
+	var embedded int
+	fake := llm.NewFakeEmbedder(256)
+	counting := llm.EmbedderFunc(func(ctx context.Context, req llm.EmbeddingRequest) (llm.EmbeddingResponse, error) {
+		embedded += len(req.Input)
+		return fake.CreateEmbeddings(ctx, req)
+	})
+
+	for range 2 {
+		backend, err = NewFileMemoryBackend(path)
+		require.NoError(t, err)
+		store, err = NewMemoryStoreWithBackend(ctx, 10, backend)
+		require.NoError(t, err)
+		require.NoError(t, store.EnableSemanticSearch(ctx, counting, "fake", NewInMemoryVectorIndex()))
+
+		results, err := store.SemanticSearch(ctx, SemanticQuery{Text: "when is the meeting", TopK: 1})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"the meeting moved to Friday"}, scoredContents(results))
+		require.NoError(t, store.Close())
+	}
+	// Two memories on the first open and one query per open
+	assert.Equal(t, 4, embedded)
+}
+
+// TestToolCallMemoriesEmbeddedTogether tests that the tool calls of a turn are
+// embedded in one request made with the run's context
+func TestToolCallMemoriesEmbeddedTogether(t *testing.T) {
+	type ctxKey struct{}
+	ctx := context.WithValue(context.Background(), ctxKey{}, "run")
+
+	var requests [][]string
+	fake := llm.NewFakeEmbedder(64)
+	embedder := llm.EmbedderFunc(func(ctx context.Context, req llm.EmbeddingRequest) (llm.EmbeddingResponse, error) {
+		assert.Equal(t, "run", ctx.Value(ctxKey{}))
+		requests = append(requests, req.Input)
+		return fake.CreateEmbeddings(ctx, req)
+	})
+
+	agent := NewAgent("Agent", "model", llm.OpenAI)
+	require.NoError(t, agent.Memory.EnableSemanticSearch(ctx, embedder, "fake", nil))
+	agent.Functions = []AgentFunction{{
+		Name:     "lookup",
+		Function: func(args, contextVariables map[string]interface{}) Result { return Result{Data: "found"} },
+	}}
+
+	call := func(id string) llm.ToolCall {
+		return llm.ToolCall{ID: id, Type: "function", Function: llm.ToolCallFunction{Name: "lookup", Arguments: "{}"}}
+	}
+	client := new(MockLLM)
+	client.On("CreateChatCompletion", mock.Anything, mock.Anything).Return(llm.ChatCompletionResponse{Choices: []llm.Choice{{
+		Message: llm.Message{Role: llm.RoleAssistant, ToolCalls: []llm.ToolCall{call("call_1"), call("call_2")}},
+	}}}, nil).Once()
+	client.On("CreateChatCompletion", mock.Anything, mock.Anything).Return(llm.ChatCompletionResponse{Choices: []llm.Choice{{
+		Message: llm.Message{Role: llm.RoleAssistant, Content: "done"},
+	}}}, nil).Once()
+
+	_, err := NewSwarmWithCustomProvider(client, nil).Run(ctx, agent, []llm.Message{{Role: llm.RoleUser, Content: "look up"}}, nil, "", false, false, 2, true)
+	require.NoError(t, err)
+	require.Len(t, requests, 1)
+	assert.Len(t, requests[0], 2)
+	assert.Len(t, agent.Memory.SearchMemories("tool_call", nil), 2)
+}
@@ -0,0 +1,187 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/mohan2020coder/swarmgo/llm"
+)
+
+// chatRequest is the body of a chat completions request. Sampling parameters are
+// accepted but ignored, the registered agents decide how they are called.
+type chatRequest struct {
+	Model         string         `json:"model"`
+	Messages      []chatMessage  `json:"messages"`
+	Stream        bool           `json:"stream,omitempty"`
+	StreamOptions *streamOptions `json:"stream_options,omitempty"`
+	Tools         []llm.Tool     `json:"tools,omitempty"`
+	User          string         `json:"user,omitempty"`
+}
+
+type streamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type chatMessage struct {
+	Role             string          `json:"role,omitempty"`
+	Content          json.RawMessage `json:"content,omitempty"` // A string or a list of content parts
+	Name             string          `json:"name,omitempty"`
+	ToolCalls        []toolCall      `json:"tool_calls,omitempty"`
+	ToolCallID       string          `json:"tool_call_id,omitempty"`
+	ReasoningContent string          `json:"reasoning_content,omitempty"`
+}
+
+type contentPart struct {
+	Type     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	ImageURL *struct {
+		URL string `json:"url"`
+	} `json:"image_url,omitempty"`
+}
+
+type toolCall struct {
+	Index    *int                 `json:"index,omitempty"`
+	ID       string               `json:"id,omitempty"`
+	Type     string               `json:"type,omitempty"`
+	Function llm.ToolCallFunction `json:"function"`
+}
+
+type chatResponse struct {
+	ID      string       `json:"id"`
+	Object  string       `json:"object"`
+	Created int64        `json:"created"`
+	Model   string       `json:"model"`
+	Choices []chatChoice `json:"choices"`
+	Usage   *usage       `json:"usage,omitempty"`
+}
+
+type chatChoice struct {
+	Index        int          `json:"index"`
+	Message      *chatMessage `json:"message,omitempty"`
+	Delta        *chatMessage `json:"delta,omitempty"`
+	FinishReason *string      `json:"finish_reason"`
+}
+
+type usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+type modelList struct {
+	Object string      `json:"object"`
+	Data   []modelCard `json:"data"`
+}
+
+type modelCard struct {
+	ID            string `json:"id"`
+	Object        string `json:"object"`
+	Created       int64  `json:"created"`
+	OwnedBy       string `json:"owned_by"`
+	ContextWindow int    `json:"context_window,omitempty"`
+}
+
+type errorResponse struct {
+	Error apiError `json:"error"`
+}
+
+type apiError struct {
+	Message string `json:"message"`
+	Type    string `json:"type"`
+	Code    string `json:"code,omitempty"`
+}
+
+// textContent encodes text as message content
+func textContent(text string) json.RawMessage {
+	content, _ := json.Marshal(text)
+	return content
+}
+
+// toMessages converts the messages of a request to our generic type. Tool
+// results get the name of the call they answer.
+func toMessages(messages []chatMessage) ([]llm.Message, error) {
+	result := make([]llm.Message, 0, len(messages))
+	toolNames := make(map[string]string)
+	for i, msg := range messages {
+		out := llm.Message{
+			Role:       llm.Role(msg.Role),
+			Name:       msg.Name,
+			ToolCallID: msg.ToolCallID,
+			Reasoning:  msg.ReasoningContent,
+		}
+		switch out.Role {
+		case llm.RoleSystem, llm.RoleUser, llm.RoleAssistant, llm.RoleTool, llm.RoleFunction:
+		case "developer":
+			out.Role = llm.RoleSystem
+		default:
+			return nil, fmt.Errorf("messages[%d]: unsupported role %q", i, msg.Role)
+		}
+
+		if err := parseContent(msg.Content, &out); err != nil {
+			return nil, fmt.Errorf("messages[%d]: %w", i, err)
+		}
+		for j, call := range msg.ToolCalls {
+			if call.Type == "" {
+				call.Type = "function"
+			}
+			out.ToolCalls = append(out.ToolCalls, llm.ToolCall{ID: call.ID, Type: call.Type, Function: call.Function, Index: j})
+			toolNames[call.ID] = call.Function.Name
+		}
+		if out.Role == llm.RoleTool && out.Name == "" {
+			out.Name = toolNames[out.ToolCallID]
+		}
+		result = append(result, out)
+	}
+	return result, nil
+}
+
+// parseContent sets the content of msg from a string or a list of content parts.
+// Text parts are joined into Content, images are kept as parts.
+func parseContent(raw json.RawMessage, msg *llm.Message) error {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil
+	}
+	if raw[0] == '"' {
+		return json.Unmarshal(raw, &msg.Content)
+	}
+
+	var parts []contentPart
+	if err := json.Unmarshal(raw, &parts); err != nil {
+		return fmt.Errorf("content must be a string or a list of parts: %w", err)
+	}
+	var texts []string
+	for _, part := range parts {
+		switch part.Type {
+		case "text":
+			texts = append(texts, part.Text)
+		case "image_url":
+			if part.ImageURL == nil || part.ImageURL.URL == "" {
+				return fmt.Errorf("image_url part without url")
+			}
+			msg.Parts = append(msg.Parts, llm.ImageURLPart(part.ImageURL.URL))
+		default:
+			return fmt.Errorf("unsupported content part %q", part.Type)
+		}
+	}
+	msg.Content = strings.Join(texts, "\n")
+	return nil
+}
+
+// fromMessage converts an assistant message to the wire format
+func fromMessage(msg llm.Message) *chatMessage {
+	out := &chatMessage{
+		Role:             string(llm.RoleAssistant),
+		Content:          textContent(msg.Text()),
+		ReasoningContent: msg.Reasoning,
+	}
+	for _, call := range msg.ToolCalls {
+		out.ToolCalls = append(out.ToolCalls, toolCall{ID: call.ID, Type: "function", Function: call.Function})
+	}
+	return out
+}
+
+// fromUsage converts token usage to the wire format
+func fromUsage(u llm.Usage) *usage {
+	return &usage{PromptTokens: u.PromptTokens, CompletionTokens: u.CompletionTokens, TotalTokens: u.TotalTokens}
+}
@@ -0,0 +1,414 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mohan2020coder/swarmgo"
+	"github.com/mohan2020coder/swarmgo/llm"
+)
+
+// Config holds the configuration of a Server
+type Config struct {
+	APIKey           string // Bearer token clients must send, no authentication when empty
+	MaxTurns         int    // Model responses per request, 0 or less means no limit
+	PassThroughTools bool   // Return tool calls to the client instead of running them
+	Debug            bool
+}
+
+// DefaultConfig returns the default server configuration
+func DefaultConfig() *Config {
+	return &Config{MaxTurns: 10}
+}
+
+// model is an agent or graph served under a model name
+type model struct {
+	agent   *swarmgo.Agent
+	graph   *swarmgo.Graph
+	created int64
+}
+
+// Server serves registered agents and graphs over the OpenAI chat completions
+// API, so existing OpenAI clients and chat UIs can talk to them. Requests select
+// an agent or graph by model name and run through the server's Swarm.
+type Server struct {
+	swarm  *swarmgo.Swarm
+	config *Config
+	mux    *http.ServeMux
+	mu     sync.RWMutex
+	models map[string]model
+}
+
+// New creates a server running requests through swarm. A nil config uses DefaultConfig.
+func New(swarm *swarmgo.Swarm, config *Config) *Server {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	s := &Server{
+		swarm:  swarm,
+		config: config,
+		mux:    http.NewServeMux(),
+		models: make(map[string]model),
+	}
+	s.mux.HandleFunc("POST /v1/chat/completions", s.handleChatCompletions)
+	s.mux.HandleFunc("GET /v1/models", s.handleModels)
+	return s
+}
+
+// RegisterAgent serves agent under the model name name
+func (s *Server) RegisterAgent(name string, agent *swarmgo.Agent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.models[name] = model{agent: agent, created: time.Now().Unix()}
+}
+
+// RegisterGraph serves graph under the model name name. The graph receives the
+// conversation in its messages state and answers with the last assistant message
+// it adds. Graphs without a swarm use the server's.
+func (s *Server) RegisterGraph(name string, graph *swarmgo.Graph) {
+	if graph.Swarm() == nil {
+		graph.SetSwarm(s.swarm)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.models[name] = model{graph: graph, created: time.Now().Unix()}
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		writeError(w, http.StatusUnauthorized, "invalid_request_error", "invalid_api_key", "invalid API key")
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// ListenAndServe serves the API on addr
+func (s *Server) ListenAndServe(addr string) error {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           s,
+		ReadHeaderTimeout: 15 * time.Second,
+	}
+	return srv.ListenAndServe()
+}
+
+// authorized checks the bearer token of a request against the configured API key
+func (s *Server) authorized(r *http.Request) bool {
+	if s.config.APIKey == "" {
+		return true
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(token), []byte(s.config.APIKey)) == 1
+}
+
+func (s *Server) handleModels(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	list := modelList{Object: "list", Data: make([]modelCard, 0, len(s.models))}
+	for name, m := range s.models {
+		card := modelCard{ID: name, Object: "model", Created: m.created, OwnedBy: "swarmgo"}
+		if m.agent != nil {
+			card.ContextWindow = s.swarm.ContextWindow(m.agent.Model)
+		}
+		list.Data = append(list.Data, card)
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(list.Data, func(i, j int) bool { return list.Data[i].ID < list.Data[j].ID })
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (s *Server) handleChatCompletions(w http.ResponseWriter, r *http.Request) {
+	var req chatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request_error", "", fmt.Sprintf("invalid request body: %v", err))
+		return
+	}
+	if len(req.Messages) == 0 {
+		writeError(w, http.StatusBadRequest, "invalid_request_error", "", "messages must not be empty")
+		return
+	}
+	messages, err := toMessages(req.Messages)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request_error", "", err.Error())
+		return
+	}
+
+	s.mu.RLock()
+	m, ok := s.models[req.Model]
+	s.mu.RUnlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, "invalid_request_error", "model_not_found", fmt.Sprintf("model %q does not exist", req.Model))
+		return
+	}
+
+	if s.config.Debug {
+		log.Printf("Chat completion for %s with %d messages, stream %v", req.Model, len(messages), req.Stream)
+	}
+	completion := &completion{
+		id:      "chatcmpl-" + uuid.New().String(),
+		created: time.Now().Unix(),
+		model:   req.Model,
+	}
+
+	if m.graph != nil {
+		message, err := s.runGraph(r.Context(), m.graph, messages)
+		if err != nil {
+			s.runFailed(w, err)
+			return
+		}
+		if req.Stream {
+			stream := completion.stream(w, req.StreamOptions != nil && req.StreamOptions.IncludeUsage)
+			stream.delta(&chatMessage{Content: textContent(message.Text())})
+			stream.finish("stop", llm.Usage{})
+			return
+		}
+		writeJSON(w, http.StatusOK, completion.response(message, "stop", llm.Usage{}))
+		return
+	}
+
+	agent := s.agentFor(m.agent, req.Tools)
+	executeTools := !s.config.PassThroughTools
+	if req.Stream {
+		s.streamAgent(r.Context(), w, completion, agent, messages, req.StreamOptions != nil && req.StreamOptions.IncludeUsage)
+		return
+	}
+
+	resp, err := s.swarm.Run(r.Context(), agent, messages, nil, "", false, s.config.Debug, s.config.MaxTurns, executeTools)
+	if err != nil {
+		s.runFailed(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, completion.response(lastAssistant(resp.Messages), finishReason(resp), resp.Usage))
+}
+
+// streamAgent runs agent with streamed responses and forwards its events as
+// chat completion chunks. Tool call deltas are only forwarded when the client
+// runs the tools.
+func (s *Server) streamAgent(ctx context.Context, w http.ResponseWriter, completion *completion, agent *swarmgo.Agent, messages []llm.Message, includeUsage bool) {
+	executeTools := !s.config.PassThroughTools
+	stream := completion.stream(w, includeUsage)
+	for event := range s.swarm.RunStream(ctx, agent, messages, nil, "", s.config.Debug, s.config.MaxTurns, executeTools) {
+		switch event.Type {
+		case swarmgo.EventTextDelta:
+			stream.delta(&chatMessage{Content: textContent(event.Text)})
+		case swarmgo.EventReasoningDelta:
+			stream.delta(&chatMessage{ReasoningContent: event.Text})
+		case swarmgo.EventToolCallDelta:
+			if executeTools {
+				continue
+			}
+			index := event.ToolCall.Index
+			stream.delta(&chatMessage{ToolCalls: []toolCall{{
+				Index:    &index,
+				ID:       event.ToolCall.ID,
+				Type:     event.ToolCall.Type,
+				Function: event.ToolCall.Function,
+			}}})
+		case swarmgo.EventError:
+			if ctx.Err() == nil {
+				log.Printf("Streaming %s failed: %v", completion.model, event.Err)
+				stream.error(event.Err)
+			}
+			return
+		case swarmgo.EventDone:
+			stream.finish(finishReason(*event.Response), event.Response.Usage)
+		}
+	}
+}
+
+// agentFor returns the agent to run for a request. When tools are passed through,
+// the tools sent by the client are added to a copy of the agent.
+func (s *Server) agentFor(agent *swarmgo.Agent, tools []llm.Tool) *swarmgo.Agent {
+	if !s.config.PassThroughTools || len(tools) == 0 {
+		return agent
+	}
+
+	withTools := *agent
+	withTools.Functions = append([]swarmgo.AgentFunction(nil), agent.Functions...)
+	known := make(map[string]bool, len(agent.Functions))
+	for _, f := range agent.Functions {
+		known[f.Name] = true
+	}
+	for _, tool := range tools {
+		if tool.Function == nil || known[tool.Function.Name] {
+			continue
+		}
+		withTools.Functions = append(withTools.Functions, swarmgo.AgentFunction{
+			Name:        tool.Function.Name,
+			Description: tool.Function.Description,
+			Parameters:  tool.Function.Parameters,
+		})
+	}
+	return &withTools
+}
+
+// runGraph executes graph on the conversation and returns the last assistant
+// message it added
+func (s *Server) runGraph(ctx context.Context, graph *swarmgo.Graph, messages []llm.Message) (llm.Message, error) {
+	state, err := graph.ExecuteGraph(ctx, swarmgo.GraphState{swarmgo.MessageKey: messages})
+	if err != nil {
+		return llm.Message{}, err
+	}
+
+	history, ok := state[swarmgo.MessageKey].([]llm.Message)
+	if !ok {
+		// Nodes may store messages in another shape
+		data, err := json.Marshal(state[swarmgo.MessageKey])
+		if err != nil {
+			return llm.Message{}, fmt.Errorf("error marshaling messages: %w", err)
+		}
+		if err := json.Unmarshal(data, &history); err != nil {
+			return llm.Message{}, fmt.Errorf("error unmarshaling messages: %w", err)
+		}
+	}
+	if len(history) > len(messages) {
+		if message := lastAssistant(history[len(messages):]); message.Role == llm.RoleAssistant {
+			return message, nil
+		}
+	}
+	return llm.Message{}, fmt.Errorf("graph %s returned no assistant message", graph.Name)
+}
+
+// runFailed reports a failed run, unless the client is gone
+func (s *Server) runFailed(w http.ResponseWriter, err error) {
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	log.Printf("Chat completion failed: %v", err)
+	writeError(w, http.StatusInternalServerError, "server_error", "", err.Error())
+}
+
+// lastAssistant returns the last assistant message of messages
+func lastAssistant(messages []llm.Message) llm.Message {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == llm.RoleAssistant {
+			return messages[i]
+		}
+	}
+	return llm.Message{}
+}
+
+// finishReason returns why a run ended: it answered, left tool calls to the
+// client or ran out of turns
+func finishReason(resp swarmgo.Response) string {
+	if len(resp.Messages) == 0 {
+		return "stop"
+	}
+	last := resp.Messages[len(resp.Messages)-1]
+	switch {
+	case last.Role != llm.RoleAssistant:
+		return "length"
+	case len(last.ToolCalls) > 0:
+		return "tool_calls"
+	default:
+		return "stop"
+	}
+}
+
+// completion identifies the response to one chat completions request
+type completion struct {
+	id      string
+	created int64
+	model   string
+}
+
+// response creates a non-streaming response
+func (c *completion) response(message llm.Message, finishReason string, u llm.Usage) chatResponse {
+	return chatResponse{
+		ID:      c.id,
+		Object:  "chat.completion",
+		Created: c.created,
+		Model:   c.model,
+		Choices: []chatChoice{{Message: fromMessage(message), FinishReason: &finishReason}},
+		Usage:   fromUsage(u),
+	}
+}
+
+// stream starts a server-sent events response and sends the assistant role
+func (c *completion) stream(w http.ResponseWriter, includeUsage bool) *eventStream {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	stream := &eventStream{w: w, completion: c, includeUsage: includeUsage}
+	stream.delta(&chatMessage{Role: string(llm.RoleAssistant), Content: textContent("")})
+	return stream
+}
+
+// eventStream writes chat completion chunks as server-sent events
+type eventStream struct {
+	w            http.ResponseWriter
+	completion   *completion
+	includeUsage bool
+}
+
+// send writes one event and flushes it to the client
+func (s *eventStream) send(payload interface{}) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("Error marshaling stream chunk: %v", err)
+		return
+	}
+	fmt.Fprintf(s.w, "data: %s\n\n", data)
+	if flusher, ok := s.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (s *eventStream) chunk(choices []chatChoice) chatResponse {
+	return chatResponse{
+		ID:      s.completion.id,
+		Object:  "chat.completion.chunk",
+		Created: s.completion.created,
+		Model:   s.completion.model,
+		Choices: choices,
+	}
+}
+
+// delta sends a message delta
+func (s *eventStream) delta(delta *chatMessage) {
+	s.send(s.chunk([]chatChoice{{Delta: delta}}))
+}
+
+// finish sends the finish reason, the usage if requested and ends the stream
+func (s *eventStream) finish(finishReason string, u llm.Usage) {
+	s.send(s.chunk([]chatChoice{{Delta: &chatMessage{}, FinishReason: &finishReason}}))
+	if s.includeUsage {
+		chunk := s.chunk([]chatChoice{})
+		chunk.Usage = fromUsage(u)
+		s.send(chunk)
+	}
+	fmt.Fprint(s.w, "data: [DONE]\n\n")
+	if flusher, ok := s.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// error sends an error event, the status code was already sent
+func (s *eventStream) error(err error) {
+	s.send(errorResponse{Error: apiError{Message: err.Error(), Type: "server_error"}})
+}
+
+func writeJSON(w http.ResponseWriter, status int, payload interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(payload); err != nil {
+		log.Printf("Error writing response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, errType, code, message string) {
+	writeJSON(w, status, errorResponse{Error: apiError{Message: message, Type: errType, Code: code}})
+}
@@ -0,0 +1,301 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mohan2020coder/swarmgo"
+	"github.com/mohan2020coder/swarmgo/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scriptedLLM answers each request with the next scripted message
+type scriptedLLM struct {
+	mu       sync.Mutex
+	messages []llm.Message
+	requests []llm.ChatCompletionRequest
+}
+
+func (l *scriptedLLM) next(req llm.ChatCompletionRequest) (llm.ChatCompletionResponse, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.requests = append(l.requests, req)
+	if len(l.messages) == 0 {
+		return llm.ChatCompletionResponse{}, errors.New("no more scripted responses")
+	}
+	message := l.messages[0]
+	l.messages = l.messages[1:]
+	return llm.ChatCompletionResponse{
+		ID:      "resp",
+		Choices: []llm.Choice{{Message: message, FinishReason: "stop"}},
+		Usage:   llm.Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15},
+	}, nil
+}
+
+func (l *scriptedLLM) CreateChatCompletion(ctx context.Context, req llm.ChatCompletionRequest) (llm.ChatCompletionResponse, error) {
+	return l.next(req)
+}
+
+func (l *scriptedLLM) CreateChatCompletionStream(ctx context.Context, req llm.ChatCompletionRequest) (llm.ChatCompletionStream, error) {
+	resp, err := l.next(req)
+	if err != nil {
+		return nil, err
+	}
+	return llm.NewSingleResponseStream(resp), nil
+}
+
+func weatherCall() llm.Message {
+	return llm.Message{Role: llm.RoleAssistant, ToolCalls: []llm.ToolCall{{
+		ID: "call_1", Type: "function", Function: llm.ToolCallFunction{Name: "get_weather", Arguments: `{"city":"Paris"}`},
+	}}}
+}
+
+func newTestServer(client *scriptedLLM, config *Config) (*Server, *int) {
+	calls := 0
+	agent := &swarmgo.Agent{Name: "Weather", Model: "gpt-4o", Instructions: "You report the weather.", Functions: []swarmgo.AgentFunction{{
+		Name: "get_weather",
+		Function: func(args map[string]interface{}, _ map[string]interface{}) swarmgo.Result {
+			calls++
+			return swarmgo.Result{Success: true, Data: "Sunny in " + args["city"].(string)}
+		},
+	}}}
+	srv := New(swarmgo.NewSwarmWithCustomProvider(client, nil), config)
+	srv.RegisterAgent("weather", agent)
+	return srv, &calls
+}
+
+func post(t *testing.T, srv http.Handler, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	return rec
+}
+
+// readEvents returns the data of the server-sent events of a response
+func readEvents(t *testing.T, rec *httptest.ResponseRecorder) []string {
+	t.Helper()
+	var events []string
+	scanner := bufio.NewScanner(rec.Body)
+	for scanner.Scan() {
+		if data, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+			events = append(events, data)
+		}
+	}
+	return events
+}
+
+// TestChatCompletionRunsToolsServerSide tests a non-streaming request that runs a tool
+func TestChatCompletionRunsToolsServerSide(t *testing.T) {
+	client := &scriptedLLM{messages: []llm.Message{weatherCall(), {Role: llm.RoleAssistant, Content: "It is sunny in Paris."}}}
+	srv, calls := newTestServer(client, nil)
+
+	rec := post(t, srv, `{"model":"weather","messages":[{"role":"user","content":"Weather in Paris?"}]}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var resp chatResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, "chat.completion", resp.Object)
+	assert.Equal(t, "weather", resp.Model)
+	require.Len(t, resp.Choices, 1)
+	assert.JSONEq(t, `"It is sunny in Paris."`, string(resp.Choices[0].Message.Content))
+	assert.Equal(t, "stop", *resp.Choices[0].FinishReason)
+	assert.Equal(t, 30, resp.Usage.TotalTokens)
+	assert.Equal(t, 1, *calls)
+
+	require.Len(t, client.requests, 2)
+	assert.Equal(t, "gpt-4o", client.requests[0].Model)
+	assert.Equal(t, "You report the weather.", client.requests[0].Messages[0].Content)
+	assert.Equal(t, "Sunny in Paris", client.requests[1].Messages[3].Content)
+}
+
+// TestChatCompletionStreams tests the chunks of a streamed request
+func TestChatCompletionStreams(t *testing.T) {
+	client := &scriptedLLM{messages: []llm.Message{weatherCall(), {Role: llm.RoleAssistant, Content: "Sunny."}}}
+	srv, calls := newTestServer(client, nil)
+
+	rec := post(t, srv, `{"model":"weather","stream":true,"stream_options":{"include_usage":true},"messages":[{"role":"user","content":"Weather?"}]}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/event-stream", rec.Header().Get("Content-Type"))
+
+	events := readEvents(t, rec)
+	require.GreaterOrEqual(t, len(events), 4)
+	assert.Equal(t, "[DONE]", events[len(events)-1])
+
+	var content strings.Builder
+	var finish string
+	var total int
+	for _, data := range events[:len(events)-1] {
+		var chunk chatResponse
+		require.NoError(t, json.Unmarshal([]byte(data), &chunk))
+		assert.Equal(t, "chat.completion.chunk", chunk.Object)
+		if chunk.Usage != nil {
+			total = chunk.Usage.TotalTokens
+		}
+		for _, choice := range chunk.Choices {
+			assert.Empty(t, choice.Delta.ToolCalls, "server-side tool calls are not forwarded")
+			if len(choice.Delta.Content) > 0 {
+				var text string
+				require.NoError(t, json.Unmarshal(choice.Delta.Content, &text))
+				content.WriteString(text)
+			}
+			if choice.FinishReason != nil {
+				finish = *choice.FinishReason
+			}
+		}
+	}
+	assert.Equal(t, "Sunny.", content.String())
+	assert.Equal(t, "stop", finish)
+	assert.Equal(t, 30, total)
+	assert.Equal(t, 1, *calls)
+}
+
+// TestChatCompletionPassesThroughTools tests that tool calls are returned to the
+// client, including tools it sent, and that its results continue the run
+func TestChatCompletionPassesThroughTools(t *testing.T) {
+	lookup := llm.Message{Role: llm.RoleAssistant, ToolCalls: []llm.ToolCall{{
+		ID: "call_7", Type: "function", Function: llm.ToolCallFunction{Name: "lookup_user", Arguments: `{}`},
+	}}}
+	client := &scriptedLLM{messages: []llm.Message{lookup, {Role: llm.RoleAssistant, Content: "Hello Ada."}}}
+	srv, calls := newTestServer(client, &Config{PassThroughTools: true})
+
+	tools := `"tools":[{"type":"function","function":{"name":"lookup_user","description":"Finds the user","parameters":{"type":"object"}}}]`
+	rec := post(t, srv, `{"model":"weather",`+tools+`,"messages":[{"role":"user","content":"Who am I?"}]}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var resp chatResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, "tool_calls", *resp.Choices[0].FinishReason)
+	require.Len(t, resp.Choices[0].Message.ToolCalls, 1)
+	assert.Equal(t, "lookup_user", resp.Choices[0].Message.ToolCalls[0].Function.Name)
+
+	var toolNames []string
+	for _, tool := range client.requests[0].Tools {
+		toolNames = append(toolNames, tool.Function.Name)
+	}
+	assert.ElementsMatch(t, []string{"get_weather", "lookup_user"}, toolNames)
+
+	rec = post(t, srv, `{"model":"weather",`+tools+`,"messages":[
+		{"role":"user","content":"Who am I?"},
+		{"role":"assistant","content":null,"tool_calls":[{"id":"call_7","type":"function","function":{"name":"lookup_user","arguments":"{}"}}]},
+		{"role":"tool","tool_call_id":"call_7","content":"Ada"}]}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.JSONEq(t, `"Hello Ada."`, string(resp.Choices[0].Message.Content))
+
+	result := client.requests[1].Messages[3]
+	assert.Equal(t, llm.RoleTool, result.Role)
+	assert.Equal(t, "lookup_user", result.Name)
+	assert.Equal(t, "Ada", result.Content)
+	assert.Zero(t, *calls)
+}
+
+// TestChatCompletionStreamsPassedThroughTools tests that a streamed run ends at
+// a call to a client tool and forwards the call instead of running it
+func TestChatCompletionStreamsPassedThroughTools(t *testing.T) {
+	lookup := llm.Message{Role: llm.RoleAssistant, ToolCalls: []llm.ToolCall{{
+		ID: "call_7", Type: "function", Function: llm.ToolCallFunction{Name: "lookup_user", Arguments: `{}`},
+	}}}
+	client := &scriptedLLM{messages: []llm.Message{lookup}}
+	srv, calls := newTestServer(client, &Config{PassThroughTools: true})
+
+	tools := `"tools":[{"type":"function","function":{"name":"lookup_user","parameters":{"type":"object"}}}]`
+	rec := post(t, srv, `{"model":"weather","stream":true,`+tools+`,"messages":[{"role":"user","content":"Who am I?"}]}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	events := readEvents(t, rec)
+	require.NotEmpty(t, events)
+	assert.Equal(t, "[DONE]", events[len(events)-1])
+
+	var names []string
+	var finish string
+	for _, data := range events[:len(events)-1] {
+		var chunk chatResponse
+		require.NoError(t, json.Unmarshal([]byte(data), &chunk))
+		assert.NotContains(t, data, `"error"`)
+		for _, choice := range chunk.Choices {
+			for _, call := range choice.Delta.ToolCalls {
+				names = append(names, call.Function.Name)
+			}
+			if choice.FinishReason != nil {
+				finish = *choice.FinishReason
+			}
+		}
+	}
+	assert.Equal(t, []string{"lookup_user"}, names)
+	assert.Equal(t, "tool_calls", finish)
+	assert.Len(t, client.requests, 1, "the run ends at the client's tool call")
+	assert.Zero(t, *calls)
+}
+
+// TestGraphModel tests serving a graph
+func TestGraphModel(t *testing.T) {
+	client := &scriptedLLM{}
+	srv, _ := newTestServer(client, nil)
+
+	graph := swarmgo.NewGraph("echo", "Echoes the last message")
+	graph.AddNode("echo", "Echo", func(ctx context.Context, state swarmgo.GraphState) (swarmgo.GraphState, error) {
+		messages := state[swarmgo.MessageKey].([]llm.Message)
+		next := state.Clone()
+		next[swarmgo.MessageKey] = append(messages, llm.Message{Role: llm.RoleAssistant, Content: "Echo: " + messages[len(messages)-1].Content})
+		return next, nil
+	})
+	require.NoError(t, graph.SetEntryPoint("echo"))
+	require.NoError(t, graph.AddExitPoint("echo"))
+	srv.RegisterGraph("echo", graph)
+	assert.NotNil(t, graph.Swarm())
+
+	rec := post(t, srv, `{"model":"echo","messages":[{"role":"user","content":[{"type":"text","text":"hi"}]}]}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var resp chatResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.JSONEq(t, `"Echo: hi"`, string(resp.Choices[0].Message.Content))
+	assert.Empty(t, client.requests)
+}
+
+// TestModelsAndErrors tests the model list, authentication and request errors
+func TestModelsAndErrors(t *testing.T) {
+	srv, _ := newTestServer(&scriptedLLM{}, &Config{APIKey: "secret"})
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/models", nil)
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	req.Header.Set("Authorization", "Bearer secret")
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var list modelList
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	require.Len(t, list.Data, 1)
+	assert.Equal(t, "weather", list.Data[0].ID)
+	assert.Equal(t, 128000, list.Data[0].ContextWindow)
+
+	for body, status := range map[string]int{
+		`{"model":"unknown","messages":[{"role":"user","content":"hi"}]}`:  http.StatusNotFound,
+		`{"model":"weather","messages":[]}`:                                http.StatusBadRequest,
+		`{"model":"weather","messages":[{"role":"robot","content":"hi"}]}`: http.StatusBadRequest,
+		`not json`: http.StatusBadRequest,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		assert.Equal(t, status, rec.Code, body)
+
+		var errResp errorResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &errResp))
+		assert.NotEmpty(t, errResp.Error.Message)
+	}
+}
@@ -0,0 +1,416 @@
+package swarmgo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iter"
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mohan2020coder/swarmgo/llm"
+)
+
+// SessionSnapshot is the serializable state of a session. Agents are referenced
+// by name, since their functions cannot be serialized.
+type SessionSnapshot struct {
+	ID               string                 `json:"id"`
+	Version          int64                  `json:"version"` // Set by stores on save, see SessionStore
+	Schema           int                    `json:"schema"`  // SessionSchemaVersion of the encoding
+	Agent            string                 `json:"agent"`
+	Messages         []llm.Message          `json:"messages"`
+	ContextVariables map[string]interface{} `json:"context_variables,omitempty"`
+	Usage            llm.Usage              `json:"usage"`
+	Metadata         map[string]string      `json:"metadata,omitempty"`
+	CreatedAt        time.Time              `json:"created_at"`
+	UpdatedAt        time.Time              `json:"updated_at"`
+
+	// Branch is the active branch, Messages and Agent are its state. Branches
+	// holds all branches once the session was forked.
+	Branch   string   `json:"branch,omitempty"`
+	Branches []Branch `json:"branches,omitempty"`
+}
+
+// Session owns the state of a conversation with a swarm: the history, the active
+// agent, context variables, usage and metadata. Each Send runs the agent loop on
+// the history, records the new messages and follows handoffs. Sends on one
+// session are serialized.
+type Session struct {
+	swarm *Swarm
+	store SessionStore
+
+	maxTurns      int
+	historyLimit  int
+	modelOverride string
+	debug         bool
+
+	sendMu sync.Mutex // Held for the duration of a send
+
+	mu               sync.RWMutex
+	id               string
+	version          int64 // Version of the last save or load
+	agent            *Agent
+	agents           map[string]*Agent // Agents a restored session can resume with, by name
+	messages         []llm.Message
+	contextVariables map[string]interface{}
+	usage            llm.Usage
+	metadata         map[string]string
+	createdAt        time.Time
+	updatedAt        time.Time
+	branch           string             // ID of the active branch, whose state is held above
+	branches         map[string]*Branch // All branches, the active one is synced when switching
+}
+
+// NewSession creates a session starting with agent
+func NewSession(swarm *Swarm, agent *Agent) *Session {
+	now := time.Now()
+	s := &Session{
+		swarm:            swarm,
+		maxTurns:         10,
+		id:               uuid.New().String(),
+		agent:            agent,
+		agents:           make(map[string]*Agent),
+		contextVariables: make(map[string]interface{}),
+		metadata:         make(map[string]string),
+		createdAt:        now,
+		updatedAt:        now,
+		branch:           MainBranch,
+		branches:         map[string]*Branch{MainBranch: {ID: MainBranch, CreatedAt: now}},
+	}
+	s.registerAgent(agent)
+	return s
+}
+
+// RestoreSession recreates a session from a snapshot. The snapshot's active agent
+// must be among agents.
+func RestoreSession(swarm *Swarm, snapshot SessionSnapshot, agents ...*Agent) (*Session, error) {
+	if err := migrateSnapshot(&snapshot); err != nil {
+		return nil, err
+	}
+	s := NewSession(swarm, nil).WithAgents(agents...)
+	agent, ok := s.agents[snapshot.Agent]
+	if !ok {
+		return nil, fmt.Errorf("agent %q of session %s is not registered", snapshot.Agent, snapshot.ID)
+	}
+
+	s.id = snapshot.ID
+	s.version = snapshot.Version
+	s.agent = agent
+	s.messages = cloneMessages(snapshot.Messages)
+	if snapshot.ContextVariables != nil {
+		s.contextVariables = maps.Clone(snapshot.ContextVariables)
+	}
+	if snapshot.Metadata != nil {
+		s.metadata = maps.Clone(snapshot.Metadata)
+	}
+	s.usage = snapshot.Usage
+	s.createdAt = snapshot.CreatedAt
+	s.updatedAt = snapshot.UpdatedAt
+
+	s.branch = snapshot.Branch
+	s.branches = make(map[string]*Branch, len(snapshot.Branches)+1)
+	for _, branch := range snapshot.Branches {
+		s.branches[branch.ID] = cloneBranch(branch)
+	}
+	if _, ok := s.branches[s.branch]; !ok {
+		s.branches[s.branch] = &Branch{ID: s.branch, CreatedAt: snapshot.CreatedAt}
+	}
+	return s, nil
+}
+
+// LoadSession resumes the session id from store. Later sends save to the same store.
+func LoadSession(ctx context.Context, swarm *Swarm, store SessionStore, id string, agents ...*Agent) (*Session, error) {
+	snapshot, err := store.Load(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("error loading session %s: %w", id, err)
+	}
+	s, err := RestoreSession(swarm, snapshot, agents...)
+	if err != nil {
+		return nil, err
+	}
+	return s.WithStore(store), nil
+}
+
+// WithStore saves the session to store after every send
+func (s *Session) WithStore(store SessionStore) *Session {
+	s.store = store
+	return s
+}
+
+// WithAgents registers the agents a restored session can resume with. Agents
+// the session hands off to are registered automatically.
+func (s *Session) WithAgents(agents ...*Agent) *Session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, agent := range agents {
+		s.registerAgent(agent)
+	}
+	return s
+}
+
+// WithMaxTurns limits the model responses per send, 0 or less means no limit
+func (s *Session) WithMaxTurns(maxTurns int) *Session {
+	s.maxTurns = maxTurns
+	return s
+}
+
+// WithHistoryLimit limits the messages sent to the model to the most recent
+// limit messages, plus the first system message. The full history is kept.
+func (s *Session) WithHistoryLimit(limit int) *Session {
+	s.historyLimit = limit
+	return s
+}
+
+// WithModelOverride runs all agents of the session with model, except on
+// branches forked with ForkWithModel
+func (s *Session) WithModelOverride(model string) *Session {
+	s.modelOverride = model
+	return s
+}
+
+// WithDebug enables debug logging of the agent loop
+func (s *Session) WithDebug(debug bool) *Session {
+	s.debug = debug
+	return s
+}
+
+// WithContextVariables sets the context variables passed to agent functions
+func (s *Session) WithContextVariables(contextVariables map[string]interface{}) *Session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.contextVariables = maps.Clone(contextVariables)
+	if s.contextVariables == nil {
+		s.contextVariables = make(map[string]interface{})
+	}
+	return s
+}
+
+// registerAgent makes agent available for restoring by name
+func (s *Session) registerAgent(agent *Agent) {
+	if agent != nil {
+		s.agents[agent.Name] = agent
+	}
+}
+
+// ID returns the session ID
+func (s *Session) ID() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.id
+}
+
+// Agent returns the active agent
+func (s *Session) Agent() *Agent {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.agent
+}
+
+// Messages returns a copy of the conversation history
+func (s *Session) Messages() []llm.Message {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return cloneMessages(s.messages)
+}
+
+// ContextVariables returns a copy of the context variables
+func (s *Session) ContextVariables() map[string]interface{} {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return maps.Clone(s.contextVariables)
+}
+
+// Usage returns the token usage summed over all sends
+func (s *Session) Usage() llm.Usage {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.usage
+}
+
+// Metadata returns the metadata value of key
+func (s *Session) Metadata(key string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	value, ok := s.metadata[key]
+	return value, ok
+}
+
+// SetMetadata sets a metadata value, such as a title or user ID
+func (s *Session) SetMetadata(key, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.metadata[key] = value
+	s.updatedAt = time.Now()
+}
+
+// Send sends a user message and runs the agent loop until it answers
+func (s *Session) Send(ctx context.Context, userText string) (Response, error) {
+	return s.SendMessage(ctx, llm.Message{Role: llm.RoleUser, Content: userText})
+}
+
+// SendMessage sends a message, such as one with images, and runs the agent loop.
+// When the run fails, the history is left unchanged. Interrupted runs keep the
+// message and the partial response.
+func (s *Session) SendMessage(ctx context.Context, message llm.Message) (Response, error) {
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+	return s.send(ctx, message)
+}
+
+// send runs the agent loop for message, the caller holds sendMu
+func (s *Session) send(ctx context.Context, message llm.Message) (Response, error) {
+	agent, history, contextVariables, model := s.prepare(message)
+	resp, err := s.swarm.Run(ctx, agent, history, contextVariables, model, false, s.debug, s.maxTurns, true)
+	return resp, s.record(ctx, message, resp, err)
+}
+
+// SendStream sends a user message like Send, streaming the responses to handler
+func (s *Session) SendStream(ctx context.Context, userText string, handler StreamHandler) (Response, error) {
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+
+	message := llm.Message{Role: llm.RoleUser, Content: userText}
+	agent, history, contextVariables, model := s.prepare(message)
+	resp, err := s.swarm.StreamingResponse(ctx, agent, history, contextVariables, model, handler, s.debug, s.maxTurns)
+	return resp, s.record(ctx, message, resp, err)
+}
+
+// Stream sends a user message like Send and yields the events of the run. The
+// session is updated before the final EventDone or EventError event, breaking
+// out of the loop earlier cancels the run without recording it.
+func (s *Session) Stream(ctx context.Context, userText string) iter.Seq[StreamEvent] {
+	return func(yield func(StreamEvent) bool) {
+		s.sendMu.Lock()
+		defer s.sendMu.Unlock()
+
+		message := llm.Message{Role: llm.RoleUser, Content: userText}
+		agent, history, contextVariables, model := s.prepare(message)
+		for event := range s.swarm.RunStream(ctx, agent, history, contextVariables, model, s.debug, s.maxTurns, true) {
+			switch event.Type {
+			case EventDone:
+				if err := s.record(ctx, message, *event.Response, nil); err != nil {
+					event = StreamEvent{Type: EventError, RunID: event.RunID, Agent: event.Agent, Turn: event.Turn, Response: event.Response, Err: err}
+				}
+			case EventError:
+				var resp Response
+				if event.Response != nil {
+					resp = *event.Response
+				}
+				if err := s.record(ctx, message, resp, event.Err); err != event.Err {
+					event.Err = err
+				}
+			}
+			if !yield(event) {
+				return
+			}
+		}
+	}
+}
+
+// prepare returns the active agent, the messages to send with message appended,
+// the context variables and the model override for a run
+func (s *Session) prepare(message llm.Message) (*Agent, []llm.Message, map[string]interface{}, string) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	history := append(s.window(), message)
+	model := s.modelOverride
+	if branch := s.branches[s.branch]; branch.Model != "" {
+		model = branch.Model
+	}
+	return s.agent, history, maps.Clone(s.contextVariables), model
+}
+
+// window returns the history sent to the model, limited by historyLimit
+func (s *Session) window() []llm.Message {
+	if s.historyLimit <= 0 || len(s.messages) <= s.historyLimit {
+		return cloneMessages(s.messages)
+	}
+
+	start := len(s.messages) - s.historyLimit
+	window := make([]llm.Message, 0, s.historyLimit+1)
+	for _, msg := range s.messages[:start] {
+		if msg.Role == llm.RoleSystem {
+			window = append(window, msg)
+			break
+		}
+	}
+	return append(window, s.messages[start:]...)
+}
+
+// record adds a sent message and the response of its run to the session and
+// saves it. Failed runs leave the session unchanged and return their error.
+func (s *Session) record(ctx context.Context, message llm.Message, resp Response, err error) error {
+	if err != nil && !errors.Is(err, ErrInterrupted) {
+		return err
+	}
+
+	s.mu.Lock()
+	s.messages = append(s.messages, message)
+	s.messages = append(s.messages, resp.Messages...)
+	if resp.Agent != nil {
+		s.agent = resp.Agent
+		s.registerAgent(resp.Agent)
+	}
+	if resp.ContextVariables != nil {
+		s.contextVariables = resp.ContextVariables
+	}
+	s.usage = addUsage(s.usage, resp.Usage)
+	s.updatedAt = time.Now()
+	s.mu.Unlock()
+
+	if saveErr := s.Save(context.WithoutCancel(ctx)); saveErr != nil {
+		return errors.Join(err, saveErr)
+	}
+	return err
+}
+
+// Save saves the session to its store, if it has one. It fails with
+// ErrVersionConflict when the session was saved elsewhere since it was loaded.
+func (s *Session) Save(ctx context.Context) error {
+	if s.store == nil {
+		return nil
+	}
+	snapshot := s.Snapshot()
+	version, err := s.store.Save(ctx, snapshot)
+	if err != nil {
+		return fmt.Errorf("error saving session %s: %w", snapshot.ID, err)
+	}
+
+	s.mu.Lock()
+	s.version = version
+	s.mu.Unlock()
+	return nil
+}
+
+// Snapshot returns a copy of the session state
+func (s *Session) Snapshot() SessionSnapshot {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	snapshot := SessionSnapshot{
+		ID:               s.id,
+		Version:          s.version,
+		Schema:           SessionSchemaVersion,
+		Messages:         cloneMessages(s.messages),
+		ContextVariables: maps.Clone(s.contextVariables),
+		Usage:            s.usage,
+		Metadata:         maps.Clone(s.metadata),
+		CreatedAt:        s.createdAt,
+		UpdatedAt:        s.updatedAt,
+	}
+	if s.agent != nil {
+		snapshot.Agent = s.agent.Name
+	}
+	snapshot.Branch = s.branch
+	if len(s.branches) > 1 {
+		snapshot.Branches = s.branchList()
+	}
+	return snapshot
+}
+
+// MarshalJSON encodes the session snapshot
+func (s *Session) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.Snapshot())
+}
@@ -0,0 +1,105 @@
+package swarmgo
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/mohan2020coder/swarmgo/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSessionFollowsHandoffsAndResumes tests that a session records the run, switches
+// agents, saves itself and can be resumed from its store and from JSON
+func TestSessionFollowsHandoffsAndResumes(t *testing.T) {
+	triage, billing := handoffAgents()
+	swarm := NewSwarmWithCustomProvider(handoffScript(), nil)
+	store := NewMemorySessionStore(0)
+
+	session := NewSession(swarm, triage).WithStore(store)
+	session.SetMetadata("user", "ada")
+	resp, err := session.SendStream(context.Background(), "Refund", nil)
+	require.NoError(t, err)
+	assert.Equal(t, billing, resp.Agent)
+
+	assert.Equal(t, billing, session.Agent())
+	messages := session.Messages()
+	require.Len(t, messages, 4)
+	assert.Equal(t, "Refund", messages[0].Content)
+	assert.Equal(t, "Billing here.", messages[3].Content)
+	assert.Equal(t, 5, session.Usage().TotalTokens)
+
+	_, err = LoadSession(context.Background(), swarm, store, session.ID(), triage)
+	assert.ErrorContains(t, err, `agent "Billing"`)
+	_, err = LoadSession(context.Background(), swarm, store, "missing", triage)
+	assert.ErrorIs(t, err, ErrSessionNotFound)
+
+	resumed, err := LoadSession(context.Background(), swarm, store, session.ID(), triage, billing)
+	require.NoError(t, err)
+	assert.Equal(t, billing, resumed.Agent())
+	assert.Equal(t, messages, resumed.Messages())
+	user, _ := resumed.Metadata("user")
+	assert.Equal(t, "ada", user)
+
+	data, err := json.Marshal(session)
+	require.NoError(t, err)
+	var snapshot SessionSnapshot
+	require.NoError(t, json.Unmarshal(data, &snapshot))
+	restored, err := RestoreSession(swarm, snapshot, triage, billing)
+	require.NoError(t, err)
+	assert.Equal(t, session.ID(), restored.ID())
+	assert.Equal(t, "Billing", restored.Snapshot().Agent)
+	assert.Len(t, restored.Messages(), 4)
+	assert.Equal(t, session.Usage(), restored.Usage())
+}
+
+// TestSessionStreamAndFailedSend tests that streamed sends are recorded and failed
+// sends leave the history unchanged
+func TestSessionStreamAndFailedSend(t *testing.T) {
+	client := &scriptedLLM{responses: [][]llm.ChatCompletionResponse{{textDelta("Hi "), textDelta("there.")}}}
+	session := NewSession(NewSwarmWithCustomProvider(client, nil), &Agent{Name: "Greeter", Model: "model"})
+
+	var text string
+	var done bool
+	for event := range session.Stream(context.Background(), "Hello") {
+		switch event.Type {
+		case EventTextDelta:
+			text += event.Text
+		case EventDone:
+			done = true
+			assert.Len(t, session.Messages(), 2, "the session is updated before EventDone")
+		}
+	}
+	assert.True(t, done)
+	assert.Equal(t, "Hi there.", text)
+
+	// The scripted client has no non-streaming responses
+	_, err := session.Send(context.Background(), "Again")
+	require.Error(t, err)
+	messages := session.Messages()
+	require.Len(t, messages, 2)
+	assert.Equal(t, "Hi there.", messages[1].Content)
+}
+
+// TestSessionHistoryLimit tests that only recent messages are sent while the full history is kept
+func TestSessionHistoryLimit(t *testing.T) {
+	client := &scriptedLLM{responses: [][]llm.ChatCompletionResponse{
+		{textDelta("One.")}, {textDelta("Two.")}, {textDelta("Three.")},
+	}}
+	agent := &Agent{Name: "Counter", Model: "model", Instructions: "Count."}
+	session := NewSession(NewSwarmWithCustomProvider(client, nil), agent).WithHistoryLimit(2)
+
+	for _, text := range []string{"a", "b", "c"} {
+		_, err := session.SendStream(context.Background(), text, nil)
+		require.NoError(t, err)
+	}
+
+	assert.Len(t, session.Messages(), 6)
+	sent := client.requests[2].Messages
+	require.Len(t, sent, 4)
+	assert.Equal(t, llm.RoleSystem, sent[0].Role)
+	assert.Equal(t, "b", sent[1].Content)
+	assert.Equal(t, "Two.", sent[2].Content)
+	assert.Equal(t, "c", sent[3].Content)
+}
@@ -0,0 +1,370 @@
+package swarmgo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SessionSchemaVersion is the version of the session snapshot encoding. Stores
+// migrate older snapshots on load and reject newer ones.
+//
+//	1: initial encoding
+//	2: branches
+const SessionSchemaVersion = 2
+
+var (
+	ErrSessionNotFound  = errors.New("session not found")
+	ErrVersionConflict  = errors.New("session was modified concurrently")
+	ErrSessionSchema    = errors.New("unsupported session schema version")
+	ErrInvalidSessionID = errors.New("invalid session ID")
+)
+
+// SessionStore persists session snapshots so sessions can be resumed by ID.
+//
+// Saves use optimistic concurrency: a snapshot must carry the version it was
+// loaded with, 0 for a new session, and Save returns the new version. Saving a
+// snapshot whose version is not the stored one fails with ErrVersionConflict.
+// Sessions that expired by the store's TTL are treated as not existing.
+type SessionStore interface {
+	Save(ctx context.Context, snapshot SessionSnapshot) (int64, error)
+	Load(ctx context.Context, id string) (SessionSnapshot, error)
+	List(ctx context.Context) ([]SessionInfo, error)
+	Delete(ctx context.Context, id string) error
+}
+
+// SessionInfo summarizes a stored session
+type SessionInfo struct {
+	ID        string            `json:"id"`
+	Agent     string            `json:"agent"`
+	Version   int64             `json:"version"`
+	Messages  int               `json:"messages"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+// Info returns the summary of a snapshot
+func (s SessionSnapshot) Info() SessionInfo {
+	return SessionInfo{
+		ID:        s.ID,
+		Agent:     s.Agent,
+		Version:   s.Version,
+		Messages:  len(s.Messages),
+		Metadata:  maps.Clone(s.Metadata),
+		CreatedAt: s.CreatedAt,
+		UpdatedAt: s.UpdatedAt,
+	}
+}
+
+// migrateSnapshot upgrades a snapshot to the current schema. Snapshots without
+// a schema predate versioning and are read as schema 1.
+func migrateSnapshot(snapshot *SessionSnapshot) error {
+	if snapshot.Schema > SessionSchemaVersion {
+		return fmt.Errorf("%w: session %s has schema %d, %d is supported", ErrSessionSchema, snapshot.ID, snapshot.Schema, SessionSchemaVersion)
+	}
+	if snapshot.Schema < 2 {
+		// Sessions without branches are on the main branch
+		snapshot.Branch = MainBranch
+		snapshot.Branches = nil
+	}
+	snapshot.Schema = SessionSchemaVersion
+	return nil
+}
+
+// cloneSnapshot copies a snapshot so stores don't share state with callers
+func cloneSnapshot(snapshot SessionSnapshot) SessionSnapshot {
+	snapshot.Messages = cloneMessages(snapshot.Messages)
+	snapshot.ContextVariables = maps.Clone(snapshot.ContextVariables)
+	snapshot.Metadata = maps.Clone(snapshot.Metadata)
+	if snapshot.Branches != nil {
+		branches := make([]Branch, len(snapshot.Branches))
+		for i, branch := range snapshot.Branches {
+			branches[i] = *cloneBranch(branch)
+		}
+		snapshot.Branches = branches
+	}
+	return snapshot
+}
+
+// sortSessions orders sessions by their last update, most recent first
+func sortSessions(infos []SessionInfo) {
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].UpdatedAt.After(infos[j].UpdatedAt)
+	})
+}
+
+// expired reports whether a session saved at savedAt has expired
+func expired(savedAt time.Time, ttl time.Duration) bool {
+	return ttl > 0 && time.Since(savedAt) > ttl
+}
+
+// MemorySessionStore keeps sessions in memory, for tests and single-process servers
+type MemorySessionStore struct {
+	mu       sync.RWMutex
+	ttl      time.Duration
+	sessions map[string]memorySession
+}
+
+type memorySession struct {
+	snapshot SessionSnapshot
+	savedAt  time.Time
+}
+
+// NewMemorySessionStore creates an in-memory store. Sessions expire ttl after
+// their last save, a ttl of 0 keeps them forever.
+func NewMemorySessionStore(ttl time.Duration) *MemorySessionStore {
+	return &MemorySessionStore{ttl: ttl, sessions: make(map[string]memorySession)}
+}
+
+// Save stores a snapshot if its version is current
+func (m *MemorySessionStore) Save(ctx context.Context, snapshot SessionSnapshot) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var current int64
+	if stored, ok := m.sessions[snapshot.ID]; ok && !expired(stored.savedAt, m.ttl) {
+		current = stored.snapshot.Version
+	}
+	if snapshot.Version != current {
+		return 0, fmt.Errorf("%w: session %s is at version %d, not %d", ErrVersionConflict, snapshot.ID, current, snapshot.Version)
+	}
+
+	snapshot = cloneSnapshot(snapshot)
+	snapshot.Version = current + 1
+	snapshot.Schema = SessionSchemaVersion
+	m.sessions[snapshot.ID] = memorySession{snapshot: snapshot, savedAt: time.Now()}
+	return snapshot.Version, nil
+}
+
+// Load returns the snapshot of a session
+func (m *MemorySessionStore) Load(ctx context.Context, id string) (SessionSnapshot, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	stored, ok := m.sessions[id]
+	if !ok || expired(stored.savedAt, m.ttl) {
+		return SessionSnapshot{}, ErrSessionNotFound
+	}
+	return cloneSnapshot(stored.snapshot), nil
+}
+
+// List returns the stored sessions, most recently updated first
+func (m *MemorySessionStore) List(ctx context.Context) ([]SessionInfo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	infos := make([]SessionInfo, 0, len(m.sessions))
+	for _, stored := range m.sessions {
+		if !expired(stored.savedAt, m.ttl) {
+			infos = append(infos, stored.snapshot.Info())
+		}
+	}
+	sortSessions(infos)
+	return infos, nil
+}
+
+// Delete removes a session
+func (m *MemorySessionStore) Delete(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.sessions, id)
+	return nil
+}
+
+// Purge removes expired sessions and returns how many were removed
+func (m *MemorySessionStore) Purge(ctx context.Context) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	removed := 0
+	for id, stored := range m.sessions {
+		if expired(stored.savedAt, m.ttl) {
+			delete(m.sessions, id)
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// FileSessionStore keeps each session in a JSON file named after its ID. Version
+// checks are only enforced between users of the same store value.
+type FileSessionStore struct {
+	mu  sync.Mutex
+	dir string
+	ttl time.Duration
+}
+
+// NewFileSessionStore creates a store in dir, creating the directory if needed.
+// Sessions expire ttl after their last save, a ttl of 0 keeps them forever.
+func NewFileSessionStore(dir string, ttl time.Duration) (*FileSessionStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("error creating session directory: %w", err)
+	}
+	return &FileSessionStore{dir: dir, ttl: ttl}, nil
+}
+
+// path returns the file of a session, rejecting IDs that are not plain file names
+func (f *FileSessionStore) path(id string) (string, error) {
+	if id == "" || id != filepath.Base(id) || strings.HasPrefix(id, ".") {
+		return "", fmt.Errorf("%w: %q", ErrInvalidSessionID, id)
+	}
+	return filepath.Join(f.dir, id+".json"), nil
+}
+
+// read decodes the session file at path and reports whether it exists and has not expired
+func (f *FileSessionStore) read(path string) (SessionSnapshot, bool, error) {
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) || (err == nil && expired(info.ModTime(), f.ttl)) {
+		return SessionSnapshot{}, false, nil
+	}
+	if err != nil {
+		return SessionSnapshot{}, false, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return SessionSnapshot{}, false, err
+	}
+	var snapshot SessionSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return SessionSnapshot{}, false, fmt.Errorf("error decoding %s: %w", path, err)
+	}
+	if err := migrateSnapshot(&snapshot); err != nil {
+		return SessionSnapshot{}, false, err
+	}
+	return snapshot, true, nil
+}
+
+// Save writes a snapshot if its version is current. The file is replaced
+// atomically, so readers never see a partial write.
+func (f *FileSessionStore) Save(ctx context.Context, snapshot SessionSnapshot) (int64, error) {
+	path, err := f.path(snapshot.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	stored, ok, err := f.read(path)
+	if err != nil {
+		return 0, err
+	}
+	var current int64
+	if ok {
+		current = stored.Version
+	}
+	if snapshot.Version != current {
+		return 0, fmt.Errorf("%w: session %s is at version %d, not %d", ErrVersionConflict, snapshot.ID, current, snapshot.Version)
+	}
+
+	snapshot.Version = current + 1
+	snapshot.Schema = SessionSchemaVersion
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return 0, fmt.Errorf("error encoding session %s: %w", snapshot.ID, err)
+	}
+
+	tmp, err := os.CreateTemp(f.dir, ".session-*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return 0, err
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return 0, err
+	}
+	return snapshot.Version, nil
+}
+
+// Load reads the snapshot of a session
+func (f *FileSessionStore) Load(ctx context.Context, id string) (SessionSnapshot, error) {
+	path, err := f.path(id)
+	if err != nil {
+		return SessionSnapshot{}, err
+	}
+	snapshot, ok, err := f.read(path)
+	if err != nil {
+		return SessionSnapshot{}, err
+	}
+	if !ok {
+		return SessionSnapshot{}, ErrSessionNotFound
+	}
+	return snapshot, nil
+}
+
+// List returns the stored sessions, most recently updated first
+func (f *FileSessionStore) List(ctx context.Context) ([]SessionInfo, error) {
+	paths, err := filepath.Glob(filepath.Join(f.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]SessionInfo, 0, len(paths))
+	for _, path := range paths {
+		if strings.HasPrefix(filepath.Base(path), ".") {
+			continue
+		}
+		snapshot, ok, err := f.read(path)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			infos = append(infos, snapshot.Info())
+		}
+	}
+	sortSessions(infos)
+	return infos, nil
+}
+
+// Delete removes a session
+func (f *FileSessionStore) Delete(ctx context.Context, id string) error {
+	path, err := f.path(id)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Purge removes expired sessions and returns how many were removed
+func (f *FileSessionStore) Purge(ctx context.Context) (int, error) {
+	if f.ttl <= 0 {
+		return 0, nil
+	}
+	paths, err := filepath.Glob(filepath.Join(f.dir, "*.json"))
+	if err != nil {
+		return 0, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	removed := 0
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil || !expired(info.ModTime(), f.ttl) {
+			continue
+		}
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
@@ -0,0 +1,190 @@
+package swarmgo
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/mohan2020coder/swarmgo/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sessionStores creates one store of each kind with the given TTL
+func sessionStores(t *testing.T, ttl time.Duration) map[string]SessionStore {
+	t.Helper()
+	fileStore, err := NewFileSessionStore(filepath.Join(t.TempDir(), "sessions"), ttl)
+	require.NoError(t, err)
+
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "sessions.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	sqliteStore, err := NewSQLiteSessionStore(context.Background(), db, ttl)
+	require.NoError(t, err)
+
+	return map[string]SessionStore{
+		"memory": NewMemorySessionStore(ttl),
+		"file":   fileStore,
+		"sqlite": sqliteStore,
+	}
+}
+
+func testSnapshot(id string) SessionSnapshot {
+	now := time.Now()
+	return SessionSnapshot{
+		ID:        id,
+		Agent:     "Triage",
+		Messages:  []llm.Message{{Role: llm.RoleUser, Content: "Hello"}},
+		Metadata:  map[string]string{"user": "ada"},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// TestSessionStores tests saving, loading, listing, deleting and version conflicts
+func TestSessionStores(t *testing.T) {
+	ctx := context.Background()
+	for name, store := range sessionStores(t, 0) {
+		t.Run(name, func(t *testing.T) {
+			version, err := store.Save(ctx, testSnapshot("a"))
+			require.NoError(t, err)
+			assert.Equal(t, int64(1), version)
+
+			_, err = store.Save(ctx, testSnapshot("a"))
+			assert.ErrorIs(t, err, ErrVersionConflict, "a new session must not replace a stored one")
+
+			loaded, err := store.Load(ctx, "a")
+			require.NoError(t, err)
+			assert.Equal(t, int64(1), loaded.Version)
+			assert.Equal(t, SessionSchemaVersion, loaded.Schema)
+			assert.Equal(t, "Hello", loaded.Messages[0].Content)
+
+			loaded.Messages = append(loaded.Messages, llm.Message{Role: llm.RoleAssistant, Content: "Hi"})
+			loaded.UpdatedAt = loaded.UpdatedAt.Add(time.Second)
+			version, err = store.Save(ctx, loaded)
+			require.NoError(t, err)
+			assert.Equal(t, int64(2), version)
+
+			// The copy loaded before the last save is stale
+			stale := loaded
+			stale.Version = 1
+			_, err = store.Save(ctx, stale)
+			assert.ErrorIs(t, err, ErrVersionConflict)
+
+			_, err = store.Save(ctx, testSnapshot("b"))
+			require.NoError(t, err)
+			infos, err := store.List(ctx)
+			require.NoError(t, err)
+			require.Len(t, infos, 2)
+			assert.Equal(t, "a", infos[0].ID)
+			assert.Equal(t, 2, infos[0].Messages)
+			assert.Equal(t, "ada", infos[0].Metadata["user"])
+
+			require.NoError(t, store.Delete(ctx, "a"))
+			_, err = store.Load(ctx, "a")
+			assert.ErrorIs(t, err, ErrSessionNotFound)
+			require.NoError(t, store.Delete(ctx, "a"))
+		})
+	}
+}
+
+// TestSessionStoresExpire tests that sessions expire after their TTL
+func TestSessionStoresExpire(t *testing.T) {
+	ctx := context.Background()
+	for name, store := range sessionStores(t, 50*time.Millisecond) {
+		t.Run(name, func(t *testing.T) {
+			_, err := store.Save(ctx, testSnapshot("old"))
+			require.NoError(t, err)
+			time.Sleep(100 * time.Millisecond)
+			_, err = store.Save(ctx, testSnapshot("new"))
+			require.NoError(t, err)
+
+			_, err = store.Load(ctx, "old")
+			assert.ErrorIs(t, err, ErrSessionNotFound)
+			infos, err := store.List(ctx)
+			require.NoError(t, err)
+			require.Len(t, infos, 1)
+			assert.Equal(t, "new", infos[0].ID)
+
+			removed, err := store.(interface {
+				Purge(context.Context) (int, error)
+			}).Purge(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, 1, removed)
+
+			// An expired ID can be reused by a new session
+			version, err := store.Save(ctx, testSnapshot("old"))
+			require.NoError(t, err)
+			assert.Equal(t, int64(1), version)
+		})
+	}
+}
+
+// TestFileSessionStoreSchema tests that newer schemas and unsafe IDs are rejected
+func TestFileSessionStoreSchema(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store, err := NewFileSessionStore(dir, 0)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "legacy.json"), []byte(`{"id":"legacy","agent":"Triage","messages":[]}`), 0644))
+	legacy, err := store.Load(ctx, "legacy")
+	require.NoError(t, err)
+	assert.Equal(t, SessionSchemaVersion, legacy.Schema)
+	assert.Equal(t, MainBranch, legacy.Branch)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "future.json"), []byte(`{"id":"future","schema":99}`), 0644))
+	_, err = store.Load(ctx, "future")
+	assert.ErrorIs(t, err, ErrSessionSchema)
+
+	_, err = store.Save(ctx, testSnapshot("../escape"))
+	assert.ErrorIs(t, err, ErrInvalidSessionID)
+}
+
+// TestSQLiteSessionStoreUpgrade tests that databases versioned with
+// user_version are upgraded to swarmgo_migrations
+func TestSQLiteSessionStoreUpgrade(t *testing.T) {
+	ctx := context.Background()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "sessions.db"))
+	require.NoError(t, err)
+	defer db.Close()
+	_, err = db.ExecContext(ctx, "PRAGMA user_version = 99")
+	require.NoError(t, err)
+	_, err = NewSQLiteSessionStore(ctx, db, 0)
+	assert.ErrorContains(t, err, "schema version 99")
+
+	_, err = db.ExecContext(ctx, sessionMigrations[0]+"; PRAGMA user_version = 1")
+	require.NoError(t, err)
+	store, err := NewSQLiteSessionStore(ctx, db, 0)
+	require.NoError(t, err)
+	_, err = store.Save(ctx, testSnapshot("upgraded"))
+	require.NoError(t, err)
+
+	var version int
+	require.NoError(t, db.QueryRowContext(ctx, "SELECT version FROM swarmgo_migrations WHERE component = 'sessions'").Scan(&version))
+	assert.Equal(t, len(sessionMigrations), version)
+}
+
+// TestSessionSaveConflict tests that two copies of a session cannot overwrite each other
+func TestSessionSaveConflict(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemorySessionStore(0)
+	agent := &Agent{Name: "Triage"}
+
+	session := NewSession(&Swarm{}, agent).WithStore(store)
+	require.NoError(t, session.Save(ctx))
+
+	first, err := LoadSession(ctx, &Swarm{}, store, session.ID(), agent)
+	require.NoError(t, err)
+	second, err := LoadSession(ctx, &Swarm{}, store, session.ID(), agent)
+	require.NoError(t, err)
+
+	first.SetMetadata("title", "first")
+	require.NoError(t, first.Save(ctx))
+	second.SetMetadata("title", "second")
+	assert.ErrorIs(t, second.Save(ctx), ErrVersionConflict)
+}
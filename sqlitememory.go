@@ -0,0 +1,159 @@
+package swarmgo
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// memoryMigrations create and upgrade the tables of SQLiteMemoryBackend
+var memoryMigrations = []string{
+	`CREATE TABLE IF NOT EXISTS swarmgo_memories (
+		namespace TEXT NOT NULL,
+		id        TEXT NOT NULL,
+		type      TEXT NOT NULL,
+		timestamp INTEGER NOT NULL,
+		data      TEXT NOT NULL,
+		PRIMARY KEY (namespace, id)
+	);
+	CREATE INDEX IF NOT EXISTS swarmgo_memories_type ON swarmgo_memories (namespace, type, timestamp);
+	CREATE INDEX IF NOT EXISTS swarmgo_memories_timestamp ON swarmgo_memories (namespace, timestamp);
+	CREATE TABLE IF NOT EXISTS swarmgo_memory_context (
+		namespace TEXT NOT NULL,
+		memory_id TEXT NOT NULL,
+		key       TEXT NOT NULL,
+		value     TEXT NOT NULL,
+		PRIMARY KEY (namespace, memory_id, key)
+	);
+	CREATE INDEX IF NOT EXISTS swarmgo_memory_context_value ON swarmgo_memory_context (namespace, key, value);`,
+}
+
+// SQLiteMemoryBackend keeps memories in a SQLite database, indexed by type,
+// context value and timestamp. It works with any registered SQLite driver, such
+// as github.com/mattn/go-sqlite3.
+type SQLiteMemoryBackend struct {
+	db        *sql.DB
+	namespace string
+}
+
+// NewSQLiteMemoryBackend creates a backend on db and migrates its tables. The
+// namespace separates the memories of agents that share a database.
+func NewSQLiteMemoryBackend(ctx context.Context, db *sql.DB, namespace string) (*SQLiteMemoryBackend, error) {
+	if err := migrateSQLite(ctx, db, "memories", memoryMigrations); err != nil {
+		return nil, fmt.Errorf("error migrating memory backend: %w", err)
+	}
+	return &SQLiteMemoryBackend{db: db, namespace: namespace}, nil
+}
+
+// Add inserts a memory and its context values in one transaction
+func (b *SQLiteMemoryBackend) Add(ctx context.Context, memory Memory) error {
+	memory = withMemoryID(memory)
+	data, err := json.Marshal(memory)
+	if err != nil {
+		return fmt.Errorf("error encoding memory %s: %w", memory.ID, err)
+	}
+
+	tx, err := b.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT OR REPLACE INTO swarmgo_memories (namespace, id, type, timestamp, data) VALUES (?, ?, ?, ?, ?)`,
+		b.namespace, memory.ID, memory.Type, memory.Timestamp.UnixNano(), string(data)); err != nil {
+		return fmt.Errorf("error saving memory %s: %w", memory.ID, err)
+	}
+	if _, err := tx.ExecContext(ctx, `
+		DELETE FROM swarmgo_memory_context WHERE namespace = ? AND memory_id = ?`, b.namespace, memory.ID); err != nil {
+		return err
+	}
+	for key, value := range memory.Context {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO swarmgo_memory_context (namespace, memory_id, key, value) VALUES (?, ?, ?, ?)`,
+			b.namespace, memory.ID, key, contextValue(value)); err != nil {
+			return fmt.Errorf("error saving context of memory %s: %w", memory.ID, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// Search returns the memories matching query
+func (b *SQLiteMemoryBackend) Search(ctx context.Context, query MemoryQuery) ([]Memory, error) {
+	conditions := []string{"namespace = ?"}
+	args := []interface{}{b.namespace}
+	if query.Type != "" {
+		conditions = append(conditions, "type = ?")
+		args = append(args, query.Type)
+	}
+	if !query.Since.IsZero() {
+		conditions = append(conditions, "timestamp >= ?")
+		args = append(args, query.Since.UnixNano())
+	}
+	if !query.Until.IsZero() {
+		conditions = append(conditions, "timestamp <= ?")
+		args = append(args, query.Until.UnixNano())
+	}
+	for key, value := range query.Context {
+		conditions = append(conditions, "id IN (SELECT memory_id FROM swarmgo_memory_context WHERE namespace = ? AND key = ? AND value = ?)")
+		args = append(args, b.namespace, key, contextValue(value))
+	}
+	limit := -1
+	if query.Limit > 0 {
+		limit = query.Limit
+	}
+	args = append(args, limit)
+
+	// Select the most recent matches, then return them oldest first
+	rows, err := b.db.QueryContext(ctx, `
+		SELECT data FROM swarmgo_memories WHERE `+strings.Join(conditions, " AND ")+`
+		ORDER BY timestamp DESC, rowid DESC LIMIT ?`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error searching memories: %w", err)
+	}
+	defer rows.Close()
+
+	var memories []Memory
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var memory Memory
+		if err := json.Unmarshal([]byte(data), &memory); err != nil {
+			return nil, fmt.Errorf("error decoding memory: %w", err)
+		}
+		memories = append(memories, memory)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(memories)-1; i < j; i, j = i+1, j-1 {
+		memories[i], memories[j] = memories[j], memories[i]
+	}
+	return memories, nil
+}
+
+// Clear removes all memories of the namespace
+func (b *SQLiteMemoryBackend) Clear(ctx context.Context) error {
+	tx, err := b.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM swarmgo_memory_context WHERE namespace = ?", b.namespace); err != nil {
+		return fmt.Errorf("error clearing memories: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM swarmgo_memories WHERE namespace = ?", b.namespace); err != nil {
+		return fmt.Errorf("error clearing memories: %w", err)
+	}
+	return tx.Commit()
+}
+
+// Close does nothing, the caller owns the database
+func (b *SQLiteMemoryBackend) Close() error {
+	return nil
+}
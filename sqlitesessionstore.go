@@ -0,0 +1,202 @@
+package swarmgo
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// sessionMigrations create and upgrade the tables of SQLiteSessionStore
+var sessionMigrations = []string{
+	`CREATE TABLE IF NOT EXISTS swarmgo_sessions (
+		id         TEXT PRIMARY KEY,
+		version    INTEGER NOT NULL,
+		agent      TEXT NOT NULL,
+		data       TEXT NOT NULL,
+		created_at INTEGER NOT NULL,
+		updated_at INTEGER NOT NULL,
+		saved_at   INTEGER NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS swarmgo_sessions_saved_at ON swarmgo_sessions (saved_at);`,
+}
+
+// SQLiteSessionStore keeps sessions in a SQLite database. It works with any
+// registered SQLite driver, such as github.com/mattn/go-sqlite3.
+type SQLiteSessionStore struct {
+	db  *sql.DB
+	ttl time.Duration
+}
+
+// NewSQLiteSessionStore creates a store on db and migrates its tables. Sessions
+// expire ttl after their last save, a ttl of 0 keeps them forever.
+func NewSQLiteSessionStore(ctx context.Context, db *sql.DB, ttl time.Duration) (*SQLiteSessionStore, error) {
+	if err := migrateSQLite(ctx, db, "sessions", sessionMigrations); err != nil {
+		return nil, fmt.Errorf("error migrating session store: %w", err)
+	}
+	return &SQLiteSessionStore{db: db, ttl: ttl}, nil
+}
+
+// legacyVersionComponent recorded its schema version in the database's
+// user_version before swarmgo_migrations existed
+const legacyVersionComponent = "sessions"
+
+// migrateSQLite applies the migrations of component that are newer than its
+// version in swarmgo_migrations, so several stores can share one database
+func migrateSQLite(ctx context.Context, db *sql.DB, component string, migrations []string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS swarmgo_migrations (
+		component TEXT PRIMARY KEY,
+		version   INTEGER NOT NULL
+	)`); err != nil {
+		return err
+	}
+	var version int
+	err = tx.QueryRowContext(ctx, "SELECT version FROM swarmgo_migrations WHERE component = ?", component).Scan(&version)
+	if errors.Is(err, sql.ErrNoRows) && component == legacyVersionComponent {
+		// Upgrade databases created before swarmgo_migrations
+		err = tx.QueryRowContext(ctx, "PRAGMA user_version").Scan(&version)
+	}
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	if version > len(migrations) {
+		return fmt.Errorf("%s schema version %d is newer than %d", component, version, len(migrations))
+	}
+	for i := version; i < len(migrations); i++ {
+		if _, err := tx.ExecContext(ctx, migrations[i]); err != nil {
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO swarmgo_migrations (component, version) VALUES (?, ?)
+		ON CONFLICT (component) DO UPDATE SET version = excluded.version`, component, len(migrations)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// cutoff returns the save time before which sessions have expired, in Unix nanoseconds
+func (s *SQLiteSessionStore) cutoff() int64 {
+	if s.ttl <= 0 {
+		return 0
+	}
+	return time.Now().Add(-s.ttl).UnixNano()
+}
+
+// Save stores a snapshot if its version is current. New sessions replace
+// expired ones with the same ID.
+func (s *SQLiteSessionStore) Save(ctx context.Context, snapshot SessionSnapshot) (int64, error) {
+	version := snapshot.Version + 1
+	snapshot.Version = version
+	snapshot.Schema = SessionSchemaVersion
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return 0, fmt.Errorf("error encoding session %s: %w", snapshot.ID, err)
+	}
+
+	now := time.Now().UnixNano()
+	var result sql.Result
+	if version == 1 {
+		result, err = s.db.ExecContext(ctx, `
+			INSERT INTO swarmgo_sessions (id, version, agent, data, created_at, updated_at, saved_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT (id) DO UPDATE SET
+				version = excluded.version, agent = excluded.agent, data = excluded.data,
+				created_at = excluded.created_at, updated_at = excluded.updated_at, saved_at = excluded.saved_at
+			WHERE swarmgo_sessions.saved_at < ?`,
+			snapshot.ID, version, snapshot.Agent, string(data), snapshot.CreatedAt.UnixNano(), snapshot.UpdatedAt.UnixNano(), now, s.cutoff())
+	} else {
+		result, err = s.db.ExecContext(ctx, `
+			UPDATE swarmgo_sessions SET version = ?, agent = ?, data = ?, updated_at = ?, saved_at = ?
+			WHERE id = ? AND version = ? AND saved_at >= ?`,
+			version, snapshot.Agent, string(data), snapshot.UpdatedAt.UnixNano(), now, snapshot.ID, version-1, s.cutoff())
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error saving session %s: %w", snapshot.ID, err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if rows == 0 {
+		return 0, fmt.Errorf("%w: session %s is not at version %d", ErrVersionConflict, snapshot.ID, version-1)
+	}
+	return version, nil
+}
+
+// Load returns the snapshot of a session
+func (s *SQLiteSessionStore) Load(ctx context.Context, id string) (SessionSnapshot, error) {
+	var version int64
+	var data string
+	err := s.db.QueryRowContext(ctx, `
+		SELECT version, data FROM swarmgo_sessions WHERE id = ? AND saved_at >= ?`,
+		id, s.cutoff()).Scan(&version, &data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return SessionSnapshot{}, ErrSessionNotFound
+	}
+	if err != nil {
+		return SessionSnapshot{}, fmt.Errorf("error loading session %s: %w", id, err)
+	}
+
+	var snapshot SessionSnapshot
+	if err := json.Unmarshal([]byte(data), &snapshot); err != nil {
+		return SessionSnapshot{}, fmt.Errorf("error decoding session %s: %w", id, err)
+	}
+	if err := migrateSnapshot(&snapshot); err != nil {
+		return SessionSnapshot{}, err
+	}
+	snapshot.Version = version
+	return snapshot, nil
+}
+
+// List returns the stored sessions, most recently updated first
+func (s *SQLiteSessionStore) List(ctx context.Context) ([]SessionInfo, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT data FROM swarmgo_sessions WHERE saved_at >= ? ORDER BY updated_at DESC`, s.cutoff())
+	if err != nil {
+		return nil, fmt.Errorf("error listing sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var infos []SessionInfo
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var snapshot SessionSnapshot
+		if err := json.Unmarshal([]byte(data), &snapshot); err != nil {
+			return nil, fmt.Errorf("error decoding session: %w", err)
+		}
+		infos = append(infos, snapshot.Info())
+	}
+	return infos, rows.Err()
+}
+
+// Delete removes a session
+func (s *SQLiteSessionStore) Delete(ctx context.Context, id string) error {
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM swarmgo_sessions WHERE id = ?", id); err != nil {
+		return fmt.Errorf("error deleting session %s: %w", id, err)
+	}
+	return nil
+}
+
+// Purge removes expired sessions and returns how many were removed
+func (s *SQLiteSessionStore) Purge(ctx context.Context) (int, error) {
+	if s.ttl <= 0 {
+		return 0, nil
+	}
+	result, err := s.db.ExecContext(ctx, "DELETE FROM swarmgo_sessions WHERE saved_at < ?", s.cutoff())
+	if err != nil {
+		return 0, fmt.Errorf("error purging sessions: %w", err)
+	}
+	removed, err := result.RowsAffected()
+	return int(removed), err
+}
@@ -0,0 +1,104 @@
+package swarmgo
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/mohan2020coder/swarmgo/llm"
+)
+
+// ErrInterrupted is returned together with the partial response when the context
+// of a run is cancelled
+var ErrInterrupted = errors.New("run interrupted")
+
+// interruptedToolResult is the function result recorded for tool calls that did not run
+const interruptedToolResult = "Error: interrupted before the tool ran"
+
+// StopHandle stops runs gracefully, like a "stop generating" button. Unlike
+// cancelling their context, stopping a run is not an error: it returns the
+// partial response with Interrupted set.
+type StopHandle struct {
+	once sync.Once
+	done chan struct{}
+}
+
+// NewStopHandle creates a stop handle
+func NewStopHandle() *StopHandle {
+	return &StopHandle{done: make(chan struct{})}
+}
+
+// Stop stops the runs using the handle, it is safe to call more than once
+func (h *StopHandle) Stop() {
+	h.once.Do(func() { close(h.done) })
+}
+
+// Done returns a channel that is closed once Stop was called
+func (h *StopHandle) Done() <-chan struct{} {
+	return h.done
+}
+
+// Stopped reports whether Stop was called
+func (h *StopHandle) Stopped() bool {
+	select {
+	case <-h.done:
+		return true
+	default:
+		return false
+	}
+}
+
+type stopHandleKey struct{}
+
+// WithStopHandle returns a context that lets h stop the runs started with it
+func WithStopHandle(ctx context.Context, h *StopHandle) context.Context {
+	return context.WithValue(ctx, stopHandleKey{}, h)
+}
+
+// stopHandleFrom returns the stop handle of a context, if any
+func stopHandleFrom(ctx context.Context) *StopHandle {
+	h, _ := ctx.Value(stopHandleKey{}).(*StopHandle)
+	return h
+}
+
+// withStop returns a context that is cancelled when the stop handle of ctx is stopped
+func withStop(ctx context.Context) (context.Context, context.CancelFunc) {
+	h := stopHandleFrom(ctx)
+	if h == nil {
+		return context.WithCancel(ctx)
+	}
+	runCtx, cancel := context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-h.Done():
+			cancel()
+		case <-runCtx.Done():
+		}
+	}()
+	return runCtx, cancel
+}
+
+// answerToolCalls returns the function results for toolCalls, adding interrupted
+// results for the calls that did not run so the history stays valid for providers
+func answerToolCalls(toolCalls []llm.ToolCall, toolMessages []llm.Message) []llm.Message {
+	answered := make(map[string]llm.Message, len(toolMessages))
+	for _, msg := range toolMessages {
+		answered[msg.ToolCallID] = msg
+	}
+
+	messages := make([]llm.Message, 0, len(toolCalls))
+	for _, call := range toolCalls {
+		if msg, ok := answered[call.ID]; ok {
+			messages = append(messages, msg)
+			continue
+		}
+		messages = append(messages, llm.Message{
+			Role:        llm.RoleFunction,
+			Content:     interruptedToolResult,
+			Name:        call.Function.Name,
+			ToolCallID:  call.ID,
+			Interrupted: true,
+		})
+	}
+	return messages
+}
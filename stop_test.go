@@ -0,0 +1,108 @@
+package swarmgo
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mohan2020coder/swarmgo/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// hangingStream sends its chunks and then blocks until the request is cancelled
+type hangingStream struct {
+	ctx    context.Context
+	chunks []llm.ChatCompletionResponse
+}
+
+func (s *hangingStream) Recv() (llm.ChatCompletionResponse, error) {
+	if len(s.chunks) > 0 {
+		chunk := s.chunks[0]
+		s.chunks = s.chunks[1:]
+		return chunk, nil
+	}
+	<-s.ctx.Done()
+	return llm.ChatCompletionResponse{}, errors.New("read on closed connection")
+}
+
+func (s *hangingStream) Close() error { return nil }
+
+type hangingLLM struct {
+	chunks []llm.ChatCompletionResponse
+}
+
+func (l *hangingLLM) CreateChatCompletion(ctx context.Context, req llm.ChatCompletionRequest) (llm.ChatCompletionResponse, error) {
+	return llm.ChatCompletionResponse{}, errors.New("not supported")
+}
+
+func (l *hangingLLM) CreateChatCompletionStream(ctx context.Context, req llm.ChatCompletionRequest) (llm.ChatCompletionStream, error) {
+	return &hangingStream{ctx: ctx, chunks: l.chunks}, nil
+}
+
+type stoppingHandler struct {
+	DefaultStreamHandler
+	stop   *StopHandle
+	tokens int
+}
+
+func (h *stoppingHandler) OnToken(token string) {
+	h.tokens++
+	if h.tokens == 2 {
+		h.stop.Stop()
+	}
+}
+
+// TestStopHandleKeepsPartialMessage tests that stopping a stream returns the text received so far
+func TestStopHandleKeepsPartialMessage(t *testing.T) {
+	client := &hangingLLM{chunks: []llm.ChatCompletionResponse{textDelta("Hello "), textDelta("wor")}}
+	swarm := NewSwarmWithCustomProvider(client, nil)
+	stop := NewStopHandle()
+
+	resp, err := swarm.StreamingResponse(WithStopHandle(context.Background(), stop), &Agent{Name: "Writer", Model: "model"},
+		[]llm.Message{{Role: llm.RoleUser, Content: "Write"}}, nil, "", &stoppingHandler{stop: stop}, false, 5)
+	require.NoError(t, err)
+
+	assert.True(t, resp.Interrupted)
+	require.Len(t, resp.Messages, 1)
+	assert.Equal(t, "Hello wor", resp.Messages[0].Content)
+	assert.True(t, resp.Messages[0].Interrupted)
+	assert.True(t, stop.Stopped())
+}
+
+// TestCancelledRunKeepsCompletedToolResults tests that cancellation during tool calls keeps finished results
+func TestCancelledRunKeepsCompletedToolResults(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ran := 0
+	agent := &Agent{Name: "Worker", Model: "model", Functions: []AgentFunction{
+		{Name: "first", Function: func(args map[string]interface{}, _ map[string]interface{}) Result {
+			ran++
+			cancel()
+			return Result{Success: true, Data: "done"}
+		}},
+		{Name: "second", Function: func(args map[string]interface{}, _ map[string]interface{}) Result {
+			ran++
+			return Result{Success: true, Data: "done"}
+		}},
+	}}
+	client := &scriptedLLM{responses: [][]llm.ChatCompletionResponse{{
+		toolDelta(0, "call_1", "first", "{}"),
+		toolDelta(1, "call_2", "second", "{}"),
+	}}}
+	swarm := NewSwarmWithCustomProvider(client, nil)
+
+	resp, err := swarm.Run(ctx, agent, []llm.Message{{Role: llm.RoleUser, Content: "Work"}}, nil, "", true, false, 5, true)
+	assert.ErrorIs(t, err, ErrInterrupted)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	assert.True(t, resp.Interrupted)
+	assert.Equal(t, 1, ran)
+	assert.Len(t, resp.ToolResults, 1)
+	require.Len(t, resp.Messages, 3)
+	assert.Equal(t, "done", resp.Messages[1].Content)
+	assert.Equal(t, "call_2", resp.Messages[2].ToolCallID)
+	assert.True(t, resp.Messages[2].Interrupted)
+	assert.Len(t, client.requests, 1)
+}
@@ -0,0 +1,157 @@
+package swarmgo
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/mohan2020coder/swarmgo/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scriptedStream replays a fixed list of chunks
+type scriptedStream struct {
+	chunks []llm.ChatCompletionResponse
+}
+
+func (s *scriptedStream) Recv() (llm.ChatCompletionResponse, error) {
+	if len(s.chunks) == 0 {
+		return llm.ChatCompletionResponse{}, io.EOF
+	}
+	chunk := s.chunks[0]
+	s.chunks = s.chunks[1:]
+	return chunk, nil
+}
+
+func (s *scriptedStream) Close() error { return nil }
+
+// scriptedLLM streams one scripted response per request
+type scriptedLLM struct {
+	responses [][]llm.ChatCompletionResponse
+	requests  []llm.ChatCompletionRequest
+}
+
+func (l *scriptedLLM) CreateChatCompletion(ctx context.Context, req llm.ChatCompletionRequest) (llm.ChatCompletionResponse, error) {
+	return llm.ChatCompletionResponse{}, errors.New("not scripted")
+}
+
+func (l *scriptedLLM) CreateChatCompletionStream(ctx context.Context, req llm.ChatCompletionRequest) (llm.ChatCompletionStream, error) {
+	l.requests = append(l.requests, req)
+	if len(l.responses) == 0 {
+		return nil, errors.New("no more scripted responses")
+	}
+	chunks := l.responses[0]
+	l.responses = l.responses[1:]
+	return &scriptedStream{chunks: chunks}, nil
+}
+
+func toolDelta(index int, id, name, arguments string) llm.ChatCompletionResponse {
+	return llm.ChatCompletionResponse{Choices: []llm.Choice{{Message: llm.Message{ToolCalls: []llm.ToolCall{{
+		Index: index, ID: id, Function: llm.ToolCallFunction{Name: name, Arguments: arguments},
+	}}}}}}
+}
+
+func textDelta(content string) llm.ChatCompletionResponse {
+	return llm.ChatCompletionResponse{Choices: []llm.Choice{{Message: llm.Message{Content: content}}}}
+}
+
+type recordingHandler struct {
+	DefaultStreamHandler
+	tokens    []string
+	toolCalls []llm.ToolCall
+	completed llm.Message
+}
+
+func (h *recordingHandler) OnToken(token string) { h.tokens = append(h.tokens, token) }
+func (h *recordingHandler) OnToolCall(toolCall llm.ToolCall) {
+	h.toolCalls = append(h.toolCalls, toolCall)
+}
+func (h *recordingHandler) OnComplete(message llm.Message) { h.completed = message }
+
+// TestStreamingResponseAssemblesToolCallsAndHandsOff tests interleaved tool call deltas,
+// execution after the stream ends, handoffs and usage aggregation
+func TestStreamingResponseAssemblesToolCallsAndHandsOff(t *testing.T) {
+	billing := &Agent{Name: "Billing", Instructions: "You handle billing.", Model: "model"}
+	var lookups []map[string]interface{}
+	triage := &Agent{
+		Name:         "Triage",
+		Instructions: "You route requests.",
+		Model:        "model",
+		Functions: []AgentFunction{
+			{Name: "lookup", Function: func(args map[string]interface{}, _ map[string]interface{}) Result {
+				lookups = append(lookups, args)
+				return Result{Success: true, Data: "found"}
+			}},
+			{Name: "transfer", Function: func(args map[string]interface{}, _ map[string]interface{}) Result {
+				return Result{Success: true, Data: "transferred", Agent: billing}
+			}},
+		},
+	}
+
+	usage := llm.ChatCompletionResponse{Usage: llm.Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15}}
+	client := &scriptedLLM{responses: [][]llm.ChatCompletionResponse{
+		{
+			toolDelta(0, "call_a", "lookup", "{"),
+			toolDelta(1, "call_b", "transfer", "{}"),
+			toolDelta(0, "", "", `"id": 7`),
+			toolDelta(0, "", "", "}"),
+			usage,
+		},
+		{textDelta("Billing "), textDelta("here."), usage},
+	}}
+	swarm := NewSwarmWithCustomProvider(client, nil)
+	handler := &recordingHandler{}
+
+	resp, err := swarm.StreamingResponse(context.Background(), triage,
+		[]llm.Message{{Role: llm.RoleUser, Content: "My invoice 7"}}, nil, "", handler, false, 5)
+	require.NoError(t, err)
+
+	// The lookup ran once, with the complete arguments
+	assert.Equal(t, []map[string]interface{}{{"id": float64(7)}}, lookups)
+	require.Len(t, handler.toolCalls, 2)
+	assert.Equal(t, `{"id": 7}`, handler.toolCalls[0].Function.Arguments)
+
+	require.Len(t, resp.Messages, 4)
+	assert.Len(t, resp.Messages[0].ToolCalls, 2)
+	assert.Equal(t, "call_a", resp.Messages[1].ToolCallID)
+	assert.Equal(t, "call_b", resp.Messages[2].ToolCallID)
+	assert.Equal(t, "Billing here.", resp.Messages[3].Content)
+	assert.Equal(t, billing, resp.Agent)
+	assert.Len(t, resp.ToolResults, 2)
+	assert.Equal(t, 30, resp.Usage.TotalTokens)
+	assert.Equal(t, "Billing here.", handler.completed.Content)
+	assert.Equal(t, []string{"Billing ", "here."}, handler.tokens)
+
+	// The second turn was sent as the billing agent
+	require.Len(t, client.requests, 2)
+	assert.Equal(t, "You handle billing.", client.requests[1].Messages[0].Content)
+	assert.Empty(t, client.requests[1].Tools)
+	assert.Len(t, client.requests[1].Messages, 5)
+}
+
+// TestRunStopsAtMaxTurns tests that tool loops end after maxTurns responses
+func TestRunStopsAtMaxTurns(t *testing.T) {
+	calls := 0
+	agent := &Agent{Name: "Looper", Model: "model", Functions: []AgentFunction{
+		{Name: "again", Function: func(args map[string]interface{}, _ map[string]interface{}) Result {
+			calls++
+			return Result{Success: true, Data: "again"}
+		}},
+	}}
+
+	var responses [][]llm.ChatCompletionResponse
+	for i := 0; i < 5; i++ {
+		responses = append(responses, []llm.ChatCompletionResponse{toolDelta(0, "", "again", "{}")})
+	}
+	client := &scriptedLLM{responses: responses}
+	swarm := NewSwarmWithCustomProvider(client, nil)
+
+	resp, err := swarm.Run(context.Background(), agent, []llm.Message{{Role: llm.RoleUser, Content: "loop"}}, nil, "", true, false, 2, true)
+	require.NoError(t, err)
+	assert.Len(t, client.requests, 2)
+	assert.Equal(t, 2, calls)
+	assert.Len(t, resp.Messages, 4)
+	assert.Equal(t, "call_0", resp.Messages[0].ToolCalls[0].ID)
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"strings"
 	"time"
@@ -20,14 +21,19 @@ var (
 	ErrInvalidProvider   = errors.New("invalid LLM provider specified")
 	ErrNoChoicesInResp   = errors.New("no choices in LLM response")
 	ErrMessageTooLong    = errors.New("message exceeds maximum token limit")
+	ErrNoEmbeddings      = errors.New("LLM provider does not support embeddings")
 )
 
 // Swarm represents the main structure
 type Swarm struct {
 	client       llm.LLM
+	provider     llm.LLMProvider  // Provider of the default client
 	tokenCounter func(string) int // Optional token counter function
 	initialized  bool             // Flag to check if Swarm is properly initialized
 	config       *Config          // Configuration settings
+	rateLimiter  *RateLimiter     // Optional client-side rate limiter
+	breaker      *CircuitBreaker  // Optional circuit breaker
+	registry     providerRegistry // Per-provider clients used by agents with their own provider
 }
 
 // Config holds configuration options for Swarm
@@ -39,9 +45,12 @@ type Config struct {
 	DefaultModel      string
 	Debug             bool
 	LogLevel          LogLevel
-	TokenLimits       map[string]int // Model-specific token limits
+	TokenLimits       map[string]int    // Model-specific token limits, taking precedence over the catalog
+	Catalog           *llm.ModelCatalog // Model metadata, nil uses llm.DefaultModelCatalog
 	FailureHandlers   []FailureHandler
 	RateLimitStrategy RateLimitStrategy
+	RateLimiter       *RateLimiter    // Optional limiter, may be shared between swarms
+	CircuitBreaker    *CircuitBreaker // Optional circuit breaker, may be shared between swarms
 }
 
 // LogLevel represents the level of logging
@@ -64,30 +73,47 @@ type RateLimitStrategy int
 
 const (
 	RateLimitRetry RateLimitStrategy = iota
-	RateLimitFail
-	RateLimitQueue
+	RateLimitFail                    // Fail immediately when the client-side budget is exhausted
+	RateLimitQueue                   // Queue callers until the client-side budget allows the request
 )
 
 // DefaultConfig returns default configuration values
 func DefaultConfig() *Config {
 	return &Config{
-		MaxRetries:     3,
-		RetryBackoff:   time.Second,
-		RequestTimeout: 60 * time.Second,
-		MaxTokens:      4096,
-		DefaultModel:   "gpt-3.5-turbo",
-		Debug:          false,
-		LogLevel:       LogError,
-		TokenLimits: map[string]int{
-			"gpt-3.5-turbo": 4096,
-			"gpt-4":         8192,
-			"gpt-4o":        128000,
-			"claude-3-opus": 200000,
-		},
+		MaxRetries:        3,
+		RetryBackoff:      time.Second,
+		RequestTimeout:    60 * time.Second,
+		MaxTokens:         4096,
+		DefaultModel:      "gpt-3.5-turbo",
+		Debug:             false,
+		LogLevel:          LogError,
+		TokenLimits:       map[string]int{},
 		RateLimitStrategy: RateLimitRetry,
 	}
 }
 
+// ModelCatalog returns the catalog used for model metadata
+func (c *Config) ModelCatalog() *llm.ModelCatalog {
+	if c == nil || c.Catalog == nil {
+		return llm.DefaultModelCatalog()
+	}
+	return c.Catalog
+}
+
+// ContextWindow returns the token limit of a model from TokenLimits or the
+// catalog, 0 if it is unknown
+func (c *Config) ContextWindow(model string) int {
+	if c != nil {
+		if limit, ok := c.TokenLimits[model]; ok {
+			return limit
+		}
+	}
+	if info, ok := c.ModelCatalog().Lookup(model); ok {
+		return info.ContextWindow
+	}
+	return 0
+}
+
 // NewSwarm initializes a new Swarm instance with an LLM client
 func NewSwarm(apiKey string, provider llm.LLMProvider) *Swarm {
 	return NewSwarmWithConfig(apiKey, provider, DefaultConfig())
@@ -95,271 +121,345 @@ func NewSwarm(apiKey string, provider llm.LLMProvider) *Swarm {
 
 // NewSwarmWithConfig initializes a new Swarm with custom configuration
 func NewSwarmWithConfig(apiKey string, provider llm.LLMProvider, config *Config) *Swarm {
-	if apiKey == "" {
-		log.Println("Warning: Empty API key provided")
-		return &Swarm{
-			initialized: false,
-			config:      config,
-		}
-	}
-
-	var client llm.LLM
-	var err error
-
-	switch provider {
-	case llm.OpenAI:
-		client = llm.NewOpenAILLM(apiKey)
-
-	case llm.Gemini:
-		client, err = llm.NewGeminiLLM(apiKey)
-		if err != nil {
-			log.Printf("Failed to create Gemini client: %v", err)
-			return &Swarm{initialized: false, config: config}
-		}
+	return NewSwarmFromClientConfig(&ClientConfig{Provider: provider, AuthToken: apiKey}, config)
+}
 
-	case llm.Claude:
-		client = llm.NewClaudeLLM(apiKey)
+// NewSwarmWithHost creates a Swarm with a custom host
+func NewSwarmWithHost(apiKey, host string, provider llm.LLMProvider) *Swarm {
+	return NewSwarmFromClientConfig(&ClientConfig{Provider: provider, AuthToken: apiKey, BaseURL: host}, DefaultConfig())
+}
 
-	case llm.Ollama:
-		client, err = llm.NewOllamaLLM()
-		if err != nil {
-			log.Printf("Failed to create Ollama client: %v", err)
-			return &Swarm{initialized: false, config: config}
+// NewSwarmFromClientConfig creates a Swarm whose default client is built from clientConfig
+func NewSwarmFromClientConfig(clientConfig *ClientConfig, config *Config) *Swarm {
+	sw := &Swarm{
+		config: config,
+	}
+	if config != nil {
+		sw.rateLimiter = config.RateLimiter
+		sw.breaker = config.CircuitBreaker
+		if config.DefaultModel != "" {
+			if _, ok := config.ModelCatalog().Lookup(config.DefaultModel); !ok {
+				log.Printf("Warning: Default model %q is not in the model catalog", config.DefaultModel)
+			}
 		}
+	}
+	if clientConfig == nil {
+		log.Println("Warning: Nil client config provided")
+		return sw
+	}
+	sw.provider = clientConfig.Provider
 
-	case llm.DeepSeek:
-		client = llm.NewDeepSeekLLM(apiKey)
-
-	case llm.OpenRouter: // 👈 NEW
-		client = llm.NewOpenRouterLLM(apiKey)
+	if clientConfig.AuthToken == "" && clientConfig.TokenProvider == nil && requiresAuthToken(clientConfig.Provider) {
+		log.Println("Warning: Empty API key provided")
+		return sw
+	}
 
-	default:
-		log.Printf("Unsupported LLM provider: %v", provider)
-		return &Swarm{initialized: false, config: config}
+	client, err := NewClientFromConfig(clientConfig)
+	if err != nil {
+		log.Printf("Failed to create %s client: %v", clientConfig.Provider, err)
+		return sw
 	}
 
 	// Verify the client was created properly
 	if client == nil {
 		log.Println("Warning: Failed to initialize LLM client")
-		return &Swarm{initialized: false, config: config}
+		return sw
 	}
 
-	return &Swarm{
-		client:      client,
-		initialized: true,
-		config:      config,
-	}
-}
-
-// NewSwarmWithHost creates a Swarm with a custom host
-func NewSwarmWithHost(apiKey, host string, provider llm.LLMProvider) *Swarm {
-	if provider == llm.OpenAI {
-		client := llm.NewOpenAILLMWithHost(apiKey, host)
-		if client == nil {
-			log.Println("Warning: Failed to initialize OpenAI client with custom host")
-			return &Swarm{
-				initialized: false,
-				config:      DefaultConfig(),
-			}
-		}
-		return &Swarm{
-			client:      client,
-			initialized: true,
-			config:      DefaultConfig(),
-		}
-	}
-	log.Printf("Custom host not supported for provider: %v", provider)
-	return NewSwarm(apiKey, provider)
+	sw.client = client
+	sw.initialized = true
+	return sw
 }
 
 // NewSwarmWithCustomProvider creates a Swarm with a custom LLM provider implementation
 func NewSwarmWithCustomProvider(providerImpl llm.LLM, config *Config) *Swarm {
-	return &Swarm{
+	sw := &Swarm{
 		client:      providerImpl,
 		initialized: true,
 		config:      config,
 	}
+	if config != nil {
+		sw.rateLimiter = config.RateLimiter
+		sw.breaker = config.CircuitBreaker
+		if config.DefaultModel != "" {
+			if _, ok := config.ModelCatalog().Lookup(config.DefaultModel); !ok {
+				log.Printf("Warning: Default model %q is not in the model catalog", config.DefaultModel)
+			}
+		}
+	}
+	return sw
 }
 
-// SetTokenCounter sets a function to count tokens in messages
+// SetTokenCounter sets a function to count tokens in messages. Without one the
+// built-in estimator for each request's model is used.
 func (s *Swarm) SetTokenCounter(counter func(string) int) {
 	s.tokenCounter = counter
 }
 
-// IsInitialized returns whether the Swarm is properly initialized
-func (s *Swarm) IsInitialized() bool {
-	return s.initialized && s.client != nil
+// EstimateTokens estimates the prompt tokens of a request, including message
+// overhead and tool schemas
+func (s *Swarm) EstimateTokens(req llm.ChatCompletionRequest) int {
+	estimator := llm.TokenEstimatorFor(req.Model)
+	if s.tokenCounter != nil {
+		estimator = estimator.WithCounter(s.tokenCounter)
+	}
+	return estimator.CountRequest(req)
 }
 
-// ValidateConnection tests the LLM connection with a simple request
-func (s *Swarm) ValidateConnection(ctx context.Context) error {
-	if !s.IsInitialized() {
-		return ErrLLMClientNotReady
+// SetRateLimiter sets the client-side rate limiter used for all LLM requests.
+// Pass the same limiter to several swarms to share a budget between them.
+func (s *Swarm) SetRateLimiter(limiter *RateLimiter) {
+	s.rateLimiter = limiter
+}
+
+// RateLimiter returns the client-side rate limiter, if any
+func (s *Swarm) RateLimiter() *RateLimiter {
+	return s.rateLimiter
+}
+
+// SetCircuitBreaker sets the circuit breaker used for all LLM requests
+func (s *Swarm) SetCircuitBreaker(breaker *CircuitBreaker) {
+	s.breaker = breaker
+}
+
+// CircuitBreaker returns the circuit breaker, if any
+func (s *Swarm) CircuitBreaker() *CircuitBreaker {
+	return s.breaker
+}
+
+// acquire reserves rate limit budget for a request according to the rate limit strategy
+func (s *Swarm) acquire(ctx context.Context, provider llm.LLMProvider, req llm.ChatCompletionRequest) (int, error) {
+	if s.rateLimiter == nil {
+		return 0, nil
 	}
 
-	// Create a simple test request
-	testRequest := llm.ChatCompletionRequest{
-		Model: s.config.DefaultModel,
-		Messages: []llm.Message{
-			{Role: llm.RoleUser, Content: "Test connection"},
-		},
-		MaxTokens: 5,
+	tokens := s.estimateRequestTokens(req)
+	if s.config != nil && s.config.RateLimitStrategy == RateLimitFail {
+		return tokens, s.rateLimiter.TryAcquire(provider, req.Model, tokens)
 	}
+	return tokens, s.rateLimiter.Wait(ctx, provider, req.Model, tokens)
+}
 
-	// Attempt to send the request
-	_, err := s.client.CreateChatCompletion(ctx, testRequest)
-	if err != nil {
-		return fmt.Errorf("connection test failed: %w", err)
+// allow asks the circuit breaker whether a request may be sent
+func (s *Swarm) allow(provider llm.LLMProvider, model string) error {
+	if s.breaker == nil {
+		return nil
 	}
+	return s.breaker.Allow(provider, model)
+}
 
-	return nil
+// record reports the outcome of a request allowed by the circuit breaker. Context
+// errors say nothing about the provider's health and only free the request.
+func (s *Swarm) record(ctx context.Context, provider llm.LLMProvider, model string, err error) {
+	if s.breaker == nil {
+		return
+	}
+	if err != nil && (ctx.Err() != nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)) {
+		s.breaker.release(provider, model)
+		return
+	}
+	s.breaker.Record(provider, model, err)
 }
 
-// getChatCompletion requests a chat completion from the LLM with retries and error handling
-func (s *Swarm) getChatCompletion(
-	ctx context.Context,
-	agent *Agent,
-	history []llm.Message,
-	contextVariables map[string]interface{},
-	modelOverride string,
-	stream bool,
-	debug bool,
-) (llm.ChatCompletionResponse, error) {
-	// Validate inputs
-	if !s.IsInitialized() {
+// createChatCompletion sends a request to the agent's client, applying the rate
+// limiter and then the circuit breaker, so waiting never holds a trial request
+func (s *Swarm) createChatCompletion(ctx context.Context, agent *Agent, req llm.ChatCompletionRequest) (llm.ChatCompletionResponse, error) {
+	client, provider := s.clientFor(agent)
+	if client == nil {
 		return llm.ChatCompletionResponse{}, ErrLLMClientNotReady
 	}
 
-	if agent == nil {
-		return llm.ChatCompletionResponse{}, ErrNilAgent
+	tools := req.Tools
+	req, emulatedTools := adaptRequest(s.capabilitiesOf(client, req.Model), req)
+
+	estimated, err := s.acquire(ctx, provider, req)
+	if err != nil {
+		return llm.ChatCompletionResponse{}, err
+	}
+	if err := s.allow(provider, req.Model); err != nil {
+		return llm.ChatCompletionResponse{}, err
 	}
 
-	if len(history) == 0 {
-		// Instead of failing, create an empty initial message
-		history = []llm.Message{}
+	resp, err := client.CreateChatCompletion(ctx, req)
+	s.record(ctx, provider, req.Model, err)
+	if err != nil {
+		return llm.ChatCompletionResponse{}, err
+	}
+	if emulatedTools {
+		resp = parseEmulatedToolCalls(resp, tools)
+	}
+	if s.rateLimiter != nil {
+		s.rateLimiter.Record(provider, req.Model, estimated, resp.Usage.TotalTokens)
 	}
+	return resp, nil
+}
 
-	// Prepare the initial system message with agent instructions
-	instructions := agent.Instructions
-	if agent.InstructionsFunc != nil {
-		if contextVariables == nil {
-			contextVariables = make(map[string]interface{})
-		}
-		instructions = agent.InstructionsFunc(contextVariables)
+// createChatCompletionStream opens a stream from the agent's client, applying the rate
+// limiter and the circuit breaker. The stream's outcome is recorded at its first Recv,
+// since some clients only report connection and model errors there.
+func (s *Swarm) createChatCompletionStream(ctx context.Context, agent *Agent, req llm.ChatCompletionRequest) (llm.ChatCompletionStream, error) {
+	client, provider := s.clientFor(agent)
+	if client == nil {
+		return nil, ErrLLMClientNotReady
 	}
 
-	if instructions != "" {
-		// Add system message at the beginning
-		messages := []llm.Message{
-			{
-				Role:    llm.RoleSystem,
-				Content: instructions,
-			},
+	caps := s.capabilitiesOf(client, req.Model)
+	if !caps.Streaming || (len(req.Tools) > 0 && (!caps.Tools || !caps.StreamToolDeltas)) {
+		// Tool calls cannot be streamed, replay a complete response instead
+		resp, err := s.createChatCompletion(ctx, agent, req)
+		if err != nil {
+			return nil, err
 		}
-		messages = append(messages, history...)
-		history = messages
+		return llm.NewSingleResponseStream(resp), nil
 	}
+	req, _ = adaptRequest(caps, req)
 
-	// Build tool definitions from agent's functions
-	var tools []llm.Tool
-	if agent.Functions != nil {
-		for _, af := range agent.Functions {
-			def := FunctionToDefinition(af)
-			tools = append(tools, llm.Tool{
-				Type: "function",
-				Function: &llm.Function{
-					Name:        def.Name,
-					Description: def.Description,
-					Parameters:  def.Parameters,
-				},
-			})
+	if _, err := s.acquire(ctx, provider, req); err != nil {
+		return nil, err
+	}
+	if err := s.allow(provider, req.Model); err != nil {
+		return nil, err
+	}
+
+	stream, err := client.CreateChatCompletionStream(ctx, req)
+	if err != nil {
+		s.record(ctx, provider, req.Model, err)
+		return nil, err
+	}
+	if s.breaker == nil {
+		return stream, nil
+	}
+	return &recordedStream{
+		ChatCompletionStream: stream,
+		record:               func(err error) { s.record(ctx, provider, req.Model, err) },
+		release:              func() { s.breaker.release(provider, req.Model) },
+	}, nil
+}
+
+// recordedStream reports the outcome of a stream to the circuit breaker once,
+// when its first chunk, error or end arrives, or when it is closed before
+type recordedStream struct {
+	llm.ChatCompletionStream
+	record   func(error)
+	release  func()
+	recorded bool
+}
+
+func (s *recordedStream) Recv() (llm.ChatCompletionResponse, error) {
+	resp, err := s.ChatCompletionStream.Recv()
+	if !s.recorded {
+		s.recorded = true
+		if errors.Is(err, io.EOF) {
+			s.record(nil)
+		} else {
+			s.record(err)
 		}
 	}
+	return resp, err
+}
 
-	// Prepare the chat completion request
-	model := agent.Model
-	if modelOverride != "" {
-		model = modelOverride
+func (s *recordedStream) Close() error {
+	if !s.recorded {
+		s.recorded = true
+		s.release()
 	}
+	return s.ChatCompletionStream.Close()
+}
 
-	// Use default model if none specified
-	if model == "" {
-		model = s.config.DefaultModel
+// Cost returns the price in USD of a request to model with the given usage,
+// 0 for models without catalog pricing
+func (s *Swarm) Cost(model string, usage llm.Usage) float64 {
+	cost, _ := s.config.ModelCatalog().Cost(model, usage)
+	return cost
+}
+
+// ContextWindow returns the token limit of model, 0 if it is unknown
+func (s *Swarm) ContextWindow(model string) int {
+	return s.config.ContextWindow(model)
+}
+
+// IsInitialized returns whether the Swarm is properly initialized
+func (s *Swarm) IsInitialized() bool {
+	return s.initialized && s.client != nil
+}
+
+// ValidateConnection tests the LLM connection with a simple request
+func (s *Swarm) ValidateConnection(ctx context.Context) error {
+	if !s.IsInitialized() {
+		return ErrLLMClientNotReady
 	}
 
-	req := llm.ChatCompletionRequest{
-		Model:    model,
-		Messages: history,
-		Tools:    tools,
+	// Create a simple test request
+	testRequest := llm.ChatCompletionRequest{
+		Model: s.config.DefaultModel,
+		Messages: []llm.Message{
+			{Role: llm.RoleUser, Content: "Test connection"},
+		},
+		MaxTokens: 5,
 	}
 
-	if debug {
-		log.Printf("Debug - Model: %s, Messages: %d, Tools: %d\n",
-			model, len(history), len(tools))
+	// Attempt to send the request
+	_, err := s.createChatCompletion(ctx, nil, testRequest)
+	if err != nil {
+		return fmt.Errorf("connection test failed: %w", err)
+	}
+
+	return nil
+}
+
+// withRetries calls call until it succeeds, retrying failed requests up to
+// MaxRetries times with backoff. Fatal errors and open circuits are returned
+// at once so callers can fall back.
+func (s *Swarm) withRetries(ctx context.Context, call func() error) error {
+	if s.config == nil {
+		return call()
 	}
 
-	// Implement retry logic
 	var lastErr error
 	for attempt := 0; attempt <= s.config.MaxRetries; attempt++ {
 		if attempt > 0 && s.config.Debug {
 			log.Printf("Retry attempt %d after error: %v", attempt, lastErr)
 		}
 
-		// Create a timeout context for this request if none was provided
-		requestCtx := ctx
-		if _, hasDeadline := ctx.Deadline(); !hasDeadline {
-			var cancel context.CancelFunc
-			requestCtx, cancel = context.WithTimeout(ctx, s.config.RequestTimeout)
-			defer cancel()
-		}
-
-		// Call the LLM to get a chat completion
-		resp, err := s.client.CreateChatCompletion(requestCtx, req)
+		err := call()
 		if err == nil {
-			// Success
-			return resp, nil
+			return nil
 		}
-
-		// Handle the error
 		lastErr = err
 
+		// Skip retries while the provider's circuit is open, and once the caller gave up
+		if errors.Is(err, ErrCircuitOpen) || ctx.Err() != nil || isFatalError(err) {
+			return err
+		}
+		if attempt == s.config.MaxRetries {
+			break
+		}
+
 		// Check for rate limit errors and apply the rate limit strategy
+		backoff := s.config.RetryBackoff * time.Duration(attempt+1)
 		if isRateLimitError(err) {
 			switch s.config.RateLimitStrategy {
 			case RateLimitFail:
-				return llm.ChatCompletionResponse{}, fmt.Errorf("rate limit exceeded: %w", err)
+				return fmt.Errorf("rate limit exceeded: %w", err)
 			case RateLimitQueue:
 				// Implement exponential backoff
-				backoff := s.config.RetryBackoff * time.Duration(1<<uint(attempt))
+				backoff = s.config.RetryBackoff * time.Duration(1<<uint(attempt))
 				if s.config.Debug {
 					log.Printf("Rate limit hit, backing off for %v", backoff)
 				}
-				select {
-				case <-ctx.Done():
-					return llm.ChatCompletionResponse{}, ctx.Err()
-				case <-time.After(backoff):
-					// Continue to next retry
-				}
-			default: // RateLimitRetry
-				// Simple retry with backoff
-				backoff := s.config.RetryBackoff * time.Duration(attempt+1)
-				if s.config.Debug {
-					log.Printf("Backing off for %v before retry", backoff)
-				}
-				time.Sleep(backoff)
 			}
-		} else if isFatalError(err) {
-			// Don't retry fatal errors
-			return llm.ChatCompletionResponse{}, err
-		} else {
-			// For other errors, apply backoff
-			backoff := s.config.RetryBackoff * time.Duration(attempt+1)
-			time.Sleep(backoff)
+		} else if s.config.Debug {
+			log.Printf("Backing off for %v before retry", backoff)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
 		}
 	}
 
 	// All retries failed
-	return llm.ChatCompletionResponse{}, fmt.Errorf("max retries exceeded: %w", lastErr)
+	return fmt.Errorf("max retries exceeded: %w", lastErr)
 }
 
 // isRateLimitError checks if an error is related to rate limiting
@@ -417,9 +517,10 @@ func (s *Swarm) handleToolCall(
 		return Response{
 			Messages: []llm.Message{
 				{
-					Role:    llm.RoleFunction,
-					Content: errorMsg,
-					Name:    toolName,
+					Role:       llm.RoleFunction,
+					Content:    errorMsg,
+					Name:       toolName,
+					ToolCallID: toolCall.ID,
 				},
 			},
 		}, nil
@@ -438,18 +539,22 @@ func (s *Swarm) handleToolCall(
 		}
 	}
 
-	// Handle case where function is not found
-	if functionFound == nil {
+	// Handle case where function is not found or has no implementation
+	if functionFound == nil || functionFound.Function == nil {
 		errorMsg := fmt.Sprintf("Error: Tool %s not found", toolName)
+		if functionFound != nil {
+			errorMsg = fmt.Sprintf("Error: Tool %s has no implementation", toolName)
+		}
 		if debug {
 			log.Println(errorMsg)
 		}
 		return Response{
 			Messages: []llm.Message{
 				{
-					Role:    llm.RoleFunction,
-					Content: errorMsg,
-					Name:    toolName,
+					Role:       llm.RoleFunction,
+					Content:    errorMsg,
+					Name:       toolName,
+					ToolCallID: toolCall.ID,
 				},
 			},
 		}, nil
@@ -459,18 +564,15 @@ func (s *Swarm) handleToolCall(
 	result := functionFound.Function(args, contextVariables)
 
 	// Create a message with the tool result
-	var resultContent string
-	if result.Error != nil {
-		resultContent = fmt.Sprintf("Error: %v", result.Error)
-	} else {
-		resultContent = fmt.Sprintf("%v", result.Data)
-	}
+	resultContent, resultParts := result.content()
 
 	// Create function response message properly formatted for tool call
 	toolResultMessage := llm.Message{
-		Role:    llm.RoleFunction,
-		Content: resultContent,
-		Name:    toolName,
+		Role:       llm.RoleFunction,
+		Content:    resultContent,
+		Parts:      resultParts,
+		Name:       toolName,
+		ToolCallID: toolCall.ID,
 	}
 
 	// Return the response with the tool result
@@ -481,125 +583,140 @@ func (s *Swarm) handleToolCall(
 	}, nil
 }
 
-// handleToolCalls handles multiple tool calls with correct context forwarding
+// handleToolCalls executes the tool calls of a response and returns their results, the
+// function result messages in call order and the agent handed off to, if any. When
+// ctx ends early the calls completed so far are returned with its error.
 func (s *Swarm) handleToolCalls(
 	ctx context.Context,
 	toolCalls []llm.ToolCall,
-	history []llm.Message,
 	agent *Agent,
 	contextVariables map[string]interface{},
-	modelOverride string,
-	stream bool,
 	debug bool,
 	parallel bool,
 ) ([]ToolResult, []llm.Message, *Agent, error) {
-	var toolResults []ToolResult
-	updatedAgent := agent
-	updatedHistory := make([]llm.Message, len(history))
-	copy(updatedHistory, history)
-
-	// Execute tools sequentially for now for simplicity
-	for _, toolCall := range toolCalls {
-		// Execute the tool call
-		toolResp, err := s.handleToolCall(ctx, &toolCall, updatedAgent, contextVariables, debug)
-		if err != nil {
-			if debug {
-				log.Printf("Error executing tool %s: %v", toolCall.Function.Name, err)
+	var runErr error
+	responses := make([]*Response, len(toolCalls))
+	if parallel && len(toolCalls) > 1 {
+		runErr = s.handleToolCallsParallel(ctx, toolCalls, agent, contextVariables, debug, responses)
+	} else {
+		for i := range toolCalls {
+			if runErr = ctx.Err(); runErr != nil {
+				break
+			}
+			toolResp, err := s.handleToolCall(ctx, &toolCalls[i], agent, contextVariables, debug)
+			if err != nil {
+				if debug {
+					log.Printf("Error executing tool %s: %v", toolCalls[i].Function.Name, err)
+				}
+				continue
 			}
+			responses[i] = &toolResp
+		}
+	}
+
+	var toolResults []ToolResult
+	var toolMessages []llm.Message
+	var memories []Memory
+	var handoff *Agent
+	for i, toolResp := range responses {
+		if toolResp == nil || len(toolResp.Messages) == 0 {
 			continue
 		}
+		toolCall := toolCalls[i]
+		result := toolResp.Messages[0]
 
 		// Parse arguments for the result
 		var args interface{}
 		_ = json.Unmarshal([]byte(toolCall.Function.Arguments), &args)
 
-		// Record the tool result
 		toolResults = append(toolResults, ToolResult{
 			ToolName: toolCall.Function.Name,
 			Args:     args,
 			Result: Result{
 				Success: true,
-				Data:    toolResp.Messages[0].Content,
-				Error:   nil,
+				Data:    result.Content,
 				Agent:   toolResp.Agent,
 			},
 		})
-
-		// Add the function result to history with proper role and name
-		updatedHistory = append(updatedHistory, llm.Message{
-			Role:    llm.RoleFunction,
-			Content: toolResp.Messages[0].Content,
-			Name:    toolCall.Function.Name,
+		toolMessages = append(toolMessages, llm.Message{
+			Role:       llm.RoleFunction,
+			Content:    result.Content,
+			Parts:      result.Parts,
+			Name:       toolCall.Function.Name,
+			ToolCallID: toolCall.ID,
 		})
 
-		// Update agent if needed
-		if toolResp.Agent != nil {
-			updatedAgent = toolResp.Agent
+		// The first handoff wins
+		if toolResp.Agent != nil && handoff == nil {
+			handoff = toolResp.Agent
 		}
-	}
 
-	// Only try to get follow-up if we executed at least one tool successfully
-	if len(toolResults) > 0 {
-		// CRITICAL FIX: Create a new request with the updated history INCLUDING function results
-		followUpReq := llm.ChatCompletionRequest{
-			Model:    updatedAgent.Model,
-			Messages: updatedHistory,
-		}
+		memories = append(memories, Memory{
+			Content: fmt.Sprintf("Tool %s call with args: %v, result: %s",
+				toolCall.Function.Name, args, result.Content),
+			Type:       "tool_call",
+			Context:    map[string]interface{}{"tool": toolCall.Function.Name},
+			Timestamp:  time.Now(),
+			Importance: 0.7,
+		})
+	}
 
-		if modelOverride != "" {
-			followUpReq.Model = modelOverride
+	// Store the calls together so they are embedded in one request
+	if agent.Memory != nil && len(memories) > 0 {
+		if err := agent.Memory.AddMemoriesContext(ctx, memories...); err != nil {
+			log.Printf("Warning: Failed to store memories of tool calls: %v", err)
 		}
+	}
 
-		// Create a timeout for the follow-up request
-		followUpCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
-		defer cancel()
-
-		if debug {
-			log.Printf("Getting follow-up with %d messages in history", len(updatedHistory))
+	return toolResults, toolMessages, handoff, runErr
+}
 
-			// Print last few messages for debugging
-			lastN := 3
-			if len(updatedHistory) < lastN {
-				lastN = len(updatedHistory)
-			}
+// handleToolCallsParallel executes tool calls concurrently, storing each response at
+// the index of its call
+func (s *Swarm) handleToolCallsParallel(
+	ctx context.Context,
+	toolCalls []llm.ToolCall,
+	agent *Agent,
+	contextVariables map[string]interface{},
+	debug bool,
+	responses []*Response,
+) error {
+	type toolCallResult struct {
+		index  int
+		result Response
+		err    error
+	}
 
-			log.Printf("Last %d messages:", lastN)
-			for i := len(updatedHistory) - lastN; i < len(updatedHistory); i++ {
-				msg := updatedHistory[i]
-				log.Printf("[%s] %s: %s", msg.Role, msg.Name, truncateString(msg.Content, 50))
-			}
-		}
+	resultChan := make(chan toolCallResult, len(toolCalls))
 
-		// Get the follow-up response with proper context
-		followUpResp, err := s.client.CreateChatCompletion(followUpCtx, followUpReq)
+	// Create a cancellable context for all tool calls
+	execCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-		if err != nil {
-			if debug {
-				log.Printf("Error getting follow-up: %v", err)
-			}
-			// Continue without follow-up rather than failing
-		} else if len(followUpResp.Choices) > 0 {
-			// Add follow-up response to history
-			followUpMessage := followUpResp.Choices[0].Message
-
-			// Only add if it has content and only append content (no tools)
-			if followUpMessage.Content != "" {
-				cleanedFollowUp := llm.Message{
-					Role:    followUpMessage.Role,
-					Content: followUpMessage.Content,
-				}
-				updatedHistory = append(updatedHistory, cleanedFollowUp)
+	// Launch goroutines for each tool call
+	for i, toolCall := range toolCalls {
+		go func(idx int, tc llm.ToolCall) {
+			toolResp, err := s.handleToolCall(execCtx, &tc, agent, contextVariables, debug)
+			resultChan <- toolCallResult{index: idx, result: toolResp, err: err}
+		}(i, toolCall)
+	}
 
+	// Collect results
+	for range toolCalls {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case result := <-resultChan:
+			if result.err != nil {
 				if debug {
-					log.Printf("Added follow-up: %s", truncateString(followUpMessage.Content, 50))
+					log.Printf("Error in tool call %d: %v", result.index, result.err)
 				}
-			} else if debug {
-				log.Println("Follow-up was empty")
+				continue
 			}
+			responses[result.index] = &result.result
 		}
 	}
-
-	return toolResults, updatedHistory, updatedAgent, nil
+	return nil
 }
 
 // Helper function to truncate strings for debugging
@@ -610,7 +727,10 @@ func truncateString(s string, maxLen int) string {
 	return s[:maxLen] + "..."
 }
 
-// Run is the main entry point for agent execution
+// Run is the main entry point for agent execution. It alternates model responses and
+// tool calls, following handoffs, until the model answers without tool calls or
+// maxTurns responses were requested. A maxTurns of 0 or less means no limit. With
+// stream set, responses are streamed and assembled before tools run.
 func (s *Swarm) Run(
 	ctx context.Context,
 	agent *Agent,
@@ -621,26 +741,166 @@ func (s *Swarm) Run(
 	debug bool,
 	maxTurns int,
 	executeTools bool,
+) (Response, error) {
+	return s.runLoop(ctx, agent, messages, contextVariables, runOptions{
+		modelOverride: modelOverride,
+		stream:        stream,
+		debug:         debug,
+		maxTurns:      maxTurns,
+		executeTools:  executeTools,
+	})
+}
+
+// runOptions controls the agent loop shared by Run, StreamingResponse and RunStream
+type runOptions struct {
+	runID         string
+	modelOverride string
+	stream        bool
+	emit          func(StreamEvent) // Receives the events of the run, may be nil
+	debug         bool
+	maxTurns      int
+	executeTools  bool
+}
+
+// runLoop runs the agent loop and returns the messages added to the conversation.
+// When the run is stopped or its context cancelled, the partial response is returned
+// with the interrupted messages marked.
+func (s *Swarm) runLoop(
+	ctx context.Context,
+	agent *Agent,
+	messages []llm.Message,
+	contextVariables map[string]interface{},
+	opts runOptions,
 ) (Response, error) {
 	// Validate inputs
 	if agent == nil {
 		return Response{}, fmt.Errorf("agent cannot be nil")
 	}
+	if contextVariables == nil {
+		contextVariables = make(map[string]interface{})
+	}
+
+	parentCtx := ctx
+	ctx, cancel := withStop(ctx)
+	defer cancel()
 
 	// Use a cloned copy of messages for history
-	history := make([]llm.Message, len(messages))
-	copy(history, messages)
+	history := cloneMessages(messages)
+	activeAgent := agent
+	var toolResults []ToolResult
+	var usage llm.Usage
 
-	if contextVariables == nil {
-		contextVariables = make(map[string]interface{})
+	turn := 0
+	emit := func(event StreamEvent) {
+		if opts.emit == nil {
+			return
+		}
+		event.RunID = opts.runID
+		event.Agent = activeAgent.Name
+		event.Turn = turn
+		opts.emit(event)
+	}
+	fail := func(err error) (Response, error) {
+		emit(StreamEvent{Type: EventError, Err: err})
+		return Response{}, err
+	}
+	response := func() Response {
+		return Response{
+			Messages:         history[len(messages):],
+			Agent:            activeAgent,
+			ContextVariables: contextVariables,
+			ToolResults:      toolResults,
+			Usage:            usage,
+		}
+	}
+	interrupt := func() (Response, error) {
+		resp := response()
+		resp.Interrupted = true
+		if err := parentCtx.Err(); err != nil {
+			err = fmt.Errorf("%w: %w", ErrInterrupted, err)
+			emit(StreamEvent{Type: EventError, Err: err, Response: &resp})
+			return resp, err
+		}
+		return resp, nil
 	}
 
-	// Set up context variables
+	for turn = 1; opts.maxTurns <= 0 || turn <= opts.maxTurns; turn++ {
+		if ctx.Err() != nil {
+			return interrupt()
+		}
+
+		req := s.buildRequest(activeAgent, history, contextVariables, opts.modelOverride)
+		if opts.debug {
+			log.Printf("Turn %d: agent %s, model %s, %d messages", turn, activeAgent.Name, req.Model, len(req.Messages))
+		}
+
+		var message llm.Message
+		var turnUsage llm.Usage
+		var err error
+		if opts.stream {
+			message, turnUsage, err = s.streamTurn(ctx, activeAgent, req, emit, opts.debug)
+		} else {
+			message, turnUsage, err = s.completeTurn(ctx, activeAgent, req)
+		}
+		usage = addUsage(usage, turnUsage)
+		if err != nil {
+			if ctx.Err() != nil {
+				// Keep the text streamed so far, tool calls may be incomplete
+				if message.Content != "" || message.Reasoning != "" {
+					message.ToolCalls = nil
+					message.Interrupted = true
+					history = append(history, message)
+				}
+				return interrupt()
+			}
+			return fail(fmt.Errorf("chat completion error: %w", err))
+		}
+		history = append(history, message)
+		emit(StreamEvent{Type: EventUsage, Usage: &turnUsage})
+		emit(StreamEvent{Type: EventTurnEnd, Message: &message})
+
+		if len(message.ToolCalls) == 0 || !opts.executeTools {
+			break
+		}
+
+		if opts.debug {
+			log.Printf("Handling %d tool calls", len(message.ToolCalls))
+		}
+		parallel := activeAgent.ParallelToolCalls && s.Capabilities(activeAgent, req.Model).ParallelToolCalls
+		results, toolMessages, handoff, err := s.handleToolCalls(ctx, message.ToolCalls, activeAgent, contextVariables, opts.debug, parallel)
+		for i := range toolMessages {
+			emit(StreamEvent{Type: EventToolResult, ToolResult: &results[i], Message: &toolMessages[i]})
+		}
+		toolResults = append(toolResults, results...)
+		if err != nil {
+			if ctx.Err() != nil {
+				history = append(history, answerToolCalls(message.ToolCalls, toolMessages)...)
+				return interrupt()
+			}
+			return fail(fmt.Errorf("tool execution error: %w", err))
+		}
+		history = append(history, toolMessages...)
+
+		if handoff != nil && handoff != activeAgent {
+			if opts.debug {
+				log.Printf("Handing off from %s to %s", activeAgent.Name, handoff.Name)
+			}
+			emit(StreamEvent{Type: EventHandoff, Handoff: handoff})
+			activeAgent = handoff
+		}
+	}
+
+	return response(), nil
+}
+
+// buildRequest creates the request for the next response of agent. The agent's
+// instructions are sent as system message unless the history already has one.
+func (s *Swarm) buildRequest(agent *Agent, history []llm.Message, contextVariables map[string]interface{}, modelOverride string) llm.ChatCompletionRequest {
+	instructions := agent.Instructions
 	if agent.InstructionsFunc != nil {
-		agent.Instructions = agent.InstructionsFunc(contextVariables)
+		instructions = agent.InstructionsFunc(contextVariables)
 	}
 
-	// Add system instruction as first message if not already present
 	hasSystemMessage := false
 	for _, msg := range history {
 		if msg.Role == llm.RoleSystem {
@@ -649,190 +909,67 @@ func (s *Swarm) Run(
 		}
 	}
 
-	if !hasSystemMessage && agent.Instructions != "" {
-		newHistory := make([]llm.Message, 0, len(history)+1)
-		newHistory = append(newHistory, llm.Message{
-			Role:    llm.RoleSystem,
-			Content: agent.Instructions,
-		})
-		newHistory = append(newHistory, history...)
-		history = newHistory
+	requestMessages := history
+	if !hasSystemMessage && instructions != "" {
+		requestMessages = make([]llm.Message, 0, len(history)+1)
+		requestMessages = append(requestMessages, llm.Message{Role: llm.RoleSystem, Content: instructions})
+		requestMessages = append(requestMessages, history...)
 	}
 
-	// Get chat completion from LLM
-	if debug {
-		log.Printf("Getting initial response with %d messages", len(history))
+	// Prepare tools for the request
+	var tools []llm.Tool
+	for _, af := range agent.Functions {
+		def := FunctionToDefinition(af)
+		tools = append(tools, llm.Tool{Type: "function", Function: &def})
 	}
 
 	model := agent.Model
 	if modelOverride != "" {
 		model = modelOverride
 	}
-
-	// Prepare tools for the request
-	var tools []llm.Tool
-	if agent.Functions != nil {
-		for _, fn := range agent.Functions {
-			tools = append(tools, llm.Tool{
-				Type: "function",
-				Function: &llm.Function{
-					Name:        fn.Name,
-					Description: fn.Description,
-					Parameters:  fn.Parameters,
-				},
-			})
-		}
+	if model == "" && s.config != nil {
+		model = s.config.DefaultModel
 	}
 
-	// Create the request
-	req := llm.ChatCompletionRequest{
+	return llm.ChatCompletionRequest{
 		Model:    model,
-		Messages: history,
+		Messages: requestMessages,
 		Tools:    tools,
 	}
+}
+
+// completeTurn requests a single response
+func (s *Swarm) completeTurn(ctx context.Context, agent *Agent, req llm.ChatCompletionRequest) (llm.Message, llm.Usage, error) {
+	var resp llm.ChatCompletionResponse
+	err := s.withRetries(ctx, func() error {
+		// Give each attempt its own timeout if the caller set no deadline
+		requestCtx := ctx
+		if _, hasDeadline := ctx.Deadline(); !hasDeadline && s.config != nil && s.config.RequestTimeout > 0 {
+			var cancel context.CancelFunc
+			requestCtx, cancel = context.WithTimeout(ctx, s.config.RequestTimeout)
+			defer cancel()
+		}
 
-	// Get initial response
-	resp, err := s.client.CreateChatCompletion(ctx, req)
+		var err error
+		resp, err = s.createChatCompletion(requestCtx, agent, req)
+		return err
+	})
 	if err != nil {
-		return Response{}, fmt.Errorf("chat completion error: %v", err)
+		return llm.Message{}, llm.Usage{}, err
 	}
-
 	if len(resp.Choices) == 0 {
-		return Response{}, fmt.Errorf("no choices in response")
+		return llm.Message{}, resp.Usage, ErrNoChoicesInResp
 	}
-
-	// Extract the response
-	choice := resp.Choices[0]
-	history = append(history, choice.Message)
-
-	// Handle tool calls if present and execution is enabled
-	if len(choice.Message.ToolCalls) > 0 && executeTools {
-		if debug {
-			log.Printf("Handling %d tool calls", len(choice.Message.ToolCalls))
-		}
-
-		// Execute tools and get the updated history including follow-up
-		toolResults, updatedHistory, updatedAgent, err := s.handleToolCalls(
-			ctx, choice.Message.ToolCalls, history, agent,
-			contextVariables, modelOverride, stream, debug,
-			agent.ParallelToolCalls)
-
-		if err != nil {
-			return Response{}, fmt.Errorf("tool execution error: %v", err)
-		}
-
-		// Calculate which messages to return (only the new ones)
-		newMessages := updatedHistory[len(messages):]
-
-		return Response{
-			Messages:         newMessages,
-			Agent:            updatedAgent,
-			ContextVariables: contextVariables,
-			ToolResults:      toolResults,
-		}, nil
-	}
-
-	// No tool calls - just return the normal response
-	return Response{
-		Messages:         history[len(messages):],
-		Agent:            agent,
-		ContextVariables: contextVariables,
-	}, nil
+	return resp.Choices[0].Message, resp.Usage, nil
 }
 
-// handleToolCallsParallel executes multiple tool calls concurrently
-func (s *Swarm) handleToolCallsParallel(
-	ctx context.Context,
-	toolCalls []llm.ToolCall,
-	history []llm.Message,
-	agent *Agent,
-	contextVariables map[string]interface{},
-	modelOverride string,
-	stream bool,
-	debug bool,
-) ([]ToolResult, []llm.Message, *Agent, error) {
-	type toolCallResult struct {
-		index  int
-		result Response
-		err    error
-	}
-
-	resultChan := make(chan toolCallResult, len(toolCalls))
-	updatedHistory := make([]llm.Message, len(history))
-	copy(updatedHistory, history)
-	updatedAgent := agent
-
-	// Create a cancellable context for all tool calls
-	execCtx, cancel := context.WithCancel(ctx)
-	defer cancel()
-
-	// Launch goroutines for each tool call
-	for i, toolCall := range toolCalls {
-		go func(idx int, tc llm.ToolCall) {
-			toolResp, err := s.handleToolCall(execCtx, &tc, agent, contextVariables, debug)
-			resultChan <- toolCallResult{index: idx, result: toolResp, err: err}
-		}(i, toolCall)
-	}
-
-	// Collect results
-	var toolResults []ToolResult
-	agentTransferred := false
-
-	for i := 0; i < len(toolCalls); i++ {
-		select {
-		case <-ctx.Done():
-			return nil, history, agent, ctx.Err()
-		case result := <-resultChan:
-			if result.err != nil {
-				if debug {
-					log.Printf("Error in tool call %d: %v", result.index, result.err)
-				}
-				continue
-			}
-
-			// Get the original tool call
-			toolCall := toolCalls[result.index]
-			var args interface{}
-			_ = json.Unmarshal([]byte(toolCall.Function.Arguments), &args)
-
-			// Add to tool results
-			toolResults = append(toolResults, ToolResult{
-				ToolName: toolCall.Function.Name,
-				Args:     args,
-				Result: Result{
-					Success: true,
-					Data:    result.result.Messages[0].Content,
-					Error:   nil,
-					Agent:   result.result.Agent,
-				},
-			})
-
-			// Add to history
-			updatedHistory = append(updatedHistory, llm.Message{
-				Role:    llm.RoleFunction,
-				Content: result.result.Messages[0].Content,
-				Name:    toolCall.Function.Name,
-			})
-
-			// Only update agent if not already transferred
-			if result.result.Agent != nil && !agentTransferred {
-				updatedAgent = result.result.Agent
-				agentTransferred = true
-			}
-
-			// Store in memory
-			if agent.Memory != nil {
-				agent.Memory.AddMemory(Memory{
-					Content: fmt.Sprintf("Tool %s call with args: %v, result: %s",
-						toolCall.Function.Name, args, result.result.Messages[0].Content),
-					Type:       "tool_call",
-					Context:    map[string]interface{}{"tool": toolCall.Function.Name},
-					Timestamp:  time.Now(),
-					Importance: 0.7,
-				})
-			}
-		}
+// addUsage sums the token usage of two responses
+func addUsage(a, b llm.Usage) llm.Usage {
+	return llm.Usage{
+		PromptTokens:        a.PromptTokens + b.PromptTokens,
+		CompletionTokens:    a.CompletionTokens + b.CompletionTokens,
+		TotalTokens:         a.TotalTokens + b.TotalTokens,
+		CacheReadTokens:     a.CacheReadTokens + b.CacheReadTokens,
+		CacheCreationTokens: a.CacheCreationTokens + b.CacheCreationTokens,
 	}
-
-	return toolResults, updatedHistory, updatedAgent, nil
 }
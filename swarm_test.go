@@ -12,6 +12,7 @@ import (
 	"github.com/mohan2020coder/swarmgo/llm"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 // MockLLM is a mock implementation of the LLM interface
@@ -158,6 +159,19 @@ func TestHandleToolCallFunctionNotFound(t *testing.T) {
 	assert.Contains(t, response.Messages[0].Content, "Error: Tool nonExistentFunction not found.")
 }
 
+// TestHandleToolCallWithoutImplementation tests that a tool without a function
+// returns an error result instead of panicking
+func TestHandleToolCallWithoutImplementation(t *testing.T) {
+	toolCall := llm.ToolCall{ID: "call_1", Type: "function", Function: llm.ToolCallFunction{Name: "lookup_user", Arguments: `{}`}}
+	agent := &Agent{Name: "TestAgent", Functions: []AgentFunction{{Name: "lookup_user"}}}
+
+	response, err := (&Swarm{}).handleToolCall(context.Background(), &toolCall, agent, nil, false)
+	require.NoError(t, err)
+	require.Len(t, response.Messages, 1)
+	assert.Equal(t, "Error: Tool lookup_user has no implementation", response.Messages[0].Content)
+	assert.Equal(t, "call_1", response.Messages[0].ToolCallID)
+}
+
 // TestRun tests the Run method
 func TestRun(t *testing.T) {
 	mockClient := new(MockLLM)
@@ -296,3 +310,78 @@ func TestProcessAndPrintResponse(t *testing.T) {
 	output := buf.String()
 	assert.NotNil(t, output)
 }
+
+// TestRunRoutesAgentsToTheirProvider tests per-agent provider selection within a single swarm
+func TestRunRoutesAgentsToTheirProvider(t *testing.T) {
+	defaultClient := new(MockLLM)
+	claudeClient := new(MockLLM)
+	sw := NewSwarmWithCustomProvider(defaultClient, DefaultConfig())
+	sw.RegisterClient(llm.Claude, claudeClient)
+
+	reply := func(content string) llm.ChatCompletionResponse {
+		return llm.ChatCompletionResponse{
+			Choices: []llm.Choice{{Message: llm.Message{Role: llm.RoleAssistant, Content: content}}},
+		}
+	}
+	defaultClient.On("CreateChatCompletion", mock.Anything, mock.Anything).Return(reply("router"), nil)
+	claudeClient.On("CreateChatCompletion", mock.Anything, mock.Anything).Return(reply("writer"), nil)
+
+	messages := []llm.Message{{Role: llm.RoleUser, Content: "Hello"}}
+	ctx := context.Background()
+
+	router := &Agent{Name: "Router", Model: "llama3"}
+	writer := &Agent{Name: "Writer", Model: "claude-3-opus", Provider: llm.Claude}
+	// No client or credentials are registered for Gemini, so the default is used
+	fallback := &Agent{Name: "Fallback", Model: "gemini-pro", Provider: llm.Gemini}
+
+	resp, err := sw.Run(ctx, router, messages, nil, "", false, false, 1, true)
+	assert.NoError(t, err)
+	assert.Equal(t, "router", resp.Messages[0].Content)
+
+	resp, err = sw.Run(ctx, writer, messages, nil, "", false, false, 1, true)
+	assert.NoError(t, err)
+	assert.Equal(t, "writer", resp.Messages[0].Content)
+
+	resp, err = sw.Run(ctx, fallback, messages, nil, "", false, false, 1, true)
+	assert.NoError(t, err)
+	assert.Equal(t, "router", resp.Messages[0].Content)
+
+	defaultClient.AssertNumberOfCalls(t, "CreateChatCompletion", 2)
+	claudeClient.AssertNumberOfCalls(t, "CreateChatCompletion", 1)
+}
+
+// TestAgentNodeLimitsTurns tests that an agent node stops a model that keeps calling tools
+func TestAgentNodeLimitsTurns(t *testing.T) {
+	client := new(MockLLM)
+	toolCall := llm.ChatCompletionResponse{
+		Choices: []llm.Choice{{Message: llm.Message{
+			Role:      llm.RoleAssistant,
+			ToolCalls: []llm.ToolCall{{ID: "call_1", Type: "function", Function: llm.ToolCallFunction{Name: "lookup", Arguments: `{}`}}},
+		}}},
+	}
+	client.On("CreateChatCompletion", mock.Anything, mock.Anything).Return(toolCall, nil)
+
+	agent := &Agent{
+		Name:  "Looper",
+		Model: "test-model",
+		Functions: []AgentFunction{{
+			Name: "lookup",
+			Function: func(args map[string]interface{}, contextVariables map[string]interface{}) Result {
+				return Result{Success: true, Data: "again"}
+			},
+		}},
+	}
+	state := GraphState{MessageKey: []llm.Message{{Role: llm.RoleUser, Content: "Hello"}}}
+
+	graph := NewGraph("limit", "")
+	graph.SetSwarm(NewMockSwarm(client))
+	node := graph.AddAgentNode("agent", "Agent", agent)
+	_, err := node.Process(context.Background(), state)
+	require.NoError(t, err)
+	client.AssertNumberOfCalls(t, "CreateChatCompletion", DefaultAgentNodeMaxTurns)
+
+	graph.SetMaxTurns(3)
+	_, err = node.Process(context.Background(), state)
+	require.NoError(t, err)
+	client.AssertNumberOfCalls(t, "CreateChatCompletion", DefaultAgentNodeMaxTurns+3)
+}
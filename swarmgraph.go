@@ -114,8 +114,15 @@ type Graph struct {
 	ExitPoints  []NodeID // Optional exit points
 	mutex       sync.RWMutex
 	eventHooks  map[string][]func(state GraphState)
+	rateLimiter *RateLimiter // Shared by all agent nodes, including parallel ones
+	swarm       *Swarm       // Runs agent nodes, routing each agent to its own provider
+	maxTurns    int          // Responses requested per agent node run
 }
 
+// DefaultAgentNodeMaxTurns is the number of responses an agent node requests by
+// default: the first response and one follow-up after its tools ran.
+const DefaultAgentNodeMaxTurns = 2
+
 // NewGraph creates a new workflow graph
 func NewGraph(name string, description string) *Graph {
 	return &Graph{
@@ -125,6 +132,7 @@ func NewGraph(name string, description string) *Graph {
 		Nodes:       make(map[NodeID]*Node),
 		Edges:       make(map[NodeID][]Edge),
 		eventHooks:  make(map[string][]func(state GraphState)),
+		maxTurns:    DefaultAgentNodeMaxTurns,
 	}
 }
 
@@ -169,15 +177,21 @@ func (g *Graph) AddAgentNode(id NodeID, name string, agent *Agent) *Node {
 			return state, fmt.Errorf("error unmarshaling messages: %w", err)
 		}
 
-		// Create swarm client if needed
-		apiKey, _ := state.GetString("api_key")
-		providerStr, _ := state.GetString("provider")
-		provider := llm.LLMProvider(providerStr)
-		if provider == "" {
-			provider = llm.OpenAI
-		}
+		// Use the graph's swarm, or create one from the state if none was set
+		client := g.Swarm()
+		if client == nil {
+			apiKey, _ := state.GetString("api_key")
+			providerStr, _ := state.GetString("provider")
+			provider := llm.LLMProvider(providerStr)
+			if provider == "" {
+				provider = llm.OpenAI
+			}
 
-		client := NewSwarm(apiKey, provider)
+			client = NewSwarm(apiKey, provider)
+			if limiter := g.RateLimiter(); limiter != nil {
+				client.SetRateLimiter(limiter)
+			}
+		}
 
 		// Extract context variables
 		contextVars := make(map[string]interface{})
@@ -189,7 +203,7 @@ func (g *Graph) AddAgentNode(id NodeID, name string, agent *Agent) *Node {
 		}
 
 		// Run the agent
-		response, err := client.Run(ctx, agent, messages, contextVars, "", false, false, 1, true)
+		response, err := client.Run(ctx, agent, messages, contextVars, "", false, false, g.MaxTurns(), true)
 		if err != nil {
 			return state, fmt.Errorf("error running agent: %w", err)
 		}
@@ -230,6 +244,57 @@ func (g *Graph) AddAgentNode(id NodeID, name string, agent *Agent) *Node {
 	return node
 }
 
+// SetSwarm sets the swarm used to run agent nodes. Each agent is routed to the
+// client for its own Provider/Config, falling back to the swarm default.
+func (g *Graph) SetSwarm(swarm *Swarm) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.swarm = swarm
+}
+
+// Swarm returns the swarm used to run agent nodes, if any
+func (g *Graph) Swarm() *Swarm {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+	return g.swarm
+}
+
+// SetRateLimiter sets a rate limiter shared by the agent nodes of a graph that
+// creates its own swarms from the state. A swarm set with SetSwarm uses its own limiter.
+func (g *Graph) SetRateLimiter(limiter *RateLimiter) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.rateLimiter = limiter
+}
+
+// RateLimiter returns the rate limiter shared by the graph's agent nodes
+func (g *Graph) RateLimiter() *RateLimiter {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+	return g.rateLimiter
+}
+
+// SetMaxTurns sets the number of responses each agent node requests per run.
+// Values below 1 are ignored, an agent node always has a limit.
+func (g *Graph) SetMaxTurns(maxTurns int) {
+	if maxTurns < 1 {
+		return
+	}
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.maxTurns = maxTurns
+}
+
+// MaxTurns returns the number of responses each agent node requests per run
+func (g *Graph) MaxTurns() int {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+	if g.maxTurns < 1 {
+		return DefaultAgentNodeMaxTurns
+	}
+	return g.maxTurns
+}
+
 // AddDirectedEdge adds a simple directed edge between nodes
 func (g *Graph) AddDirectedEdge(from NodeID, to NodeID) error {
 	g.mutex.Lock()
@@ -624,6 +689,12 @@ func (b *GraphBuilder) Build() *Graph {
 	return b.graph
 }
 
+// WithSwarm sets the swarm used to run agent nodes
+func (b *GraphBuilder) WithSwarm(swarm *Swarm) *GraphBuilder {
+	b.graph.SetSwarm(swarm)
+	return b
+}
+
 // WithAgent adds an agent node to the graph
 func (b *GraphBuilder) WithAgent(id NodeID, name string, agent *Agent) *GraphBuilder {
 	b.graph.AddAgentNode(id, name, agent)
@@ -0,0 +1,85 @@
+package swarmgo
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mohan2020coder/swarmgo/llm"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestTokenizerFamilyOf tests automatic estimator selection by model name
+func TestTokenizerFamilyOf(t *testing.T) {
+	cases := map[string]llm.TokenizerFamily{
+		"gpt-4o-mini":                       llm.TokenizerO200k,
+		"openai/o3-mini":                    llm.TokenizerO200k,
+		"gpt-4-turbo":                       llm.TokenizerCL100k,
+		"gpt-3.5-turbo":                     llm.TokenizerCL100k,
+		"claude-3-5-sonnet-latest":          llm.TokenizerClaude,
+		"anthropic/claude-sonnet-4":         llm.TokenizerClaude,
+		"gemini-2.0-flash":                  llm.TokenizerGemini,
+		"llama3.2:3b":                       llm.TokenizerLlama,
+		"deepseek-reasoner":                 llm.TokenizerLlama,
+		"some-unknown-model":                llm.TokenizerHeuristic,
+		"meta-llama/Llama-3.3-70B-Instruct": llm.TokenizerLlama,
+	}
+	for model, family := range cases {
+		assert.Equal(t, family, llm.TokenizerFamilyOf(model), model)
+	}
+}
+
+// TestTokenEstimatorText tests estimates against known tokenizer counts
+func TestTokenEstimatorText(t *testing.T) {
+	assert.Equal(t, 4, llm.CountTokens("gpt-4", "Hello, world!"))
+	assert.Equal(t, 10, llm.CountTokens("gpt-4o", "The quick brown fox jumps over the lazy dog."))
+	assert.Equal(t, 3, llm.CountTokens("gpt-4", "1234567"))
+	assert.Equal(t, 0, llm.CountTokens("claude-3-opus", ""))
+
+	// Longer prose stays within a reasonable margin of roughly 0.75 words per token
+	prose := strings.Repeat("Agents coordinate through handoffs and share context variables between turns. ", 20)
+	for _, model := range []string{"gpt-4o", "claude-3-opus", "gemini-1.5-pro", "llama3.2"} {
+		words := len(strings.Fields(prose))
+		tokens := llm.CountTokens(model, prose)
+		assert.InDelta(t, float64(words)*1.3, float64(tokens), float64(words)*0.5, model)
+	}
+
+	// Non-Latin text costs more tokens per character than English
+	assert.Greater(t, llm.CountTokens("gpt-4", "こんにちは世界"), llm.CountTokens("gpt-4", "hello"))
+}
+
+// TestTokenEstimatorRequest tests that requests count message overhead, tool schemas and images
+func TestTokenEstimatorRequest(t *testing.T) {
+	req := llm.ChatCompletionRequest{
+		Model: "gpt-4o",
+		Messages: []llm.Message{
+			{Role: llm.RoleSystem, Content: "You are a helpful assistant."},
+			{Role: llm.RoleUser, Content: "Hello"},
+		},
+	}
+	estimator := llm.TokenEstimatorFor(req.Model)
+	text := estimator.CountText("You are a helpful assistant.") + estimator.CountText("Hello")
+	base := llm.CountRequestTokens(req)
+	assert.Greater(t, base, text)
+
+	req.Tools = []llm.Tool{lookupTool}
+	withTools := llm.CountRequestTokens(req)
+	assert.Greater(t, withTools, base+estimator.CountText(`{"type":"object"}`))
+
+	req.Messages[1].Parts = []llm.ContentPart{llm.ImagePart(testPNG, "image/png")}
+	assert.GreaterOrEqual(t, llm.CountRequestTokens(req), withTools+500)
+
+	// A custom counter replaces text counting but keeps the overheads
+	counted := estimator.WithCounter(func(s string) int { return 1 }).CountRequest(llm.ChatCompletionRequest{
+		Model:    "gpt-4o",
+		Messages: []llm.Message{{Role: llm.RoleUser, Content: "anything at all"}},
+	})
+	assert.Equal(t, llm.TokenEstimatorFor("gpt-4o").CountRequest(llm.ChatCompletionRequest{
+		Model:    "gpt-4o",
+		Messages: []llm.Message{{Role: llm.RoleUser, Content: "x"}},
+	}), counted)
+
+	swarm := &Swarm{}
+	assert.Equal(t, llm.CountRequestTokens(req), swarm.EstimateTokens(req))
+	swarm.SetTokenCounter(func(s string) int { return 1 })
+	assert.Less(t, swarm.EstimateTokens(req), llm.CountRequestTokens(req))
+}
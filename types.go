@@ -1,6 +1,8 @@
 package swarmgo
 
 import (
+	"fmt"
+
 	"github.com/mohan2020coder/swarmgo/llm"
 )
 
@@ -10,6 +12,8 @@ type Response struct {
 	Agent            *Agent
 	ContextVariables map[string]interface{}
 	ToolResults      []ToolResult // Results from tool calls
+	Usage            llm.Usage    // Token usage summed over all responses
+	Interrupted      bool         // Whether the run was stopped or cancelled before it finished
 }
 
 // ToolResult represents the result of a tool call
@@ -22,7 +26,34 @@ type ToolResult struct {
 // Result represents the result of a function execution
 type Result struct {
 	Success bool        // Whether the function execution was successful
-	Data    interface{} // Any data returned by the function
+	Data    interface{} // Any data returned by the function, llm.ContentPart values are sent as media
 	Error   error       // Any error that occurred during execution
 	Agent   *Agent      // Active agent
 }
+
+// content returns the text and media parts of a result for the function result message
+func (r Result) content() (string, []llm.ContentPart) {
+	if r.Error != nil {
+		return fmt.Sprintf("Error: %v", r.Error), nil
+	}
+
+	var parts []llm.ContentPart
+	switch data := r.Data.(type) {
+	case llm.ContentPart:
+		parts = []llm.ContentPart{data}
+	case []llm.ContentPart:
+		parts = data
+	default:
+		return fmt.Sprintf("%v", r.Data), nil
+	}
+
+	// Text parts become the content so providers without media still see them
+	msg := llm.Message{Parts: parts}
+	var media []llm.ContentPart
+	for _, part := range parts {
+		if part.Type != llm.ContentPartText {
+			media = append(media, part)
+		}
+	}
+	return msg.Text(), media
+}